@@ -0,0 +1,351 @@
+package collector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+	"time"
+)
+
+// 审计 netlink 协议常量（linux/audit.h、linux/netlink.h）
+const (
+	netlinkAudit = 9 // NETLINK_AUDIT
+
+	auditGet     = 1000 // AUDIT_GET
+	auditSet     = 1001 // AUDIT_SET
+	auditAddRule = 1011 // AUDIT_ADD_RULE
+	auditDelRule = 1012 // AUDIT_DEL_RULE
+
+	auditStatusEnabled = 0x0001 // AUDIT_STATUS_ENABLED
+	auditStatusPID     = 0x0004 // AUDIT_STATUS_PID
+
+	auditNlgrpReadlog = 1 // AUDIT_NLGRP_READLOG，只读多播组，需要 CAP_AUDIT_READ
+
+	sizeofAuditStatus = 40 // struct audit_status 中本程序使用的前 10 个字段
+)
+
+// auditAssembleTimeout 等待事件 EOE 记录的最长时间，超时后按已收到的记录输出
+const auditAssembleTimeout = 2 * time.Second
+
+// auditMode 审计事件的接收方式
+type auditMode int
+
+const (
+	auditModeUnicast   auditMode = iota // 注册为审计守护进程，独占接收事件
+	auditModeMulticast                  // auditd 已占用时订阅只读多播组
+)
+
+func (m auditMode) String() string {
+	if m == auditModeMulticast {
+		return "multicast"
+	}
+	return "unicast"
+}
+
+// auditStatus 内核审计状态（struct audit_status 的前几个字段）
+type auditStatus struct {
+	Enabled uint32
+	PID     uint32
+}
+
+// auditClient 基于 NETLINK_AUDIT 的审计事件采集，需要 CAP_AUDIT_CONTROL（多播模式还需要 CAP_AUDIT_READ）
+type auditClient struct {
+	control *auditSocket // 发送状态查询和规则等控制请求
+	events  *auditSocket // 接收审计事件
+	mode    auditMode
+	handler func(AuditEvent)
+
+	enabled bool         // 审计原本关闭、由本程序开启，Close 时关闭
+	added   []*auditRule // 本程序添加的规则，Close 时删除
+}
+
+// newAuditClient 连接内核审计子系统并安装规则
+//
+// 内核同时只允许一个进程注册为审计守护进程。auditd 已在运行时注册会返回 EEXIST，
+// 此时改为订阅只读多播组，事件仍由 auditd 写入 audit.log，规则也照常添加。
+func newAuditClient(rules []*auditRule, handler func(AuditEvent)) (*auditClient, error) {
+	control, err := openAuditSocket(0)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &auditClient{control: control, handler: handler}
+	status, err := c.status()
+	if err != nil {
+		control.Close()
+		return nil, fmt.Errorf("get audit status: %w", err)
+	}
+	if status.Enabled == 2 {
+		log.Println("Audit configuration is locked (enabled=2), rules cannot be changed")
+	}
+
+	// 先注册再加规则，避免规则生效后的事件在注册前进入内核日志
+	c.events, err = openAuditSocket(0)
+	if err != nil {
+		control.Close()
+		return nil, err
+	}
+	err = c.events.request(auditSet, encodeAuditStatus(auditStatusPID, 0, uint32(os.Getpid())))
+	switch {
+	case err == nil:
+		c.mode = auditModeUnicast
+	case errors.Is(err, syscall.EEXIST):
+		c.events.Close()
+		log.Printf("Audit events are owned by another daemon (pid %d), subscribing to the read-only multicast group", status.PID)
+		if c.events, err = openAuditSocket(auditNlgrpReadlog); err != nil {
+			control.Close()
+			return nil, fmt.Errorf("subscribe to audit multicast: %w", err)
+		}
+		c.mode = auditModeMulticast
+	default:
+		c.events.Close()
+		control.Close()
+		return nil, fmt.Errorf("register as audit daemon: %w", err)
+	}
+
+	if status.Enabled == 0 {
+		if err := c.control.request(auditSet, encodeAuditStatus(auditStatusEnabled, 1, 0)); err != nil {
+			log.Printf("Failed to enable auditing: %v", err)
+		} else {
+			c.enabled = true
+		}
+	}
+
+	for _, rule := range rules {
+		err := c.control.request(auditAddRule, rule.marshal())
+		switch {
+		case err == nil:
+			c.added = append(c.added, rule)
+		case errors.Is(err, syscall.EEXIST):
+			// 同样的规则已由 auditd 或上次未正常退出的代理添加，保留不删
+		default:
+			log.Printf("Failed to add audit rule %q: %v", rule.text, err)
+		}
+	}
+
+	return c, nil
+}
+
+// Run 读取审计记录，按序号合并为事件后分发，直到 Close 被调用
+func (c *auditClient) Run() {
+	records := make(chan auditRecord, 256)
+	done := make(chan struct{})
+	go c.assemble(records, done)
+	defer func() {
+		close(records)
+		<-done
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := c.events.file.Read(buf)
+		if err != nil {
+			if errors.Is(err, syscall.ENOBUFS) {
+				// 接收缓冲区溢出，期间的记录已丢失
+				log.Println("Audit receive buffer overflowed, some audit records were lost")
+				continue
+			}
+			if !errors.Is(err, os.ErrClosed) {
+				log.Printf("Audit socket read failed: %v", err)
+			}
+			return
+		}
+
+		// 内核每个数据报只发送一条审计消息，部分内核版本的 nlmsg_len 不准确，按数据报长度读取
+		if n < syscall.NLMSG_HDRLEN {
+			continue
+		}
+		typ := binary.LittleEndian.Uint16(buf[4:6])
+		if typ < auditFirstUserMsg && typ != auditTypeLogin {
+			continue
+		}
+		rec, err := parseAuditMessage(typ, string(buf[syscall.NLMSG_HDRLEN:n]))
+		if err != nil {
+			continue
+		}
+		records <- rec
+	}
+}
+
+// auditFirstUserMsg 审计消息类型的起始值，更小的是 netlink 控制消息和请求应答（内核的 LOGIN 记录除外）
+const (
+	auditFirstUserMsg = 1100
+	auditTypeLogin    = 1006
+)
+
+// assemble 合并记录，定时输出缺少 EOE 的事件
+func (c *auditClient) assemble(records <-chan auditRecord, done chan<- struct{}) {
+	defer close(done)
+
+	assembler := newAuditAssembler(auditAssembleTimeout)
+	ticker := time.NewTicker(auditAssembleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case rec, ok := <-records:
+			if !ok {
+				for _, ev := range assembler.flush(time.Now(), true) {
+					c.handler(ev)
+				}
+				return
+			}
+			if ev, ok := assembler.add(rec, time.Now()); ok {
+				c.handler(ev)
+			}
+		case now := <-ticker.C:
+			for _, ev := range assembler.flush(now, false) {
+				c.handler(ev)
+			}
+		}
+	}
+}
+
+// Close 删除本程序添加的规则，注销审计守护进程并恢复审计开关
+func (c *auditClient) Close() error {
+	for _, rule := range c.added {
+		if err := c.control.request(auditDelRule, rule.marshal()); err != nil && !errors.Is(err, syscall.ENOENT) {
+			log.Printf("Failed to delete audit rule %q: %v", rule.text, err)
+		}
+	}
+	if c.mode == auditModeUnicast {
+		if err := c.control.request(auditSet, encodeAuditStatus(auditStatusPID, 0, 0)); err != nil {
+			log.Printf("Failed to unregister audit daemon: %v", err)
+		}
+	}
+	if c.enabled {
+		if err := c.control.request(auditSet, encodeAuditStatus(auditStatusEnabled, 0, 0)); err != nil {
+			log.Printf("Failed to restore audit state: %v", err)
+		}
+	}
+
+	c.events.Close()
+	return c.control.Close()
+}
+
+// status 查询内核审计状态
+func (c *auditClient) status() (auditStatus, error) {
+	data, err := c.control.query(auditGet)
+	if err != nil {
+		return auditStatus{}, err
+	}
+	if len(data) < 16 {
+		return auditStatus{}, fmt.Errorf("short audit status reply")
+	}
+	return auditStatus{
+		Enabled: binary.LittleEndian.Uint32(data[4:8]),
+		PID:     binary.LittleEndian.Uint32(data[12:16]),
+	}, nil
+}
+
+// encodeAuditStatus 编码 AUDIT_SET 请求
+func encodeAuditStatus(mask, enabled, pid uint32) []byte {
+	b := make([]byte, sizeofAuditStatus)
+	binary.LittleEndian.PutUint32(b[0:], mask)
+	binary.LittleEndian.PutUint32(b[4:], enabled)
+	binary.LittleEndian.PutUint32(b[12:], pid)
+	return b
+}
+
+// auditSocket NETLINK_AUDIT 套接字
+type auditSocket struct {
+	fd   int
+	file *os.File
+	seq  uint32
+}
+
+// openAuditSocket 打开审计套接字，groups 非 0 时订阅多播组
+func openAuditSocket(groups uint32) (*auditSocket, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, netlinkAudit)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: groups}); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	// 事件突发时避免溢出
+	syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_RCVBUF, 1<<20)
+
+	// 非阻塞描述符交给 runtime 轮询，Close 时可以唤醒阻塞中的 Read
+	return &auditSocket{fd: fd, file: os.NewFile(uintptr(fd), "netlink-audit")}, nil
+}
+
+// send 发送请求并要求内核应答
+func (s *auditSocket) send(typ uint16, payload []byte) (uint32, error) {
+	s.seq++
+	msg := make([]byte, syscall.NLMSG_HDRLEN+len(payload))
+	binary.LittleEndian.PutUint32(msg[0:], uint32(len(msg)))
+	binary.LittleEndian.PutUint16(msg[4:], typ)
+	binary.LittleEndian.PutUint16(msg[6:], syscall.NLM_F_REQUEST|syscall.NLM_F_ACK)
+	binary.LittleEndian.PutUint32(msg[8:], s.seq)
+	copy(msg[syscall.NLMSG_HDRLEN:], payload)
+
+	return s.seq, syscall.Sendto(s.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+}
+
+// request 发送请求并等待内核确认
+func (s *auditSocket) request(typ uint16, payload []byte) error {
+	seq, err := s.send(typ, payload)
+	if err != nil {
+		return err
+	}
+	_, err = s.wait(seq, 0)
+	return err
+}
+
+// query 发送查询请求，返回类型相同的应答内容
+func (s *auditSocket) query(typ uint16) ([]byte, error) {
+	seq, err := s.send(typ, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.wait(seq, typ)
+}
+
+// wait 等待指定序号的确认，reply 非 0 时还要等到该类型的应答（应答与确认的先后顺序不固定）
+func (s *auditSocket) wait(seq uint32, reply uint16) ([]byte, error) {
+	buf := make([]byte, 64*1024)
+	deadline := time.Now().Add(2 * time.Second)
+	s.file.SetReadDeadline(deadline)
+	defer s.file.SetReadDeadline(time.Time{})
+
+	var data []byte
+	acked := false
+	for !acked || (reply != 0 && data == nil) {
+		n, err := s.file.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range msgs {
+			if msg.Header.Seq != seq {
+				continue
+			}
+			switch msg.Header.Type {
+			case syscall.NLMSG_ERROR:
+				if len(msg.Data) < 4 {
+					return nil, fmt.Errorf("short netlink error")
+				}
+				if errno := int32(binary.LittleEndian.Uint32(msg.Data)); errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+				acked = true
+			case reply:
+				data = append([]byte(nil), msg.Data...)
+			}
+		}
+	}
+	return data, nil
+}
+
+// Close 关闭套接字
+func (s *auditSocket) Close() error {
+	return s.file.Close()
+}
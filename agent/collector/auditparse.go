@@ -0,0 +1,492 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 审计记录类型（linux/audit.h）
+const (
+	auditTypeSyscall   = 1300
+	auditTypePath      = 1302
+	auditTypeSockaddr  = 1306
+	auditTypeCwd       = 1307
+	auditTypeExecve    = 1309
+	auditTypeEOE       = 1320
+	auditTypeProctitle = 1327
+)
+
+// auditTypeNames 审计记录类型名称，与 audit.log 中的 type= 一致
+var auditTypeNames = map[uint16]string{
+	1006: "LOGIN",
+	1100: "USER_AUTH",
+	1101: "USER_ACCT",
+	1104: "CRED_ACQ",
+	1105: "USER_START",
+	1106: "USER_END",
+	1112: "USER_LOGIN",
+	1123: "USER_CMD",
+	1300: "SYSCALL",
+	1302: "PATH",
+	1303: "IPC",
+	1305: "CONFIG_CHANGE",
+	1306: "SOCKADDR",
+	1307: "CWD",
+	1309: "EXECVE",
+	1318: "FD_PAIR",
+	1320: "EOE",
+	1321: "BPRM_FCAPS",
+	1322: "CAPSET",
+	1323: "MMAP",
+	1325: "NETFILTER_CFG",
+	1326: "SECCOMP",
+	1327: "PROCTITLE",
+	1330: "KERN_MODULE",
+	1334: "BPF",
+	1337: "OPENAT2",
+	1400: "AVC",
+	1701: "ANOM_ABEND",
+}
+
+// auditTypeName 返回记录类型名称，未知类型与 auditd 一样显示为 UNKNOWN[n]
+func auditTypeName(typ uint16) string {
+	if name, ok := auditTypeNames[typ]; ok {
+		return name
+	}
+	return fmt.Sprintf("UNKNOWN[%d]", typ)
+}
+
+// auditStringFields 值为字符串的字段，未加引号时为十六进制编码（值中含空格或特殊字符）
+var auditStringFields = map[string]bool{
+	"comm": true, "exe": true, "name": true, "cwd": true, "key": true, "proctitle": true,
+	"cmd": true, "acct": true, "terminal": true, "hostname": true, "addr": true,
+}
+
+// AuditPath 审计事件涉及的文件
+type AuditPath struct {
+	Name     string `json:"name"`               // 路径
+	Nametype string `json:"nametype,omitempty"` // 类型（NORMAL/PARENT/CREATE/DELETE）
+	Inode    uint64 `json:"inode,omitempty"`    // inode
+	Mode     string `json:"mode,omitempty"`     // 文件类型与权限（八进制）
+	OUID     uint32 `json:"ouid"`               // 文件属主
+}
+
+// AuditEvent 由同一序号的多条审计记录（SYSCALL、EXECVE、PATH、CWD 等）合并得到的事件
+type AuditEvent struct {
+	Serial    uint64            `json:"serial"`            // 审计序号
+	Timestamp time.Time         `json:"timestamp"`         // 事件时间
+	Type      string            `json:"type"`              // 主记录类型
+	Key       string            `json:"key,omitempty"`     // 命中规则的 key
+	Syscall   string            `json:"syscall,omitempty"` // 系统调用名称
+	Success   bool              `json:"success"`           // 系统调用是否成功
+	Exit      int64             `json:"exit"`              // 系统调用返回值
+	PID       int               `json:"pid,omitempty"`     // 进程ID
+	PPID      int               `json:"ppid,omitempty"`    // 父进程ID
+	UID       uint32            `json:"uid"`               // 用户ID
+	EUID      uint32            `json:"euid"`              // 有效用户ID
+	AUID      uint32            `json:"auid"`              // 登录用户ID，未登录时为 4294967295
+	TTY       string            `json:"tty,omitempty"`     // 控制终端
+	Comm      string            `json:"comm,omitempty"`    // 进程名称
+	Exe       string            `json:"exe,omitempty"`     // 可执行文件路径
+	Cwd       string            `json:"cwd,omitempty"`     // 工作目录
+	Argv      []string          `json:"argv,omitempty"`    // execve 参数
+	Paths     []AuditPath       `json:"paths,omitempty"`   // 涉及的文件
+	Address   string            `json:"address,omitempty"` // connect/bind 等的套接字地址
+	Args      []string          `json:"args,omitempty"`    // 系统调用参数 a0-a3（十六进制）
+	Fields    map[string]string `json:"fields,omitempty"`  // 非系统调用事件的原始字段
+	Records   []string          `json:"records"`           // 组成事件的记录类型
+}
+
+// auditRecord 单条审计记录
+type auditRecord struct {
+	Type   uint16
+	Name   string
+	Serial uint64
+	Time   time.Time
+	Fields map[string]string
+}
+
+// parseAuditLine 解析 audit.log 中的一行，例如
+//
+//	type=SYSCALL msg=audit(1700000000.123:456): arch=c000003e syscall=59 success=yes ...
+func parseAuditLine(line string) (auditRecord, error) {
+	line = strings.TrimSpace(line)
+	// ENRICHED 格式在 0x1d 之后附加了解析后的用户名等字段
+	if i := strings.IndexByte(line, 0x1d); i >= 0 {
+		line = line[:i]
+	}
+
+	if !strings.HasPrefix(line, "type=") {
+		return auditRecord{}, fmt.Errorf("missing type: %q", line)
+	}
+	sp := strings.IndexByte(line, ' ')
+	if sp < 0 {
+		return auditRecord{}, fmt.Errorf("missing msg: %q", line)
+	}
+	name := line[len("type="):sp]
+	rest := strings.TrimSpace(line[sp:])
+	if !strings.HasPrefix(rest, "msg=") {
+		return auditRecord{}, fmt.Errorf("missing msg: %q", line)
+	}
+
+	var typ uint16
+	for t, n := range auditTypeNames {
+		if n == name {
+			typ = t
+			break
+		}
+	}
+	if typ == 0 && strings.HasPrefix(name, "UNKNOWN[") {
+		n, _ := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, "UNKNOWN["), "]"), 10, 16)
+		typ = uint16(n)
+	}
+
+	rec, err := parseAuditMessage(typ, rest[len("msg="):])
+	rec.Name = name
+	return rec, err
+}
+
+// ParseAuditLog 解析 auditd 写入的 audit.log，按序号合并记录后依次回调，返回无法解析的行数
+func ParseAuditLog(r io.Reader, handler func(AuditEvent)) (int, error) {
+	// 日志中的记录按时间顺序写入，不需要超时输出，读完后统一输出缺少 EOE 的事件
+	assembler := newAuditAssembler(0)
+	invalid := 0
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		rec, err := parseAuditLine(scanner.Text())
+		if err != nil {
+			invalid++
+			continue
+		}
+		if ev, ok := assembler.add(rec, time.Time{}); ok {
+			handler(ev)
+		}
+	}
+	for _, ev := range assembler.flush(time.Time{}, true) {
+		handler(ev)
+	}
+	return invalid, scanner.Err()
+}
+
+// parseAuditMessage 解析 netlink 收到的审计消息：audit(秒.毫秒:序号): key=value ...
+func parseAuditMessage(typ uint16, msg string) (auditRecord, error) {
+	rec := auditRecord{Type: typ, Name: auditTypeName(typ)}
+
+	msg = strings.TrimRight(msg, "\x00\n ")
+	if !strings.HasPrefix(msg, "audit(") {
+		return rec, fmt.Errorf("missing audit header: %q", msg)
+	}
+	end := strings.Index(msg, "):")
+	if end < 0 {
+		return rec, fmt.Errorf("malformed audit header: %q", msg)
+	}
+
+	stamp, serial, ok := strings.Cut(msg[len("audit("):end], ":")
+	if !ok {
+		return rec, fmt.Errorf("malformed audit header: %q", msg)
+	}
+	sec, frac, _ := strings.Cut(stamp, ".")
+	s, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return rec, fmt.Errorf("malformed audit time: %q", stamp)
+	}
+	// 内核固定输出 3 位毫秒，补齐位数以兼容其他来源
+	frac = (frac + "000000000")[:9]
+	ns, _ := strconv.ParseInt(frac, 10, 64)
+	rec.Time = time.Unix(s, ns)
+	if rec.Serial, err = strconv.ParseUint(serial, 10, 64); err != nil {
+		return rec, fmt.Errorf("malformed audit serial: %q", serial)
+	}
+
+	rec.Fields = parseAuditFields(typ, msg[end+2:])
+	return rec, nil
+}
+
+// parseAuditFields 解析 key=value 字段，值可能带双引号、单引号（用户态消息的 msg='...'）或为十六进制编码
+func parseAuditFields(typ uint16, s string) map[string]string {
+	fields := make(map[string]string)
+
+	for {
+		s = strings.TrimLeft(s, " ")
+		if s == "" {
+			return fields
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return fields
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+
+		var value string
+		switch {
+		case strings.HasPrefix(s, `"`):
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		case strings.HasPrefix(s, `'`):
+			// 用户态消息把真正的字段放在 msg='...' 中，展开到同一层
+			end := strings.IndexByte(s[1:], '\'')
+			inner := s[1:]
+			if end < 0 {
+				s = ""
+			} else {
+				inner, s = s[1:end+1], s[end+2:]
+			}
+			for k, v := range parseAuditFields(typ, inner) {
+				fields[k] = v
+			}
+			continue
+		default:
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				value, s = s, ""
+			} else {
+				value, s = s[:end], s[end:]
+			}
+			if isAuditStringField(typ, key) {
+				value = decodeAuditHex(value)
+			}
+		}
+		fields[key] = value
+	}
+}
+
+// isAuditStringField 判断字段是否为可能十六进制编码的字符串
+func isAuditStringField(typ uint16, key string) bool {
+	if auditStringFields[key] {
+		return true
+	}
+	// EXECVE 的参数 a0、a1 ...（SYSCALL 的 a0-a3 是十六进制数值，不解码）
+	if typ == auditTypeExecve && len(key) > 1 && key[0] == 'a' {
+		_, err := strconv.Atoi(key[1:])
+		return err == nil
+	}
+	return false
+}
+
+// decodeAuditHex 解码十六进制编码的字符串，(null) 表示空值，无法解码时原样返回
+func decodeAuditHex(value string) string {
+	if value == "(null)" || value == "(none)" {
+		return ""
+	}
+	if len(value)%2 != 0 {
+		return value
+	}
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		return value
+	}
+	// 命令行参数以 NUL 分隔（proctitle）
+	return strings.TrimRight(strings.ReplaceAll(string(decoded), "\x00", " "), " ")
+}
+
+// auditAssembler 按序号合并审计记录，收到 EOE 或超时后输出事件
+type auditAssembler struct {
+	maxAge  time.Duration
+	pending map[uint64][]auditRecord
+	first   map[uint64]time.Time // 首条记录的接收时间
+}
+
+// newAuditAssembler 创建审计记录合并器
+func newAuditAssembler(maxAge time.Duration) *auditAssembler {
+	return &auditAssembler{
+		maxAge:  maxAge,
+		pending: make(map[uint64][]auditRecord),
+		first:   make(map[uint64]time.Time),
+	}
+}
+
+// add 添加一条记录，事件完整时返回合并后的事件
+func (a *auditAssembler) add(rec auditRecord, now time.Time) (AuditEvent, bool) {
+	if rec.Type == auditTypeEOE {
+		records, ok := a.pending[rec.Serial]
+		if !ok {
+			return AuditEvent{}, false
+		}
+		delete(a.pending, rec.Serial)
+		delete(a.first, rec.Serial)
+		return buildAuditEvent(records), true
+	}
+
+	// 非系统调用事件（用户态消息、配置变更等）只有一条记录，没有 EOE
+	if _, ok := a.pending[rec.Serial]; !ok && rec.Type != auditTypeSyscall && !isAuditAuxRecord(rec.Type) {
+		return buildAuditEvent([]auditRecord{rec}), true
+	}
+
+	if _, ok := a.pending[rec.Serial]; !ok {
+		a.first[rec.Serial] = now
+	}
+	a.pending[rec.Serial] = append(a.pending[rec.Serial], rec)
+	return AuditEvent{}, false
+}
+
+// flush 输出超过 maxAge 仍未收到 EOE 的事件，force 为 true 时输出全部
+func (a *auditAssembler) flush(now time.Time, force bool) []AuditEvent {
+	var serials []uint64
+	for serial, at := range a.first {
+		if force || now.Sub(at) >= a.maxAge {
+			serials = append(serials, serial)
+		}
+	}
+	sort.Slice(serials, func(i, j int) bool { return serials[i] < serials[j] })
+
+	events := make([]AuditEvent, 0, len(serials))
+	for _, serial := range serials {
+		events = append(events, buildAuditEvent(a.pending[serial]))
+		delete(a.pending, serial)
+		delete(a.first, serial)
+	}
+	return events
+}
+
+// isAuditAuxRecord 判断是否为附属于系统调用事件的记录
+func isAuditAuxRecord(typ uint16) bool {
+	switch typ {
+	case auditTypePath, auditTypeSockaddr, auditTypeCwd, auditTypeExecve, auditTypeProctitle:
+		return true
+	}
+	// 1300-1399 为内核系统调用审计的附属记录
+	return typ > auditTypeSyscall && typ < 1400
+}
+
+// buildAuditEvent 将同一序号的记录合并为结构化事件
+func buildAuditEvent(records []auditRecord) AuditEvent {
+	ev := AuditEvent{Records: make([]string, 0, len(records))}
+	if len(records) == 0 {
+		return ev
+	}
+	ev.Serial = records[0].Serial
+	ev.Timestamp = records[0].Time
+	ev.Type = records[0].Name
+
+	for _, rec := range records {
+		ev.Records = append(ev.Records, rec.Name)
+		f := rec.Fields
+
+		switch rec.Type {
+		case auditTypeSyscall:
+			ev.Type = rec.Name
+			ev.Syscall = auditSyscallName(f["arch"], f["syscall"])
+			ev.Success = f["success"] == "yes"
+			ev.Exit, _ = strconv.ParseInt(f["exit"], 10, 64)
+			ev.PID, _ = strconv.Atoi(f["pid"])
+			ev.PPID, _ = strconv.Atoi(f["ppid"])
+			ev.UID = parseAuditID(f["uid"])
+			ev.EUID = parseAuditID(f["euid"])
+			ev.AUID = parseAuditID(f["auid"])
+			ev.TTY = noneToEmpty(f["tty"])
+			ev.Comm = f["comm"]
+			ev.Exe = f["exe"]
+			ev.Key = f["key"]
+			for i := 0; i < 4; i++ {
+				if v, ok := f["a"+strconv.Itoa(i)]; ok {
+					ev.Args = append(ev.Args, v)
+				}
+			}
+
+		case auditTypeExecve:
+			argc, _ := strconv.Atoi(f["argc"])
+			for i := 0; i < argc; i++ {
+				ev.Argv = append(ev.Argv, f["a"+strconv.Itoa(i)])
+			}
+
+		case auditTypeCwd:
+			ev.Cwd = f["cwd"]
+
+		case auditTypePath:
+			p := AuditPath{Name: f["name"], Nametype: f["nametype"], Mode: f["mode"], OUID: parseAuditID(f["ouid"])}
+			p.Inode, _ = strconv.ParseUint(f["inode"], 10, 64)
+			ev.Paths = append(ev.Paths, p)
+
+		case auditTypeSockaddr:
+			ev.Address = decodeSockaddr(f["saddr"])
+
+		case auditTypeProctitle:
+			// EXECVE 记录缺失时（例如非 execve 事件）用 proctitle 作为命令行
+			if len(ev.Argv) == 0 && f["proctitle"] != "" {
+				ev.Argv = strings.Fields(f["proctitle"])
+			}
+
+		default:
+			if len(records) == 1 {
+				ev.Fields = f
+				ev.PID, _ = strconv.Atoi(f["pid"])
+				ev.UID = parseAuditID(f["uid"])
+				ev.AUID = parseAuditID(f["auid"])
+				ev.Exe = f["exe"]
+				// CONFIG_CHANGE 的 key 是被修改规则的 key，不是命中的规则，只保留在 fields 中
+				ev.Success = f["res"] == "success" || f["res"] == "1"
+			}
+		}
+	}
+
+	return ev
+}
+
+// parseAuditID 解析 uid/auid 等字段，unset 表示未设置
+func parseAuditID(value string) uint32 {
+	if value == "" || value == "unset" {
+		return 4294967295
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 4294967295
+	}
+	return uint32(id)
+}
+
+// noneToEmpty 将 (none) 转换为空字符串
+func noneToEmpty(value string) string {
+	if value == "(none)" || value == "(null)" {
+		return ""
+	}
+	return value
+}
+
+// decodeSockaddr 解码 SOCKADDR 记录中十六进制的 sockaddr
+func decodeSockaddr(saddr string) string {
+	raw, err := hex.DecodeString(saddr)
+	if err != nil || len(raw) < 2 {
+		return ""
+	}
+
+	switch family := binary.LittleEndian.Uint16(raw); family {
+	case 1: // AF_UNIX
+		path := strings.TrimRight(string(raw[2:]), "\x00")
+		if strings.HasPrefix(path, "\x00") {
+			path = "@" + path[1:]
+		}
+		return "unix:" + path
+	case 2: // AF_INET
+		if len(raw) < 8 {
+			return ""
+		}
+		port := binary.BigEndian.Uint16(raw[2:])
+		return net.JoinHostPort(net.IP(raw[4:8]).String(), strconv.Itoa(int(port)))
+	case 10: // AF_INET6
+		if len(raw) < 24 {
+			return ""
+		}
+		port := binary.BigEndian.Uint16(raw[2:])
+		return net.JoinHostPort(net.IP(raw[8:24]).String(), strconv.Itoa(int(port)))
+	default:
+		return fmt.Sprintf("family:%d", family)
+	}
+}
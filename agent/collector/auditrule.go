@@ -0,0 +1,493 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// 审计规则常量（linux/audit.h）
+const (
+	auditFilterUser    = 0x00
+	auditFilterTask    = 0x01
+	auditFilterExit    = 0x04
+	auditFilterExclude = 0x05
+
+	auditNever  = 0
+	auditAlways = 2
+
+	auditMaxFields   = 64
+	auditBitmaskSize = 64
+	auditMaxKeyLen   = 256
+
+	auditArchX86_64  = 0xc000003e
+	auditArchAArch64 = 0xc00000b7
+)
+
+// 规则比较运算符
+const (
+	auditBitMask     = 0x08000000
+	auditLessThan    = 0x10000000
+	auditGreaterThan = 0x20000000
+	auditNotEqual    = 0x30000000
+	auditEqual       = 0x40000000
+	auditBitTest     = auditBitMask | auditEqual
+	auditLessEqual   = auditLessThan | auditEqual
+	auditGreaterEq   = auditGreaterThan | auditEqual
+)
+
+// 规则字段
+const (
+	auditFieldWatch     = 105
+	auditFieldPerm      = 106
+	auditFieldDir       = 107
+	auditFieldExe       = 112
+	auditFieldFilterKey = 210
+)
+
+// 监控权限（-p rwxa）
+const (
+	auditPermExec  = 1
+	auditPermWrite = 2
+	auditPermRead  = 4
+	auditPermAttr  = 8
+)
+
+// auditFields -F 支持的字段，值为字段编号和是否为字符串
+var auditFields = map[string]struct {
+	id  uint32
+	str bool
+}{
+	"pid": {0, false}, "uid": {1, false}, "euid": {2, false}, "suid": {3, false}, "fsuid": {4, false},
+	"gid": {5, false}, "egid": {6, false}, "sgid": {7, false}, "fsgid": {8, false},
+	"auid": {9, false}, "loginuid": {9, false}, "pers": {10, false}, "arch": {11, false},
+	"msgtype": {12, false}, "ppid": {18, false}, "sessionid": {25, false},
+	"devmajor": {100, false}, "devminor": {101, false}, "inode": {102, false},
+	"exit": {103, false}, "success": {104, false},
+	"path": {auditFieldWatch, true}, "dir": {auditFieldDir, true}, "perm": {auditFieldPerm, false},
+	"obj_uid": {109, false}, "obj_gid": {110, false}, "exe": {auditFieldExe, true},
+	"a0": {200, false}, "a1": {201, false}, "a2": {202, false}, "a3": {203, false},
+	"key": {auditFieldFilterKey, true},
+}
+
+// auditOperators 比较运算符，较长的放在前面以便按前缀匹配
+var auditOperators = []struct {
+	text string
+	op   uint32
+}{
+	{"!=", auditNotEqual}, {">=", auditGreaterEq}, {"<=", auditLessEqual}, {"&=", auditBitTest},
+	{"=", auditEqual}, {">", auditGreaterThan}, {"<", auditLessThan}, {"&", auditBitMask},
+}
+
+// auditErrnos 规则中可以按名称使用的错误码（-F exit=-EACCES）
+var auditErrnos = map[string]int64{
+	"EPERM": 1, "ENOENT": 2, "EINTR": 4, "EACCES": 13, "EEXIST": 17, "EINVAL": 22, "ENOSYS": 38,
+}
+
+// auditRule 编码后的审计规则（struct audit_rule_data）
+type auditRule struct {
+	text       string
+	flags      uint32
+	action     uint32
+	mask       [auditBitmaskSize]uint32
+	fields     []uint32
+	values     []uint32
+	fieldflags []uint32
+	buf        []byte
+}
+
+// parseAuditRule 解析 auditctl 语法的规则，支持
+//
+//	-a always,exit [-F arch=b64] -S execve,connect -F auid>=1000 -k key
+//	-w /etc/passwd -p wa -k key
+//
+// 系统调用名称按代理所在平台解释，只支持本机架构（b64），带 -S 而未指定 arch 时自动限定为本机架构。
+func parseAuditRule(text string) (*auditRule, error) {
+	rule := &auditRule{text: text}
+	args := strings.Fields(text)
+	if len(args) < 2 {
+		return nil, fmt.Errorf("incomplete rule")
+	}
+
+	var watch, perm string
+	var listed, syscalls bool
+
+	for i := 0; i < len(args); i++ {
+		opt := args[i]
+		if i+1 >= len(args) {
+			return nil, fmt.Errorf("option %s requires a value", opt)
+		}
+		i++
+		value := args[i]
+
+		switch opt {
+		case "-a", "-A":
+			if err := rule.setList(value); err != nil {
+				return nil, err
+			}
+			listed = true
+		case "-w":
+			watch = value
+		case "-p":
+			perm = value
+		case "-S":
+			for _, name := range strings.Split(value, ",") {
+				if err := rule.addSyscall(name); err != nil {
+					return nil, err
+				}
+			}
+			syscalls = true
+		case "-F":
+			if err := rule.addField(value); err != nil {
+				return nil, err
+			}
+		case "-k":
+			if err := rule.addString(auditFieldFilterKey, auditEqual, value); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported option %s", opt)
+		}
+	}
+
+	if watch != "" {
+		if listed || syscalls {
+			return nil, fmt.Errorf("-w cannot be combined with -a or -S")
+		}
+		return rule, rule.setWatch(watch, perm)
+	}
+	if perm != "" {
+		return nil, fmt.Errorf("-p requires -w")
+	}
+	if !listed {
+		return nil, fmt.Errorf("rule requires -a or -w")
+	}
+	if rule.flags != auditFilterExit {
+		if syscalls {
+			return nil, fmt.Errorf("-S is only supported on the exit list")
+		}
+		return rule, nil
+	}
+
+	// 没有 -S 的 exit 规则匹配所有系统调用；系统调用号与架构相关，未指定 arch 时限定为本机架构
+	if !syscalls {
+		rule.addSyscall("all")
+	} else if !rule.hasField(auditFields["arch"].id) {
+		arch, err := auditArchValue("b64")
+		if err != nil {
+			return nil, err
+		}
+		if err := rule.addValue(auditFields["arch"].id, auditEqual, arch); err != nil {
+			return nil, err
+		}
+	}
+	return rule, nil
+}
+
+// setList 解析 -a 的过滤列表和动作，两者顺序不限
+func (r *auditRule) setList(value string) error {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid list,action %q", value)
+	}
+
+	var listSet, actionSet bool
+	for _, part := range parts {
+		switch part {
+		case "exit":
+			r.flags, listSet = auditFilterExit, true
+		case "task":
+			r.flags, listSet = auditFilterTask, true
+		case "user":
+			r.flags, listSet = auditFilterUser, true
+		case "exclude":
+			r.flags, listSet = auditFilterExclude, true
+		case "always":
+			r.action, actionSet = auditAlways, true
+		case "never":
+			r.action, actionSet = auditNever, true
+		default:
+			return fmt.Errorf("invalid list,action %q", value)
+		}
+	}
+	if !listSet || !actionSet {
+		return fmt.Errorf("invalid list,action %q", value)
+	}
+	return nil
+}
+
+// setWatch 将 -w 规则转换为 exit 列表上的路径规则
+func (r *auditRule) setWatch(path, perm string) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("watch path must be absolute: %s", path)
+	}
+	r.flags = auditFilterExit
+	r.action = auditAlways
+	r.addSyscall("all")
+
+	// 以 / 结尾的路径监控整个目录树
+	field := uint32(auditFieldWatch)
+	if strings.HasSuffix(path, "/") && len(path) > 1 {
+		field = auditFieldDir
+		path = strings.TrimRight(path, "/")
+	}
+	if err := r.addString(field, auditEqual, path); err != nil {
+		return err
+	}
+
+	if perm == "" {
+		perm = "rwxa"
+	}
+	var mask uint32
+	for _, c := range perm {
+		switch c {
+		case 'r':
+			mask |= auditPermRead
+		case 'w':
+			mask |= auditPermWrite
+		case 'x':
+			mask |= auditPermExec
+		case 'a':
+			mask |= auditPermAttr
+		default:
+			return fmt.Errorf("invalid permission %q", perm)
+		}
+	}
+	return r.addValue(auditFieldPerm, auditEqual, mask)
+}
+
+// hasField 判断规则是否已包含字段
+func (r *auditRule) hasField(field uint32) bool {
+	for _, f := range r.fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// addSyscall 将系统调用加入规则的掩码，all 表示全部
+func (r *auditRule) addSyscall(name string) error {
+	if name == "all" {
+		for i := range r.mask {
+			r.mask[i] = ^uint32(0)
+		}
+		return nil
+	}
+
+	nr, ok := auditSyscallNumber(name)
+	if !ok {
+		return fmt.Errorf("unknown syscall %q on %s", name, runtime.GOARCH)
+	}
+	if nr >= auditBitmaskSize*32 {
+		return fmt.Errorf("syscall %q out of range", name)
+	}
+	r.mask[nr/32] |= 1 << (nr % 32)
+	return nil
+}
+
+// addField 解析 -F field<op>value
+func (r *auditRule) addField(expr string) error {
+	var name, value string
+	var op uint32
+	for _, o := range auditOperators {
+		if i := strings.Index(expr, o.text); i > 0 {
+			name, value, op = expr[:i], expr[i+len(o.text):], o.op
+			break
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("invalid field expression %q", expr)
+	}
+
+	field, ok := auditFields[name]
+	if !ok {
+		return fmt.Errorf("unsupported field %q", name)
+	}
+	if field.str {
+		if op != auditEqual && op != auditNotEqual {
+			return fmt.Errorf("field %q only supports = and !=", name)
+		}
+		return r.addString(field.id, op, value)
+	}
+
+	switch name {
+	case "arch":
+		arch, err := auditArchValue(value)
+		if err != nil {
+			return err
+		}
+		return r.addValue(field.id, op, arch)
+	case "perm":
+		return fmt.Errorf("use -p to set permissions")
+	}
+
+	n, err := parseAuditValue(value)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+	return r.addValue(field.id, op, uint32(n))
+}
+
+// parseAuditValue 解析数值，支持负数、十六进制、unset 和 -EACCES 等错误码名称
+func parseAuditValue(value string) (int64, error) {
+	if value == "unset" {
+		return 4294967295, nil
+	}
+	if strings.HasPrefix(value, "-E") {
+		if errno, ok := auditErrnos[value[1:]]; ok {
+			return -errno, nil
+		}
+	}
+	if n, err := strconv.ParseInt(value, 0, 64); err == nil {
+		return n, nil
+	}
+	n, err := strconv.ParseUint(value, 0, 32)
+	return int64(n), err
+}
+
+// auditArchValue 将 b64、b32 或十六进制架构值转换为 AUDIT_ARCH_*
+func auditArchValue(value string) (uint32, error) {
+	native, ok := auditNativeArch()
+	if !ok {
+		return 0, fmt.Errorf("audit rules are not supported on %s", runtime.GOARCH)
+	}
+	switch value {
+	case "b64", runtime.GOARCH:
+		return native, nil
+	case "b32":
+		return 0, fmt.Errorf("32-bit syscall rules are not supported")
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 32)
+	if err != nil || uint32(n) != native {
+		return 0, fmt.Errorf("unsupported arch %q", value)
+	}
+	return native, nil
+}
+
+// addValue 添加数值字段
+func (r *auditRule) addValue(field, op, value uint32) error {
+	if len(r.fields) >= auditMaxFields {
+		return fmt.Errorf("too many fields")
+	}
+	r.fields = append(r.fields, field)
+	r.values = append(r.values, value)
+	r.fieldflags = append(r.fieldflags, op)
+	return nil
+}
+
+// addString 添加字符串字段，值为字符串长度，内容追加到 buf
+func (r *auditRule) addString(field, op uint32, value string) error {
+	if value == "" {
+		return fmt.Errorf("empty value for field %d", field)
+	}
+	if field == auditFieldFilterKey && len(value) > auditMaxKeyLen {
+		return fmt.Errorf("key longer than %d bytes", auditMaxKeyLen)
+	}
+	if err := r.addValue(field, op, uint32(len(value))); err != nil {
+		return err
+	}
+	r.buf = append(r.buf, value...)
+	return nil
+}
+
+// marshal 编码为 struct audit_rule_data
+func (r *auditRule) marshal() []byte {
+	var fields, values, fieldflags [auditMaxFields]uint32
+	copy(fields[:], r.fields)
+	copy(values[:], r.values)
+	copy(fieldflags[:], r.fieldflags)
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, r.flags)
+	binary.Write(&b, binary.LittleEndian, r.action)
+	binary.Write(&b, binary.LittleEndian, uint32(len(r.fields)))
+	binary.Write(&b, binary.LittleEndian, r.mask)
+	binary.Write(&b, binary.LittleEndian, fields)
+	binary.Write(&b, binary.LittleEndian, values)
+	binary.Write(&b, binary.LittleEndian, fieldflags)
+	binary.Write(&b, binary.LittleEndian, uint32(len(r.buf)))
+	b.Write(r.buf)
+	return b.Bytes()
+}
+
+// auditNativeArch 返回本机的 AUDIT_ARCH_* 值
+func auditNativeArch() (uint32, bool) {
+	switch runtime.GOARCH {
+	case "amd64":
+		return auditArchX86_64, true
+	case "arm64":
+		return auditArchAArch64, true
+	}
+	return 0, false
+}
+
+// auditSyscallNumber 按本机架构查找系统调用号，也接受数字
+func auditSyscallNumber(name string) (int, bool) {
+	if nr, err := strconv.Atoi(name); err == nil && nr >= 0 {
+		return nr, true
+	}
+	nr, ok := auditSyscalls[runtime.GOARCH][name]
+	return nr, ok
+}
+
+// auditSyscallName 根据审计记录中的 arch 和 syscall 字段查找系统调用名称，未知时返回编号
+func auditSyscallName(arch, syscall string) string {
+	var goarch string
+	switch strings.ToLower(arch) {
+	case "c000003e":
+		goarch = "amd64"
+	case "c00000b7":
+		goarch = "arm64"
+	}
+	nr, err := strconv.Atoi(syscall)
+	if err != nil {
+		return syscall
+	}
+	for name, n := range auditSyscalls[goarch] {
+		if n == nr {
+			return name
+		}
+	}
+	return syscall
+}
+
+// auditSyscalls 规则和事件中常用的系统调用号
+var auditSyscalls = map[string]map[string]int{
+	"amd64": {
+		"read": 0, "write": 1, "open": 2, "close": 3, "mmap": 9, "mprotect": 10, "ioctl": 16,
+		"socket": 41, "connect": 42, "accept": 43, "sendto": 44, "recvfrom": 45, "bind": 49, "listen": 50,
+		"clone": 56, "fork": 57, "vfork": 58, "execve": 59, "exit": 60, "kill": 62,
+		"truncate": 76, "ftruncate": 77, "rename": 82, "mkdir": 83, "rmdir": 84, "creat": 85,
+		"link": 86, "unlink": 87, "symlink": 88, "chmod": 90, "fchmod": 91,
+		"chown": 92, "fchown": 93, "lchown": 94, "ptrace": 101,
+		"setuid": 105, "setgid": 106, "setpgid": 109, "setreuid": 113, "setregid": 114,
+		"setresuid": 117, "setresgid": 119, "setfsuid": 122, "setfsgid": 123,
+		"mknod": 133, "personality": 135, "prctl": 157, "chroot": 161, "settimeofday": 164,
+		"mount": 165, "umount2": 166, "sethostname": 170, "setdomainname": 171,
+		"init_module": 175, "delete_module": 176, "clock_settime": 227, "kexec_load": 246,
+		"openat": 257, "mkdirat": 258, "fchownat": 260, "unlinkat": 263, "renameat": 264,
+		"linkat": 265, "symlinkat": 266, "fchmodat": 268, "unshare": 272, "accept4": 288,
+		"open_by_handle_at": 304, "setns": 308, "process_vm_readv": 310, "process_vm_writev": 311,
+		"finit_module": 313, "renameat2": 316, "memfd_create": 319, "kexec_file_load": 320,
+		"bpf": 321, "execveat": 322, "pidfd_open": 434, "openat2": 437,
+	},
+	"arm64": {
+		"ioctl": 29, "mknodat": 33, "mkdirat": 34, "unlinkat": 35, "symlinkat": 36, "linkat": 37,
+		"renameat": 38, "umount2": 39, "mount": 40, "truncate": 45, "ftruncate": 46,
+		"chroot": 51, "fchmod": 52, "fchmodat": 53, "fchownat": 54, "fchown": 55, "openat": 56,
+		"close": 57, "read": 63, "write": 64, "personality": 92, "exit": 93, "unshare": 97,
+		"kexec_load": 104, "init_module": 105, "delete_module": 106, "clock_settime": 112,
+		"ptrace": 117, "kill": 129, "setregid": 143, "setgid": 144, "setreuid": 145, "setuid": 146,
+		"setresuid": 147, "setresgid": 149, "setfsuid": 151, "setfsgid": 152, "setpgid": 154,
+		"sethostname": 161, "setdomainname": 162, "prctl": 167, "settimeofday": 170,
+		"socket": 198, "bind": 200, "listen": 201, "accept": 202, "connect": 203,
+		"sendto": 206, "recvfrom": 207, "clone": 220, "execve": 221, "mmap": 222, "mprotect": 226,
+		"accept4": 242, "open_by_handle_at": 265, "setns": 268, "process_vm_readv": 270,
+		"process_vm_writev": 271, "finit_module": 273, "renameat2": 276, "memfd_create": 279,
+		"bpf": 280, "execveat": 281, "kexec_file_load": 294, "pidfd_open": 434, "openat2": 437,
+	},
+}
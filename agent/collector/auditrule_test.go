@@ -0,0 +1,192 @@
+package collector
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// auditTestField 规则中的一个字段
+type auditTestField struct {
+	field, op, value uint32
+}
+
+func TestParseAuditRule(t *testing.T) {
+	native, ok := auditNativeArch()
+	if !ok {
+		t.Skip("audit rules are not supported on this architecture")
+	}
+	nr := func(name string) int {
+		n, ok := auditSyscallNumber(name)
+		if !ok {
+			t.Fatalf("unknown syscall %s", name)
+		}
+		return n
+	}
+	arch := auditFields["arch"].id
+
+	tests := []struct {
+		name     string
+		text     string
+		flags    uint32
+		action   uint32
+		syscalls []string // 掩码中应设置的系统调用，nil 表示全部
+		fields   []auditTestField
+		buf      string
+	}{
+		{
+			name:     "syscalls get native arch",
+			text:     "-a always,exit -S execve,execveat -k exec",
+			flags:    auditFilterExit,
+			action:   auditAlways,
+			syscalls: []string{"execve", "execveat"},
+			fields: []auditTestField{
+				{auditFieldFilterKey, auditEqual, 4},
+				{arch, auditEqual, native},
+			},
+			buf: "exec",
+		},
+		{
+			name:     "explicit arch and comparisons",
+			text:     "-a exit,always -F arch=b64 -S connect -F auid>=1000 -F auid!=unset -F exit=-EACCES -k net",
+			flags:    auditFilterExit,
+			action:   auditAlways,
+			syscalls: []string{"connect"},
+			fields: []auditTestField{
+				{arch, auditEqual, native},
+				{auditFields["auid"].id, auditGreaterEq, 1000},
+				{auditFields["auid"].id, auditNotEqual, 4294967295},
+				{auditFields["exit"].id, auditEqual, uint32(0xfffffff3)},
+				{auditFieldFilterKey, auditEqual, 3},
+			},
+			buf: "net",
+		},
+		{
+			name:   "exit rule without syscalls matches all",
+			text:   "-a never,exit -F exe=/usr/bin/auditctl",
+			flags:  auditFilterExit,
+			action: auditNever,
+			fields: []auditTestField{
+				{auditFieldExe, auditEqual, 17},
+			},
+			buf: "/usr/bin/auditctl",
+		},
+		{
+			name:   "file watch",
+			text:   "-w /etc/passwd -p wa -k identity",
+			flags:  auditFilterExit,
+			action: auditAlways,
+			// -w 在解析完全部选项后才转换，key 排在路径之前
+			fields: []auditTestField{
+				{auditFieldFilterKey, auditEqual, 8},
+				{auditFieldWatch, auditEqual, 11},
+				{auditFieldPerm, auditEqual, auditPermWrite | auditPermAttr},
+			},
+			buf: "identity" + "/etc/passwd",
+		},
+		{
+			name:   "directory watch defaults to rwxa",
+			text:   "-w /etc/sudoers.d/",
+			flags:  auditFilterExit,
+			action: auditAlways,
+			fields: []auditTestField{
+				{auditFieldDir, auditEqual, 14},
+				{auditFieldPerm, auditEqual, auditPermRead | auditPermWrite | auditPermExec | auditPermAttr},
+			},
+			buf: "/etc/sudoers.d",
+		},
+		{
+			name:     "exclude list",
+			text:     "-a always,exclude -F msgtype=1325",
+			flags:    auditFilterExclude,
+			action:   auditAlways,
+			syscalls: []string{},
+			fields: []auditTestField{
+				{auditFields["msgtype"].id, auditEqual, 1325},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := parseAuditRule(tt.text)
+			if err != nil {
+				t.Fatalf("parseAuditRule(%q): %v", tt.text, err)
+			}
+			if rule.flags != tt.flags || rule.action != tt.action {
+				t.Errorf("flags/action = %d/%d, want %d/%d", rule.flags, rule.action, tt.flags, tt.action)
+			}
+
+			var wantMask [auditBitmaskSize]uint32
+			if tt.syscalls == nil {
+				for i := range wantMask {
+					wantMask[i] = ^uint32(0)
+				}
+			}
+			for _, name := range tt.syscalls {
+				n := nr(name)
+				wantMask[n/32] |= 1 << (n % 32)
+			}
+			if rule.mask != wantMask {
+				t.Errorf("syscall mask does not match %v", tt.syscalls)
+			}
+
+			var got []auditTestField
+			for i := range rule.fields {
+				got = append(got, auditTestField{rule.fields[i], rule.fieldflags[i], rule.values[i]})
+			}
+			if !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("fields = %v, want %v", got, tt.fields)
+			}
+			if string(rule.buf) != tt.buf {
+				t.Errorf("buf = %q, want %q", rule.buf, tt.buf)
+			}
+
+			// struct audit_rule_data: 3 个 uint32、掩码、3 个字段数组、buflen，之后是字符串
+			b := rule.marshal()
+			header := 4*3 + 4*auditBitmaskSize + 4*auditMaxFields*3 + 4
+			if len(b) != header+len(tt.buf) {
+				t.Fatalf("marshal length = %d, want %d", len(b), header+len(tt.buf))
+			}
+			if n := binary.LittleEndian.Uint32(b[8:]); n != uint32(len(tt.fields)) {
+				t.Errorf("marshal field_count = %d, want %d", n, len(tt.fields))
+			}
+			if n := binary.LittleEndian.Uint32(b[header-4:]); n != uint32(len(tt.buf)) {
+				t.Errorf("marshal buflen = %d, want %d", n, len(tt.buf))
+			}
+		})
+	}
+}
+
+func TestParseAuditRuleErrors(t *testing.T) {
+	if _, ok := auditNativeArch(); !ok {
+		t.Skip("audit rules are not supported on this architecture")
+	}
+
+	tests := []string{
+		"",
+		"-a always,exit -S",
+		"-a always -S execve",
+		"-a always,sometimes -S execve",
+		"-a exit,task -S execve",
+		"-a always,exit -S nosuchsyscall",
+		"-a always,exit -F arch=b32 -S execve",
+		"-a always,exit -F nosuchfield=1",
+		"-a always,exit -F key>foo",
+		"-a always,exit -F perm=wa",
+		"-a always,exit -F auid",
+		"-a always,task -S execve",
+		"-w etc/passwd -p wa",
+		"-w /etc/passwd -p z",
+		"-w /etc/passwd -S open",
+		"-p wa -k identity",
+		"-k only",
+		"-a always,exit -X foo",
+	}
+
+	for _, text := range tests {
+		if _, err := parseAuditRule(text); err == nil {
+			t.Errorf("parseAuditRule(%q) succeeded, want error", text)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package collector
+
+import (
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 认证事件类型
+const (
+	AuthLogin          = "login"            // SSH 登录成功或失败
+	AuthInvalidUser    = "invalid_user"     // SSH 尝试不存在的用户
+	AuthSudo           = "sudo"             // sudo 执行命令或认证失败
+	AuthSu             = "su"               // su 切换用户
+	AuthUserAdd        = "user_add"         // 新建用户
+	AuthUserDelete     = "user_delete"      // 删除用户
+	AuthGroupAdd       = "group_add"        // 新建用户组
+	AuthGroupMemberAdd = "group_member_add" // 用户加入用户组
+	AuthPasswordChange = "password_change"  // 修改密码
+	AuthBruteForce     = "brute_force"      // 同一来源在时间窗口内登录失败次数超过阈值
+)
+
+// authLogPollInterval 认证日志的轮询间隔
+const authLogPollInterval = time.Second
+
+// maxBruteForceSources 暴力破解检测跟踪的来源数量上限
+const maxBruteForceSources = 10000
+
+// maxBruteForceUsers 暴力破解事件中记录的用户名数量上限
+const maxBruteForceUsers = 20
+
+// AuthEvent 从认证日志（auth.log/secure）解析出的事件
+type AuthEvent struct {
+	Type        string    `json:"type"`                   // 事件类型
+	Service     string    `json:"service,omitempty"`      // 记录日志的程序（sshd/sudo/su/useradd 等）
+	User        string    `json:"user,omitempty"`         // 登录或执行操作的用户
+	TargetUser  string    `json:"target_user,omitempty"`  // sudo/su 切换到的用户，新建/删除/改密的用户
+	Group       string    `json:"group,omitempty"`        // 新建或加入的用户组
+	SourceIP    string    `json:"source_ip,omitempty"`    // 登录来源地址
+	Port        int       `json:"port,omitempty"`         // 登录来源端口
+	Method      string    `json:"method,omitempty"`       // 认证方式（password/publickey/keyboard-interactive 等）
+	Success     bool      `json:"success"`                // 是否成功
+	Reason      string    `json:"reason,omitempty"`       // 失败原因（sudo）
+	InvalidUser bool      `json:"invalid_user,omitempty"` // 用户不存在
+	TTY         string    `json:"tty,omitempty"`          // 终端
+	Command     string    `json:"command,omitempty"`      // sudo 执行的命令
+	Count       int       `json:"count,omitempty"`        // 合并的重复日志条数；暴力破解事件为窗口内的失败次数
+	Failures    int       `json:"failures,omitempty"`     // 登录成功时该来源在窗口内的失败次数
+	Users       []string  `json:"users,omitempty"`        // 暴力破解尝试过的用户名
+	Window      int       `json:"window,omitempty"`       // 暴力破解检测窗口（秒）
+	Hostname    string    `json:"hostname,omitempty"`     // 日志中的主机名
+	PID         int       `json:"pid,omitempty"`          // 记录日志的进程ID
+	Message     string    `json:"message,omitempty"`      // 原始日志内容
+	Log         string    `json:"log,omitempty"`          // 日志文件
+	Timestamp   time.Time `json:"timestamp"`              // 日志时间
+}
+
+var (
+	sshdLoginRe    = regexp.MustCompile(`^(Accepted|Failed) (\S+) for (invalid user )?(.*?) from (\S+) port (\d+)`)
+	sshdInvalidRe  = regexp.MustCompile(`^Invalid user (.*?) from (\S+)(?: port (\d+))?`)
+	repeatedRe     = regexp.MustCompile(`^message repeated (\d+) times: \[ ?(.*?)\]$`)
+	suUtilLinuxRe  = regexp.MustCompile(`^(FAILED SU )?\(to (\S+)\) (\S+) on (\S+)$`)
+	suShadowRe     = regexp.MustCompile(`^(Successful|FAILED) su for (\S+) by (\S+)$`)
+	newUserRe      = regexp.MustCompile(`^new user: name=([^,]+),.*?(?:from=(\S+))?$`)
+	newGroupRe     = regexp.MustCompile(`^new group: name=([^,]+)`)
+	deleteUserRe   = regexp.MustCompile(`^delete user '([^']+)'`)
+	groupMemberRe  = regexp.MustCompile(`^add '([^']+)' to group '([^']+)'`)
+	passwdChangeRe = regexp.MustCompile(`password changed for (\S+)`)
+)
+
+// parseAuthLine 解析一行认证日志，不关心的日志返回 false
+//
+// 支持传统 syslog 时间（Oct 16 23:17:13，年份按 now 推断）和 RFC3339 时间（rsyslog 高精度格式）。
+func parseAuthLine(line string, now time.Time) (AuthEvent, bool) {
+	ts, host, prog, pid, msg, ok := parseSyslogLine(line, now)
+	if !ok {
+		return AuthEvent{}, false
+	}
+
+	ev := AuthEvent{Service: prog, Hostname: host, PID: pid, Message: msg, Timestamp: ts, Count: 1}
+
+	// rsyslog 合并的重复日志
+	if m := repeatedRe.FindStringSubmatch(msg); m != nil {
+		ev.Count, _ = strconv.Atoi(m[1])
+		msg = m[2]
+	}
+
+	var parsed bool
+	switch prog {
+	case "sshd":
+		parsed = parseSSHD(&ev, msg)
+	case "sudo":
+		parsed = parseSudo(&ev, msg)
+	case "su":
+		parsed = parseSu(&ev, msg)
+	case "useradd", "adduser", "groupadd", "userdel", "usermod", "gpasswd", "passwd", "chpasswd":
+		parsed = parseAccountChange(&ev, msg)
+	}
+	if !parsed {
+		return AuthEvent{}, false
+	}
+	if ev.Count == 1 {
+		ev.Count = 0
+	}
+	return ev, true
+}
+
+// parseSyslogLine 拆分 syslog 行：时间、主机名、程序名、PID 和消息
+func parseSyslogLine(line string, now time.Time) (ts time.Time, host, prog string, pid int, msg string, ok bool) {
+	var rest string
+	if len(line) > 16 && line[3] == ' ' && line[15] == ' ' {
+		t, err := time.ParseInLocation(time.Stamp, line[:15], now.Location())
+		if err != nil {
+			return
+		}
+		// 传统格式没有年份，跨年时日志时间会比当前时间晚
+		ts = t.AddDate(now.Year(), 0, 0)
+		if ts.After(now.Add(24 * time.Hour)) {
+			ts = ts.AddDate(-1, 0, 0)
+		}
+		rest = line[16:]
+	} else {
+		stamp, after, found := strings.Cut(line, " ")
+		if !found {
+			return
+		}
+		t, err := time.Parse(time.RFC3339Nano, stamp)
+		if err != nil {
+			return
+		}
+		ts, rest = t, after
+	}
+
+	host, rest, found := strings.Cut(rest, " ")
+	if !found {
+		return
+	}
+	tag, msg, found := strings.Cut(rest, ": ")
+	if !found {
+		return
+	}
+	prog = tag
+	if i := strings.IndexByte(tag, '['); i >= 0 && strings.HasSuffix(tag, "]") {
+		prog = tag[:i]
+		pid, _ = strconv.Atoi(tag[i+1 : len(tag)-1])
+	}
+	if i := strings.LastIndexByte(prog, '/'); i >= 0 {
+		prog = prog[i+1:]
+	}
+	// OpenSSH 9.8 起会话由 sshd-session 进程记录
+	if prog == "sshd-session" || prog == "sshd-auth" {
+		prog = "sshd"
+	}
+	return ts, host, prog, pid, strings.TrimSpace(msg), true
+}
+
+// parseSSHD 解析 sshd 的登录成功、失败和无效用户日志
+func parseSSHD(ev *AuthEvent, msg string) bool {
+	if m := sshdLoginRe.FindStringSubmatch(msg); m != nil {
+		ev.Type = AuthLogin
+		ev.Success = m[1] == "Accepted"
+		ev.Method, _, _ = strings.Cut(m[2], "/") // keyboard-interactive/pam
+		ev.InvalidUser = m[3] != ""
+		ev.User = m[4]
+		ev.SourceIP = m[5]
+		ev.Port, _ = strconv.Atoi(m[6])
+		return true
+	}
+	if m := sshdInvalidRe.FindStringSubmatch(msg); m != nil {
+		ev.Type = AuthInvalidUser
+		ev.InvalidUser = true
+		ev.User = m[1]
+		ev.SourceIP = m[2]
+		ev.Port, _ = strconv.Atoi(m[3])
+		return true
+	}
+	return false
+}
+
+// parseSudo 解析 sudo 的命令日志，例如
+//
+//	alice : TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/usr/bin/id
+//	alice : 3 incorrect password attempts ; TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/usr/bin/id
+func parseSudo(ev *AuthEvent, msg string) bool {
+	user, rest, found := strings.Cut(msg, " : ")
+	if !found {
+		return false
+	}
+
+	var reasons []string
+	for _, part := range strings.Split(rest, " ; ") {
+		key, value, found := strings.Cut(part, "=")
+		if !found || strings.ContainsAny(key, " ") {
+			reasons = append(reasons, strings.TrimSpace(part))
+			continue
+		}
+		switch key {
+		case "TTY":
+			// 没有终端时为 unknown
+			if value != "unknown" {
+				ev.TTY = value
+			}
+		case "USER":
+			ev.TargetUser = value
+		case "COMMAND":
+			ev.Command = value
+		}
+	}
+	if ev.Command == "" {
+		return false
+	}
+
+	ev.Type = AuthSudo
+	ev.User = strings.TrimSpace(user)
+	ev.Reason = strings.Join(reasons, "; ")
+	ev.Success = ev.Reason == ""
+	if ev.TargetUser == "" {
+		ev.TargetUser = "root"
+	}
+	return true
+}
+
+// parseSu 解析 su 的切换日志，兼容 util-linux 和 shadow 两种实现
+func parseSu(ev *AuthEvent, msg string) bool {
+	if m := suUtilLinuxRe.FindStringSubmatch(msg); m != nil {
+		ev.Type = AuthSu
+		ev.Success = m[1] == ""
+		ev.TargetUser = m[2]
+		ev.User = m[3]
+		ev.TTY = m[4]
+		return true
+	}
+	if m := suShadowRe.FindStringSubmatch(msg); m != nil {
+		ev.Type = AuthSu
+		ev.Success = m[1] == "Successful"
+		ev.TargetUser = m[2]
+		ev.User = m[3]
+		return true
+	}
+	return false
+}
+
+// parseAccountChange 解析 useradd/userdel/usermod/passwd 等账号变更日志
+func parseAccountChange(ev *AuthEvent, msg string) bool {
+	ev.Success = true
+
+	switch {
+	case newUserRe.MatchString(msg):
+		m := newUserRe.FindStringSubmatch(msg)
+		ev.Type = AuthUserAdd
+		ev.TargetUser = m[1]
+		ev.TTY = strings.TrimPrefix(m[2], "/dev/")
+	case newGroupRe.MatchString(msg):
+		ev.Type = AuthGroupAdd
+		ev.Group = newGroupRe.FindStringSubmatch(msg)[1]
+	case deleteUserRe.MatchString(msg):
+		ev.Type = AuthUserDelete
+		ev.TargetUser = deleteUserRe.FindStringSubmatch(msg)[1]
+	case groupMemberRe.MatchString(msg):
+		// 同时会记录 "add 'bob' to shadow group 'sudo'"，只取 group 那一条
+		m := groupMemberRe.FindStringSubmatch(msg)
+		ev.Type = AuthGroupMemberAdd
+		ev.TargetUser = m[1]
+		ev.Group = m[2]
+	case passwdChangeRe.MatchString(msg):
+		ev.Type = AuthPasswordChange
+		ev.TargetUser = passwdChangeRe.FindStringSubmatch(msg)[1]
+	default:
+		return false
+	}
+	return true
+}
+
+// failureWindow 单个来源在检测窗口内的登录失败记录
+type failureWindow struct {
+	times   []time.Time
+	users   []string
+	alerted bool // 本轮已产生暴力破解事件，失败次数回落到阈值以下后重新计算
+}
+
+// bruteForceDetector 按来源 IP 统计滑动窗口内的 SSH 登录失败次数
+type bruteForceDetector struct {
+	threshold int
+	window    time.Duration
+	sources   map[string]*failureWindow
+}
+
+// newBruteForceDetector 创建暴力破解检测
+func newBruteForceDetector(threshold int, window time.Duration) *bruteForceDetector {
+	return &bruteForceDetector{threshold: threshold, window: window, sources: make(map[string]*failureWindow)}
+}
+
+// observe 记录登录事件，失败次数首次达到阈值时返回暴力破解事件
+//
+// 登录成功时在事件的 failures 中记录该来源近期的失败次数，便于发现破解成功的登录。
+func (d *bruteForceDetector) observe(ev *AuthEvent) (AuthEvent, bool) {
+	if ev.Type != AuthLogin || ev.SourceIP == "" {
+		return AuthEvent{}, false
+	}
+
+	w := d.sources[ev.SourceIP]
+	if w != nil {
+		w.prune(ev.Timestamp.Add(-d.window))
+	}
+
+	if ev.Success {
+		if w != nil {
+			ev.Failures = len(w.times)
+		}
+		return AuthEvent{}, false
+	}
+
+	if w == nil {
+		if len(d.sources) >= maxBruteForceSources {
+			d.sweep(ev.Timestamp)
+		}
+		w = &failureWindow{}
+		d.sources[ev.SourceIP] = w
+	}
+	for i := 0; i < max(ev.Count, 1); i++ {
+		w.times = append(w.times, ev.Timestamp)
+	}
+	if !contains(w.users, ev.User) && len(w.users) < maxBruteForceUsers {
+		w.users = append(w.users, ev.User)
+	}
+
+	if len(w.times) < d.threshold {
+		w.alerted = false
+		return AuthEvent{}, false
+	}
+	if w.alerted {
+		return AuthEvent{}, false
+	}
+	w.alerted = true
+
+	users := append([]string(nil), w.users...)
+	sort.Strings(users)
+	return AuthEvent{
+		Type:      AuthBruteForce,
+		Service:   ev.Service,
+		SourceIP:  ev.SourceIP,
+		Count:     len(w.times),
+		Users:     users,
+		Window:    int(d.window / time.Second),
+		Hostname:  ev.Hostname,
+		Log:       ev.Log,
+		Timestamp: ev.Timestamp,
+	}, true
+}
+
+// prune 丢弃窗口之前的失败记录
+func (w *failureWindow) prune(since time.Time) {
+	i := 0
+	for i < len(w.times) && w.times[i].Before(since) {
+		i++
+	}
+	w.times = w.times[i:]
+	if len(w.times) == 0 {
+		w.users = nil
+	}
+}
+
+// sweep 清理窗口内已没有失败记录的来源，仍然超出上限时清空
+func (d *bruteForceDetector) sweep(now time.Time) {
+	for ip, w := range d.sources {
+		w.prune(now.Add(-d.window))
+		if len(w.times) == 0 {
+			delete(d.sources, ip)
+		}
+	}
+	if len(d.sources) >= maxBruteForceSources {
+		log.Printf("Tracking too many login failure sources (%d), resetting brute-force counters", len(d.sources))
+		d.sources = make(map[string]*failureWindow)
+	}
+}
+
+// contains 判断字符串是否在列表中
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAuthLine(t *testing.T) {
+	now := time.Date(2026, 10, 16, 23, 30, 0, 0, time.Local)
+	at := func(month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(2026, month, day, hour, min, sec, 0, time.Local)
+	}
+
+	tests := []struct {
+		name string
+		line string
+		want AuthEvent
+	}{
+		{
+			name: "failed password",
+			line: "Oct 16 23:17:13 web-01 sshd[1234]: Failed password for root from 203.0.113.5 port 52144 ssh2",
+			want: AuthEvent{Type: AuthLogin, Service: "sshd", User: "root", SourceIP: "203.0.113.5", Port: 52144,
+				Method: "password", Hostname: "web-01", PID: 1234, Timestamp: at(10, 16, 23, 17, 13)},
+		},
+		{
+			name: "failed password for invalid user",
+			line: "Oct 16 23:17:14 web-01 sshd[1235]: Failed password for invalid user admin from 203.0.113.5 port 52146 ssh2",
+			want: AuthEvent{Type: AuthLogin, Service: "sshd", User: "admin", SourceIP: "203.0.113.5", Port: 52146,
+				Method: "password", InvalidUser: true, Hostname: "web-01", PID: 1235, Timestamp: at(10, 16, 23, 17, 14)},
+		},
+		{
+			name: "accepted publickey",
+			line: "Oct  6 09:02:01 web-01 sshd[2001]: Accepted publickey for deploy from 2001:db8::7 port 40022 ssh2: ED25519 SHA256:abc",
+			want: AuthEvent{Type: AuthLogin, Service: "sshd", User: "deploy", SourceIP: "2001:db8::7", Port: 40022,
+				Method: "publickey", Success: true, Hostname: "web-01", PID: 2001, Timestamp: at(10, 6, 9, 2, 1)},
+		},
+		{
+			name: "keyboard-interactive with user containing spaces",
+			line: "2026-10-16T23:18:00.123456+00:00 web-01 sshd-session[3001]: Accepted keyboard-interactive/pam for john doe from 10.0.0.2 port 2222 ssh2",
+			want: AuthEvent{Type: AuthLogin, Service: "sshd", User: "john doe", SourceIP: "10.0.0.2", Port: 2222,
+				Method: "keyboard-interactive", Success: true, Hostname: "web-01", PID: 3001,
+				Timestamp: time.Date(2026, 10, 16, 23, 18, 0, 123456000, time.UTC)},
+		},
+		{
+			name: "invalid user",
+			line: "Oct 16 23:17:12 web-01 sshd[1233]: Invalid user oracle from 198.51.100.9 port 41000",
+			want: AuthEvent{Type: AuthInvalidUser, Service: "sshd", User: "oracle", SourceIP: "198.51.100.9", Port: 41000,
+				InvalidUser: true, Hostname: "web-01", PID: 1233, Timestamp: at(10, 16, 23, 17, 12)},
+		},
+		{
+			name: "invalid user without port",
+			line: "Oct 16 23:17:12 web-01 /usr/sbin/sshd[1233]: Invalid user test from 198.51.100.9",
+			want: AuthEvent{Type: AuthInvalidUser, Service: "sshd", User: "test", SourceIP: "198.51.100.9",
+				InvalidUser: true, Hostname: "web-01", PID: 1233, Timestamp: at(10, 16, 23, 17, 12)},
+		},
+		{
+			name: "repeated failures",
+			line: "Oct 16 23:20:00 web-01 sshd[1240]: message repeated 4 times: [ Failed password for root from 203.0.113.5 port 52150 ssh2]",
+			want: AuthEvent{Type: AuthLogin, Service: "sshd", User: "root", SourceIP: "203.0.113.5", Port: 52150,
+				Method: "password", Count: 4, Hostname: "web-01", PID: 1240, Timestamp: at(10, 16, 23, 20, 0)},
+		},
+		{
+			name: "last year's log",
+			line: "Dec 31 23:59:59 web-01 sshd[1]: Failed password for root from 203.0.113.5 port 1 ssh2",
+			want: AuthEvent{Type: AuthLogin, Service: "sshd", User: "root", SourceIP: "203.0.113.5", Port: 1,
+				Method: "password", Hostname: "web-01", PID: 1, Timestamp: time.Date(2025, 12, 31, 23, 59, 59, 0, time.Local)},
+		},
+		{
+			name: "sudo command",
+			line: "Oct 16 23:21:00 web-01 sudo: alice : TTY=pts/0 ; PWD=/home/alice ; USER=root ; COMMAND=/usr/bin/id",
+			want: AuthEvent{Type: AuthSudo, Service: "sudo", User: "alice", TargetUser: "root", TTY: "pts/0",
+				Command: "/usr/bin/id", Success: true, Hostname: "web-01", Timestamp: at(10, 16, 23, 21, 0)},
+		},
+		{
+			name: "sudo failure",
+			line: "Oct 16 23:21:05 web-01 sudo[77]: alice : 3 incorrect password attempts ; TTY=unknown ; PWD=/home/alice ; USER=root ; COMMAND=/bin/sh",
+			want: AuthEvent{Type: AuthSudo, Service: "sudo", User: "alice", TargetUser: "root",
+				Command: "/bin/sh", Reason: "3 incorrect password attempts", Hostname: "web-01", PID: 77, Timestamp: at(10, 16, 23, 21, 5)},
+		},
+		{
+			name: "su",
+			line: "Oct 16 23:22:00 web-01 su[88]: (to root) alice on pts/1",
+			want: AuthEvent{Type: AuthSu, Service: "su", User: "alice", TargetUser: "root", TTY: "pts/1",
+				Success: true, Hostname: "web-01", PID: 88, Timestamp: at(10, 16, 23, 22, 0)},
+		},
+		{
+			name: "useradd",
+			line: "Oct 16 23:23:00 web-01 useradd[99]: new user: name=backdoor, UID=0, GID=0, home=/root, shell=/bin/bash, from=/dev/pts/1",
+			want: AuthEvent{Type: AuthUserAdd, Service: "useradd", TargetUser: "backdoor", TTY: "pts/1",
+				Success: true, Hostname: "web-01", PID: 99, Timestamp: at(10, 16, 23, 23, 0)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseAuthLine(tt.line, now)
+			if !ok {
+				t.Fatalf("parseAuthLine(%q) not parsed", tt.line)
+			}
+			got.Message = ""
+			if !got.Timestamp.Equal(tt.want.Timestamp) {
+				t.Errorf("timestamp = %v, want %v", got.Timestamp, tt.want.Timestamp)
+			}
+			got.Timestamp, tt.want.Timestamp = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAuthLineIgnored(t *testing.T) {
+	now := time.Now()
+	for _, line := range []string{
+		"",
+		"garbage",
+		"Oct 16 23:17:13 web-01 sshd[1234]: Connection closed by 203.0.113.5 port 52144 [preauth]",
+		"Oct 16 23:17:13 web-01 sshd[1234]: pam_unix(sshd:session): session opened for user root",
+		"Oct 16 23:17:13 web-01 CRON[5]: pam_unix(cron:session): session opened for user root",
+		"Oct 16 23:17:13 web-01 sudo: pam_unix(sudo:session): session closed for user root",
+		"2026-13-01T00:00:00Z web-01 sshd[1]: Failed password for root from 1.2.3.4 port 1 ssh2",
+	} {
+		if ev, ok := parseAuthLine(line, now); ok {
+			t.Errorf("parseAuthLine(%q) = %+v, want ignored", line, ev)
+		}
+	}
+}
+
+func TestBruteForceDetector(t *testing.T) {
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	d := newBruteForceDetector(5, time.Minute)
+
+	failure := func(ip, user string, at time.Duration, count int) *AuthEvent {
+		return &AuthEvent{Type: AuthLogin, Service: "sshd", SourceIP: ip, User: user, Count: count, Timestamp: base.Add(at)}
+	}
+
+	// 窗口内第 5 次失败时产生事件，之后不再重复
+	for i := 0; i < 4; i++ {
+		if _, ok := d.observe(failure("203.0.113.5", []string{"root", "admin"}[i%2], time.Duration(i)*10*time.Second, 0)); ok {
+			t.Fatalf("brute force reported after %d failures", i+1)
+		}
+	}
+	ev, ok := d.observe(failure("203.0.113.5", "oracle", 40*time.Second, 0))
+	if !ok {
+		t.Fatal("brute force not reported at the threshold")
+	}
+	if ev.Type != AuthBruteForce || ev.Count != 5 || ev.Window != 60 || ev.SourceIP != "203.0.113.5" ||
+		!reflect.DeepEqual(ev.Users, []string{"admin", "oracle", "root"}) {
+		t.Errorf("brute force event = %+v", ev)
+	}
+	if _, ok := d.observe(failure("203.0.113.5", "root", 45*time.Second, 0)); ok {
+		t.Error("brute force reported twice in the same round")
+	}
+
+	// 其他来源单独计数
+	if _, ok := d.observe(failure("198.51.100.9", "root", 46*time.Second, 0)); ok {
+		t.Error("failures from another source were counted together")
+	}
+
+	// 登录成功时记录窗口内的失败次数
+	success := &AuthEvent{Type: AuthLogin, Success: true, SourceIP: "203.0.113.5", User: "root", Timestamp: base.Add(50 * time.Second)}
+	if _, ok := d.observe(success); ok || success.Failures != 6 {
+		t.Errorf("successful login: failures %d, want 6", success.Failures)
+	}
+
+	// 窗口滑过后失败次数回落，重新达到阈值时再次报告
+	if _, ok := d.observe(failure("203.0.113.5", "root", 3*time.Minute, 0)); ok {
+		t.Error("brute force reported with a single failure in the window")
+	}
+	ev, ok = d.observe(failure("203.0.113.5", "root", 3*time.Minute+time.Second, 4))
+	if !ok || ev.Count != 5 || !reflect.DeepEqual(ev.Users, []string{"root"}) {
+		t.Errorf("second round with repeated message: ok %v, %+v", ok, ev)
+	}
+
+	// 窗口边界：恰好一个窗口之前的失败仍在窗口内
+	d = newBruteForceDetector(2, time.Minute)
+	d.observe(failure("192.0.2.1", "root", 0, 0))
+	if _, ok := d.observe(failure("192.0.2.1", "root", time.Minute, 0)); !ok {
+		t.Error("failure exactly one window ago was not counted")
+	}
+	d = newBruteForceDetector(2, time.Minute)
+	d.observe(failure("192.0.2.1", "root", 0, 0))
+	if _, ok := d.observe(failure("192.0.2.1", "root", time.Minute+time.Nanosecond, 0)); ok {
+		t.Error("failure outside the window was counted")
+	}
+
+	// 非登录事件和没有来源的事件不计数
+	d = newBruteForceDetector(1, time.Minute)
+	if _, ok := d.observe(&AuthEvent{Type: AuthSudo, SourceIP: "192.0.2.1", Timestamp: base}); ok {
+		t.Error("sudo event counted as login failure")
+	}
+	if _, ok := d.observe(&AuthEvent{Type: AuthLogin, Timestamp: base}); ok {
+		t.Error("login without source counted")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"mini-hids/agent/config"
@@ -19,16 +20,73 @@ type Collector struct {
 	config  *config.Config         // 配置信息
 	data    map[string]interface{} // 采集到的数据
 	dataMux sync.RWMutex           // 数据读写锁
+
+	files      *fileMonitor // 文件完整性监控
+	fileEvents []FileEvent  // 待上报的文件变更事件
+
+	sessions     *sessionMonitor // 登录会话监控
+	loginRecords []LoginRecord   // 待上报的登录/注销记录
+
+	eventMux    sync.Mutex     // 实时事件锁
+	watchEvents []WatchEvent   // 待上报的实时文件事件
+	procEvents  []ProcessEvent // 待上报的进程执行/退出事件
+	auditEvents []AuditEvent   // 待上报的内核审计事件
+	authEvents  []AuthEvent    // 待上报的认证日志事件
+
+	audit *auditClient // 内核审计采集，未启用或连接失败时为 nil
+
+	cpuSamples map[procKey]cpuSample // 上一周期的进程 CPU 采样
+	lastCPU    *cpuTimes             // 上一周期的系统 CPU 时间
 }
 
+// maxPendingEvents 待上报事件的最大缓存数量
+const maxPendingEvents = 10000
+
+// procPollInterval 无法使用 proc connector 时扫描 /proc 的间隔
+const procPollInterval = time.Second
+
 // ProcessInfo 进程信息
 type ProcessInfo struct {
-	PID     int    `json:"pid"`     // 进程ID
-	Name    string `json:"name"`    // 进程名称
-	Cmdline string `json:"cmdline"` // 进程命令行
-	User    string `json:"user"`    // 进程所属用户
-	CPU     string `json:"cpu"`     // CPU占用率
-	Memory  string `json:"memory"`  // 内存占用率
+	PID        int     `json:"pid"`         // 进程ID
+	PPID       int     `json:"ppid"`        // 父进程ID
+	Name       string  `json:"name"`        // 进程名称
+	ParentName string  `json:"parent_name"` // 父进程名称
+	Exe        string  `json:"exe"`         // 可执行文件路径
+	Cmdline    string  `json:"cmdline"`     // 进程命令行
+	Cwd        string  `json:"cwd"`         // 工作目录
+	TTY        string  `json:"tty"`         // 控制终端（如 pts/0），无终端时为空
+	User       string  `json:"user"`        // 进程所属用户
+	UID        uint32  `json:"uid"`         // 有效用户ID
+	CPU        string  `json:"cpu"`         // CPU占用率
+	Memory     string  `json:"memory"`      // 内存占用
+	CPUPercent float64 `json:"cpu_percent"` // CPU占用率（数值）
+	RSSBytes   uint64  `json:"rss_bytes"`   // 常驻内存（字节）
+	StartTime  uint64  `json:"start_time"`  // 启动时间（开机后的时钟滴答数），与 PID 一起唯一标识进程
+}
+
+// clockTicks 内核时钟频率（USER_HZ），Linux 上固定为 100
+const clockTicks = 100
+
+// procKey 进程标识，加入启动时间以区分复用的 PID
+type procKey struct {
+	pid       int
+	startTime uint64
+}
+
+// cpuSample 进程 CPU 时间采样
+type cpuSample struct {
+	ticks uint64    // utime + stime
+	at    time.Time // 采样时间
+}
+
+// procStat /proc/<pid>/stat 中用到的字段
+type procStat struct {
+	State     string // 进程状态
+	PPID      int    // 父进程ID
+	TTYNr     uint32 // 控制终端设备号
+	UTime     uint64 // 用户态时间（ticks）
+	STime     uint64 // 内核态时间（ticks）
+	StartTime uint64 // 启动时间（系统启动后的 ticks）
 }
 
 // NetworkConnection 网络连接信息
@@ -40,18 +98,54 @@ type NetworkConnection struct {
 	RemotePort int    `json:"remote_port"` // 远程端口
 	State      string `json:"state"`       // 连接状态
 	PID        int    `json:"pid"`         // 进程ID
+	Process    string `json:"process"`     // 进程名称
+	Inode      uint64 `json:"inode"`       // socket inode
+}
+
+// socketOwner socket 所属进程
+type socketOwner struct {
+	PID  int
+	Name string
 }
 
 // SystemInfo 系统信息
 type SystemInfo struct {
-	Hostname    string  `json:"hostname"`     // 主机名
-	OS          string  `json:"os"`           // 操作系统
-	Kernel      string  `json:"kernel"`       // 内核版本
-	Uptime      string  `json:"uptime"`       // 系统运行时间
-	LoadAverage string  `json:"load_average"` // 系统负载平均值
-	CPUUsage    float64 `json:"cpu_usage"`    // CPU占用率
-	MemoryUsage float64 `json:"memory_usage"` // 内存占用率
-	DiskUsage   float64 `json:"disk_usage"`   // 磁盘占用率
+	Hostname        string     `json:"hostname"`         // 主机名
+	OS              string     `json:"os"`               // 操作系统
+	Kernel          string     `json:"kernel"`           // 内核版本
+	Uptime          string     `json:"uptime"`           // 系统运行时间
+	LoadAverage     string     `json:"load_average"`     // 系统负载平均值
+	CPUUsage        float64    `json:"cpu_usage"`        // CPU占用率
+	MemoryUsage     float64    `json:"memory_usage"`     // 内存占用率
+	MemoryTotal     uint64     `json:"memory_total"`     // 内存总量（字节）
+	MemoryAvailable uint64     `json:"memory_available"` // 可用内存（字节）
+	DiskUsage       []DiskInfo `json:"disk_usage"`       // 各挂载点磁盘占用
+}
+
+// DiskInfo 挂载点磁盘占用
+type DiskInfo struct {
+	Mountpoint string  `json:"mountpoint"` // 挂载点
+	Device     string  `json:"device"`     // 设备
+	FSType     string  `json:"fstype"`     // 文件系统类型
+	Total      uint64  `json:"total"`      // 总容量（字节）
+	Used       uint64  `json:"used"`       // 已用容量（字节）
+	Free       uint64  `json:"free"`       // 非特权用户可用容量（字节）
+	Usage      float64 `json:"usage"`      // 占用率
+}
+
+// cpuTimes /proc/stat 中的 CPU 时间累计值
+type cpuTimes struct {
+	total uint64 // 全部时间
+	idle  uint64 // 空闲时间（含 iowait）
+}
+
+// pseudoFilesystems 不统计磁盘占用的虚拟文件系统
+var pseudoFilesystems = map[string]bool{
+	"proc": true, "sysfs": true, "tmpfs": true, "devtmpfs": true, "devpts": true,
+	"cgroup": true, "cgroup2": true, "mqueue": true, "debugfs": true, "tracefs": true,
+	"securityfs": true, "pstore": true, "bpf": true, "autofs": true, "configfs": true,
+	"fusectl": true, "hugetlbfs": true, "binfmt_misc": true, "nsfs": true, "ramfs": true,
+	"rpc_pipefs": true, "efivarfs": true, "selinuxfs": true, "squashfs": true,
 }
 
 // New 创建新的采集器
@@ -59,6 +153,9 @@ func New(cfg *config.Config) *Collector {
 	return &Collector{
 		config: cfg,
 		data:   make(map[string]interface{}),
+		files:  newFileMonitor(cfg.WatchPaths, cfg.FIMBaselineFile),
+
+		cpuSamples: make(map[procKey]cpuSample),
 	}
 }
 
@@ -66,6 +163,26 @@ func New(cfg *config.Config) *Collector {
 func (c *Collector) Start(stopCh <-chan struct{}) {
 	log.Println("Starting data collector...")
 
+	if c.config.CollectFile {
+		c.startFileWatcher(stopCh)
+	}
+
+	if c.config.CollectProcessEvents {
+		c.startProcessMonitor(stopCh)
+	}
+
+	if c.config.Audit.Enabled {
+		c.startAudit()
+	}
+
+	if c.config.AuthLog.Enabled {
+		go c.runAuthLog(stopCh)
+	}
+
+	if c.config.CollectSessions {
+		c.sessions = newSessionMonitor()
+	}
+
 	// 定时采集数据
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
@@ -75,13 +192,164 @@ func (c *Collector) Start(stopCh <-chan struct{}) {
 		case <-ticker.C:
 			c.collectData()
 		case <-stopCh:
+			// 审计规则留在内核中会持续产生事件，退出前同步删除
+			if c.audit != nil {
+				c.audit.Close()
+			}
 			return
 		}
 	}
 }
 
+// startFileWatcher 启动实时文件监控，失败时仅依赖定时扫描
+func (c *Collector) startFileWatcher(stopCh <-chan struct{}) {
+	watcher, err := newInotifyWatcher(c.addWatchEvent)
+	if err != nil {
+		log.Printf("Failed to initialize inotify, falling back to polling: %v", err)
+		return
+	}
+
+	for _, path := range c.config.WatchPaths {
+		if err := watcher.Add(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to watch %s: %v", path, err)
+		}
+	}
+
+	go watcher.Run()
+	go func() {
+		<-stopCh
+		watcher.Close()
+	}()
+}
+
+// startProcessMonitor 启动实时进程事件监控，无法使用 proc connector 时改为轮询 /proc
+func (c *Collector) startProcessMonitor(stopCh <-chan struct{}) {
+	conn, err := newProcConnector(c.addProcessEvent)
+	if err != nil {
+		log.Printf("Failed to open proc connector, falling back to polling /proc every %s: %v", procPollInterval, err)
+		go newProcPoller(c.addProcessEvent).Run(procPollInterval, stopCh)
+		return
+	}
+
+	go conn.Run()
+	go func() {
+		<-stopCh
+		conn.Close()
+	}()
+}
+
+// startAudit 安装审计规则并接收内核审计事件，失败时不影响其他采集
+func (c *Collector) startAudit() {
+	rules := make([]*auditRule, 0, len(c.config.Audit.Rules))
+	for _, text := range c.config.Audit.Rules {
+		rule, err := parseAuditRule(text)
+		if err != nil {
+			log.Printf("Skipping invalid audit rule %q: %v", text, err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	client, err := newAuditClient(rules, c.addAuditEvent)
+	if err != nil {
+		log.Printf("Failed to connect to the audit subsystem, audit events disabled: %v", err)
+		return
+	}
+	log.Printf("Receiving audit events (%s), %d of %d rules installed", client.mode, len(client.added), len(rules))
+
+	c.audit = client
+	go client.Run()
+}
+
+// runAuthLog 跟踪认证日志，解析登录和账号变更事件并检测暴力破解
+func (c *Collector) runAuthLog(stopCh <-chan struct{}) {
+	cfg := c.config.AuthLog
+	detector := newBruteForceDetector(cfg.BruteForceThreshold, time.Duration(cfg.BruteForceWindow)*time.Second)
+
+	tailers := make([]*logTailer, 0, len(cfg.Paths))
+	for _, path := range cfg.Paths {
+		tailers = append(tailers, newLogTailer(path))
+	}
+
+	ticker := time.NewTicker(authLogPollInterval)
+	defer ticker.Stop()
+
+	failed := make(map[string]bool) // 已记录过读取失败的文件，避免每次轮询重复记录
+	for {
+		for _, t := range tailers {
+			lines, err := t.poll()
+			if err != nil && !failed[t.path] {
+				log.Printf("Failed to read auth log %s: %v", t.path, err)
+			}
+			failed[t.path] = err != nil
+
+			now := time.Now()
+			for _, line := range lines {
+				ev, ok := parseAuthLine(string(line), now)
+				if !ok {
+					continue
+				}
+				ev.Log = t.path
+				bruteForce, detected := detector.observe(&ev)
+				c.addAuthEvent(ev)
+				if detected {
+					log.Printf("Brute-force login detected from %s: %d failures in %ds", bruteForce.SourceIP, bruteForce.Count, bruteForce.Window)
+					c.addAuthEvent(bruteForce)
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			for _, t := range tailers {
+				t.close()
+			}
+			return
+		}
+	}
+}
+
+// addAuthEvent 记录认证日志事件
+func (c *Collector) addAuthEvent(event AuthEvent) {
+	c.eventMux.Lock()
+	defer c.eventMux.Unlock()
+
+	c.authEvents = appendBounded(c.authEvents, event)
+}
+
+// addAuditEvent 记录审计事件
+func (c *Collector) addAuditEvent(event AuditEvent) {
+	c.eventMux.Lock()
+	defer c.eventMux.Unlock()
+
+	c.auditEvents = appendBounded(c.auditEvents, event)
+}
+
+// addProcessEvent 记录进程事件
+func (c *Collector) addProcessEvent(event ProcessEvent) {
+	c.eventMux.Lock()
+	defer c.eventMux.Unlock()
+
+	c.procEvents = appendBounded(c.procEvents, event)
+}
+
+// addWatchEvent 记录实时文件事件
+func (c *Collector) addWatchEvent(event WatchEvent) {
+	c.eventMux.Lock()
+	defer c.eventMux.Unlock()
+
+	c.watchEvents = appendBounded(c.watchEvents, event)
+}
+
 // collectData 采集各种数据
 func (c *Collector) collectData() {
+	// 文件扫描耗时较长，在加锁前完成
+	var fileEvents []FileEvent
+	if c.config.CollectFile {
+		fileEvents = c.files.scan()
+	}
+
 	c.dataMux.Lock()
 	defer c.dataMux.Unlock()
 
@@ -96,11 +364,35 @@ func (c *Collector) collectData() {
 	if c.config.CollectSystem {
 		c.data["system"] = c.collectSystemInfo()
 	}
+
+	if c.sessions != nil {
+		// 先处理注销记录，再用当前会话补全启动前已登录的终端
+		if records := c.sessions.records(); len(records) > 0 {
+			c.loginRecords = appendBounded(c.loginRecords, records...)
+		}
+		c.data["sessions"] = c.sessions.sessions()
+	}
+
+	if len(fileEvents) > 0 {
+		c.fileEvents = appendBounded(c.fileEvents, fileEvents...)
+	}
+}
+
+// appendBounded 追加事件，超出上限时丢弃最旧的事件
+func appendBounded[T any](events []T, more ...T) []T {
+	events = append(events, more...)
+	if len(events) > maxPendingEvents {
+		dropped := len(events) - maxPendingEvents
+		log.Printf("Pending events exceed %d, dropping %d oldest", maxPendingEvents, dropped)
+		events = events[dropped:]
+	}
+	return events
 }
 
 // collectProcesses 采集进程信息
 func (c *Collector) collectProcesses() []ProcessInfo {
 	var processes []ProcessInfo
+	samples := make(map[procKey]cpuSample)
 
 	procDir := "/proc"
 	files, err := os.ReadDir(procDir)
@@ -119,17 +411,29 @@ func (c *Collector) collectProcesses() []ProcessInfo {
 			continue
 		}
 
-		process := c.getProcessInfo(pid)
+		process := c.getProcessInfo(pid, samples)
 		if process != nil {
 			processes = append(processes, *process)
 		}
 	}
 
+	// 补充父进程名称，便于规则识别异常的父子关系（例如 Web 服务派生 shell）
+	names := make(map[int]string, len(processes))
+	for _, p := range processes {
+		names[p.PID] = p.Name
+	}
+	for i := range processes {
+		processes[i].ParentName = names[processes[i].PPID]
+	}
+
+	// 只保留仍存在的进程的采样
+	c.cpuSamples = samples
+
 	return processes
 }
 
-// getProcessInfo 获取单个进程信息
-func (c *Collector) getProcessInfo(pid int) *ProcessInfo {
+// getProcessInfo 获取单个进程信息，并把本次 CPU 采样记录到 samples
+func (c *Collector) getProcessInfo(pid int, samples map[procKey]cpuSample) *ProcessInfo {
 	// 读取进程名称
 	commPath := fmt.Sprintf("/proc/%d/comm", pid)
 	commData, err := os.ReadFile(commPath)
@@ -146,14 +450,144 @@ func (c *Collector) getProcessInfo(pid int) *ProcessInfo {
 	}
 	cmdline := strings.ReplaceAll(string(cmdlineData), "\x00", " ")
 
-	return &ProcessInfo{
+	process := &ProcessInfo{
 		PID:     pid,
 		Name:    name,
 		Cmdline: strings.TrimSpace(cmdline),
-		User:    "unknown", // 简化版本，不获取用户信息
-		CPU:     "0%",      // 简化版本，不计算 CPU 使用率
-		Memory:  "0MB",     // 简化版本，不计算内存使用
+		User:    "unknown",
+	}
+
+	// 内核线程和其他用户的进程可能无法读取 exe 和 cwd
+	process.Exe, _ = os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	process.Cwd, _ = os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+
+	// 读取所属用户
+	if uid, ok := readProcUID(pid); ok {
+		process.UID = uid
+		process.User = lookupUser(uid)
+	}
+
+	// 读取常驻内存
+	process.RSSBytes = readProcRSS(pid)
+	process.Memory = fmt.Sprintf("%.1fMB", float64(process.RSSBytes)/(1024*1024))
+
+	// 根据两次采样之间的 CPU 时间差计算占用率
+	if stat, err := readProcStat(pid); err == nil {
+		key := procKey{pid: pid, startTime: stat.StartTime}
+		process.StartTime = stat.StartTime
+		process.PPID = stat.PPID
+		process.TTY = ttyName(stat.TTYNr)
+		now := time.Now()
+		ticks := stat.UTime + stat.STime
+
+		if prev, ok := c.cpuSamples[key]; ok && ticks >= prev.ticks {
+			elapsed := now.Sub(prev.at).Seconds()
+			if elapsed > 0 {
+				process.CPUPercent = float64(ticks-prev.ticks) / clockTicks / elapsed * 100
+			}
+		}
+		samples[key] = cpuSample{ticks: ticks, at: now}
 	}
+	process.CPU = fmt.Sprintf("%.1f%%", process.CPUPercent)
+
+	return process
+}
+
+// readProcStat 解析 /proc/<pid>/stat
+func readProcStat(pid int) (*procStat, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+
+	// comm 字段可能包含空格和括号，从最后一个 ')' 之后开始解析
+	end := strings.LastIndexByte(string(data), ')')
+	if end < 0 {
+		return nil, fmt.Errorf("malformed stat for pid %d", pid)
+	}
+
+	// fields[0] 对应 stat 的第 3 个字段（state）
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("short stat for pid %d", pid)
+	}
+
+	stat := &procStat{State: fields[0]}
+	stat.PPID, _ = strconv.Atoi(fields[1])
+	if tty, err := strconv.ParseInt(fields[4], 10, 64); err == nil {
+		stat.TTYNr = uint32(tty)
+	}
+	stat.UTime, _ = strconv.ParseUint(fields[11], 10, 64)
+	stat.STime, _ = strconv.ParseUint(fields[12], 10, 64)
+	stat.StartTime, _ = strconv.ParseUint(fields[19], 10, 64)
+
+	return stat, nil
+}
+
+// ttyName 将 stat 中的 tty_nr 转换为终端名称
+func ttyName(nr uint32) string {
+	if nr == 0 {
+		return ""
+	}
+
+	major := (nr >> 8) & 0xfff
+	minor := (nr & 0xff) | ((nr >> 12) & 0xfff00)
+	switch {
+	case major >= 136 && major <= 143:
+		return fmt.Sprintf("pts/%d", minor+(major-136)*256)
+	case major == 4 && minor < 64:
+		return fmt.Sprintf("tty%d", minor)
+	case major == 4:
+		return fmt.Sprintf("ttyS%d", minor-64)
+	case major == 5 && minor == 1:
+		return "console"
+	}
+	return fmt.Sprintf("%d:%d", major, minor)
+}
+
+// readProcUID 从 /proc/<pid>/status 读取有效 UID
+func readProcUID(pid int) (uint32, bool) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0, false
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Uid:") {
+			continue
+		}
+
+		// 格式: Uid: real effective saved fs
+		fields := strings.Fields(strings.TrimPrefix(line, "Uid:"))
+		if len(fields) < 2 {
+			return 0, false
+		}
+
+		uid, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			return 0, false
+		}
+		return uint32(uid), true
+	}
+
+	return 0, false
+}
+
+// readProcRSS 从 /proc/<pid>/statm 读取常驻内存字节数
+func readProcRSS(pid int) uint64 {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return 0
+	}
+
+	// 格式: size resident shared text lib data dt（单位为页）
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0
+	}
+
+	pages, _ := strconv.ParseUint(fields[1], 10, 64)
+	return pages * uint64(os.Getpagesize())
 }
 
 // collectNetworkConnections 采集网络连接信息
@@ -168,9 +602,71 @@ func (c *Collector) collectNetworkConnections() []NetworkConnection {
 	udpConnections := c.parseNetworkFile("/proc/net/udp")
 	connections = append(connections, udpConnections...)
 
+	// 读取 IPv6 连接（内核未启用 IPv6 时文件不存在）
+	connections = append(connections, c.parseNetworkFile("/proc/net/tcp6")...)
+	connections = append(connections, c.parseNetworkFile("/proc/net/udp6")...)
+
+	// 根据 socket inode 关联所属进程
+	owners := c.buildSocketIndex()
+	for i := range connections {
+		if owner, ok := owners[connections[i].Inode]; ok {
+			connections[i].PID = owner.PID
+			connections[i].Process = owner.Name
+		}
+	}
+
 	return connections
 }
 
+// buildSocketIndex 扫描 /proc/<pid>/fd，建立 socket inode 到进程的映射
+func (c *Collector) buildSocketIndex() map[uint64]socketOwner {
+	owners := make(map[uint64]socketOwner)
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return owners
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		fdDir := fmt.Sprintf("/proc/%d/fd", pid)
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+
+		var name string
+		for _, fd := range fds {
+			link, err := os.Readlink(fdDir + "/" + fd.Name())
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+
+			inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+
+			// 多个进程共享同一 socket 时（例如 fork 后继承）保留最先扫描到的进程
+			if _, exists := owners[inode]; exists {
+				continue
+			}
+
+			if name == "" {
+				comm, _ := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+				name = strings.TrimSpace(string(comm))
+			}
+			owners[inode] = socketOwner{PID: pid, Name: name}
+		}
+	}
+
+	return owners
+}
+
 // parseNetworkFile 解析网络连接文件
 func (c *Collector) parseNetworkFile(filePath string) []NetworkConnection {
 	var connections []NetworkConnection
@@ -201,6 +697,11 @@ func (c *Collector) parseNetworkFile(filePath string) []NetworkConnection {
 		if strings.Contains(filePath, "udp") {
 			protocol = "udp"
 		}
+		if strings.HasSuffix(filePath, "6") {
+			protocol += "6"
+		}
+
+		inode, _ := strconv.ParseUint(fields[9], 10, 64)
 
 		connection := NetworkConnection{
 			Protocol:   protocol,
@@ -209,7 +710,7 @@ func (c *Collector) parseNetworkFile(filePath string) []NetworkConnection {
 			RemoteAddr: remoteAddr,
 			RemotePort: remotePort,
 			State:      c.getConnectionState(fields[3]),
-			PID:        0, // 简化版本，不获取 PID
+			Inode:      inode,
 		}
 
 		connections = append(connections, connection)
@@ -237,16 +738,28 @@ func (c *Collector) parseAddress(addr string) (string, int) {
 }
 
 // hexToIP 将十六进制字符串转换为 IP 地址
+// 内核按 32 位字输出地址，每个字内部为主机字节序（小端序）
+// IPv4 为 1 个字（8 个字符），IPv6 为 4 个字（32 个字符）
 func (c *Collector) hexToIP(hexStr string) string {
-	if len(hexStr) != 8 {
+	if len(hexStr) != 8 && len(hexStr) != 32 {
 		return "0.0.0.0"
 	}
 
-	ip := make(net.IP, 4)
-	for i := 0; i < 4; i++ {
-		byteHex := hexStr[i*2 : i*2+2]
-		byteVal, _ := strconv.ParseUint(byteHex, 16, 8)
-		ip[3-i] = byte(byteVal) // 小端序
+	ip := make(net.IP, len(hexStr)/2)
+	for word := 0; word < len(ip)/4; word++ {
+		for i := 0; i < 4; i++ {
+			offset := (word*4 + i) * 2
+			byteVal, err := strconv.ParseUint(hexStr[offset:offset+2], 16, 8)
+			if err != nil {
+				return "0.0.0.0"
+			}
+			ip[word*4+3-i] = byte(byteVal) // 小端序
+		}
+	}
+
+	// IPv4 映射地址（::ffff:a.b.c.d）统一为 IPv4 形式
+	if len(ip) == net.IPv6len && ip.To4() != nil {
+		return ip.To4().String()
 	}
 
 	return ip.String()
@@ -279,16 +792,161 @@ func (c *Collector) getConnectionState(stateHex string) string {
 func (c *Collector) collectSystemInfo() SystemInfo {
 	hostname, _ := os.Hostname()
 
-	return SystemInfo{
+	info := SystemInfo{
 		Hostname:    hostname,
 		OS:          c.getOSInfo(),
 		Kernel:      c.getKernelVersion(),
 		Uptime:      c.getUptime(),
 		LoadAverage: c.getLoadAverage(),
-		CPUUsage:    0.0, // 简化版本
-		MemoryUsage: 0.0, // 简化版本
-		DiskUsage:   0.0, // 简化版本
+		CPUUsage:    c.getCPUUsage(),
+		DiskUsage:   c.getDiskUsage(),
+	}
+	info.MemoryUsage, info.MemoryTotal, info.MemoryAvailable = c.getMemoryUsage()
+
+	return info
+}
+
+// getCPUUsage 根据两次采样之间 /proc/stat 的时间差计算 CPU 占用率，首次采样返回 0
+func (c *Collector) getCPUUsage() float64 {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return 0
+	}
+
+	// 第一行格式: cpu user nice system idle iowait irq softirq steal guest guest_nice
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0
+	}
+
+	var current cpuTimes
+	for i, field := range fields[1:] {
+		// guest 时间已计入 user，不重复累加
+		if i >= 8 {
+			break
+		}
+		value, _ := strconv.ParseUint(field, 10, 64)
+		current.total += value
+		if i == 3 || i == 4 {
+			current.idle += value
+		}
+	}
+
+	prev := c.lastCPU
+	c.lastCPU = &current
+	if prev == nil || current.total <= prev.total {
+		return 0
+	}
+
+	totalDelta := float64(current.total - prev.total)
+	idleDelta := float64(current.idle - prev.idle)
+	return (totalDelta - idleDelta) / totalDelta * 100
+}
+
+// getMemoryUsage 读取 /proc/meminfo，返回占用率、总量和可用量（字节）
+func (c *Collector) getMemoryUsage() (float64, uint64, uint64) {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, 0, 0
+	}
+
+	values := make(map[string]uint64)
+	for _, line := range strings.Split(string(data), "\n") {
+		// 格式: MemTotal:       16303528 kB
+		key, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		value, _ := strconv.ParseUint(fields[0], 10, 64)
+		values[key] = value * 1024
+	}
+
+	total := values["MemTotal"]
+	available, ok := values["MemAvailable"]
+	if !ok {
+		// 3.14 之前的内核没有 MemAvailable
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	if total == 0 || available > total {
+		return 0, total, available
+	}
+
+	return float64(total-available) / float64(total) * 100, total, available
+}
+
+// getDiskUsage 统计各挂载点的磁盘占用，同一设备的多次挂载只统计一次
+func (c *Collector) getDiskUsage() []DiskInfo {
+	var disks []DiskInfo
+
+	file, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return disks
+	}
+	defer file.Close()
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 格式: device mountpoint fstype options dump pass
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || pseudoFilesystems[fields[2]] || seen[fields[0]] {
+			continue
+		}
+
+		mountpoint := unescapeMountPath(fields[1])
+
+		var st syscall.Statfs_t
+		if err := syscall.Statfs(mountpoint, &st); err != nil || st.Blocks == 0 {
+			continue
+		}
+		seen[fields[0]] = true
+
+		bsize := uint64(st.Bsize)
+		used := (st.Blocks - st.Bfree) * bsize
+		free := st.Bavail * bsize
+
+		disk := DiskInfo{
+			Mountpoint: mountpoint,
+			Device:     fields[0],
+			FSType:     fields[2],
+			Total:      st.Blocks * bsize,
+			Used:       used,
+			Free:       free,
+		}
+		// 与 df 一致，以非特权用户可见容量为分母
+		if used+free > 0 {
+			disk.Usage = float64(used) / float64(used+free) * 100
+		}
+
+		disks = append(disks, disk)
+	}
+
+	return disks
+}
+
+// unescapeMountPath 还原 /proc/mounts 中转义的空白字符（如 \040）
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, "\\") {
+		return path
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if v, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
 	}
+	return b.String()
 }
 
 // getOSInfo 获取操作系统信息
@@ -355,10 +1013,10 @@ func (c *Collector) getLoadAverage() string {
 	return "Unknown"
 }
 
-// GetData 获取采集的数据
+// GetData 获取采集的数据，待上报的事件在返回后清空
 func (c *Collector) GetData() map[string]interface{} {
-	c.dataMux.RLock()
-	defer c.dataMux.RUnlock()
+	c.dataMux.Lock()
+	defer c.dataMux.Unlock()
 
 	// 复制数据
 	result := make(map[string]interface{})
@@ -366,5 +1024,33 @@ func (c *Collector) GetData() map[string]interface{} {
 		result[k] = v
 	}
 
+	if len(c.fileEvents) > 0 {
+		result["files"] = c.fileEvents
+		c.fileEvents = nil
+	}
+	if len(c.loginRecords) > 0 {
+		result["login_records"] = c.loginRecords
+		c.loginRecords = nil
+	}
+
+	c.eventMux.Lock()
+	if len(c.watchEvents) > 0 {
+		result["file_events"] = c.watchEvents
+		c.watchEvents = nil
+	}
+	if len(c.procEvents) > 0 {
+		result["process_events"] = c.procEvents
+		c.procEvents = nil
+	}
+	if len(c.auditEvents) > 0 {
+		result["audit_events"] = c.auditEvents
+		c.auditEvents = nil
+	}
+	if len(c.authEvents) > 0 {
+		result["auth_events"] = c.authEvents
+		c.authEvents = nil
+	}
+	c.eventMux.Unlock()
+
 	return result
 }
@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHexToIP(t *testing.T) {
+	c := &Collector{}
+	tests := []struct {
+		hex  string
+		want string
+	}{
+		// /proc/net/tcp：一个小端序的字
+		{"0100007F", "127.0.0.1"},
+		{"0F02000A", "10.0.2.15"},
+		{"00000000", "0.0.0.0"},
+		// /proc/net/tcp6：四个字，每个字内部为小端序
+		{"00000000000000000000000001000000", "::1"},
+		{"00000000000000000000000000000000", "::"},
+		{"0000000000000000FFFF00000A01A8C0", "192.168.1.10"}, // ::ffff:192.168.1.10
+		{"0000000000000000ffff00000100007f", "127.0.0.1"},    // 小写十六进制
+		{"B80D0120000000000000000002000100", "2001:db8::1:2"},
+		{"000080FE00000000FF3E2D1C6B5A4FFE", "fe80::1c2d:3eff:fe4f:5a6b"},
+		// 格式错误
+		{"", "0.0.0.0"},
+		{"0100007", "0.0.0.0"},
+		{"0000000000000000000000000100000", "0.0.0.0"},
+		{"ZZ00007F", "0.0.0.0"},
+	}
+	for _, tt := range tests {
+		if got := c.hexToIP(tt.hex); got != tt.want {
+			t.Errorf("hexToIP(%q) = %s, want %s", tt.hex, got, tt.want)
+		}
+	}
+}
+
+func TestParseNetworkFileIPv6(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tcp6 := write("tcp6", `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000001000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 21001 1 0000000000000000 100 0 0 10 0
+   1: 0000000000000000FFFF00000A01A8C0:01BB 0000000000000000FFFF0000050071CB:D2F0 01 00000000:00000000 02:00000A3C 00000000    33        0 21002 1 0000000000000000 20 4 30 10 -1
+   2: B80D0120000000000000000002000100:0050 B80D0120000000000000000007000000:E1A4 06 00000000:00000000 03:00001770 00000000     0        0 0 3 0000000000000000
+   3: truncated
+`)
+	udp6 := write("udp6", `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  100: 000080FE00000000FF3E2D1C6B5A4FFE:0222 00000000000000000000000000000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 21003 2 0000000000000000 0
+`)
+
+	c := &Collector{}
+	want := []NetworkConnection{
+		{Protocol: "tcp6", LocalAddr: "::1", LocalPort: 22, RemoteAddr: "::", RemotePort: 0, State: "LISTEN", Inode: 21001},
+		{Protocol: "tcp6", LocalAddr: "192.168.1.10", LocalPort: 443, RemoteAddr: "203.113.0.5", RemotePort: 54000, State: "ESTABLISHED", Inode: 21002},
+		{Protocol: "tcp6", LocalAddr: "2001:db8::1:2", LocalPort: 80, RemoteAddr: "2001:db8::7", RemotePort: 57764, State: "TIME_WAIT", Inode: 0},
+		{Protocol: "udp6", LocalAddr: "fe80::1c2d:3eff:fe4f:5a6b", LocalPort: 546, RemoteAddr: "::", RemotePort: 0, State: "CLOSE", Inode: 21003},
+	}
+	got := append(c.parseNetworkFile(tcp6), c.parseNetworkFile(udp6)...)
+	if len(got) != len(want) {
+		t.Fatalf("parsed %d connections: %+v", len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("connection %d = %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+
+	if conns := c.parseNetworkFile(filepath.Join(dir, "missing6")); len(conns) != 0 {
+		t.Errorf("missing file returned %d connections", len(conns))
+	}
+}
@@ -0,0 +1,313 @@
+package collector
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// 文件事件类型
+const (
+	FileAdded    = "added"
+	FileRemoved  = "removed"
+	FileModified = "modified"
+)
+
+// maxHashSize 计算哈希的最大文件大小，超过后只比较元数据
+const maxHashSize = 256 << 20
+
+// FileInfo 文件元数据
+type FileInfo struct {
+	Path    string    `json:"path"`             // 文件路径
+	Size    int64     `json:"size"`             // 文件大小
+	Mode    string    `json:"mode"`             // 文件权限
+	UID     uint32    `json:"uid"`              // 所有者 UID
+	GID     uint32    `json:"gid"`              // 所属组 GID
+	Owner   string    `json:"owner"`            // 所有者用户名
+	ModTime time.Time `json:"mod_time"`         // 修改时间
+	SHA256  string    `json:"sha256,omitempty"` // 文件内容哈希
+	Link    string    `json:"link,omitempty"`   // 符号链接目标
+
+	inode uint64 // inode 号
+	ctime int64  // 状态变更时间（纳秒）
+}
+
+// FileEvent 文件变更事件
+type FileEvent struct {
+	Type      string    `json:"type"`              // 事件类型（added/removed/modified）
+	Path      string    `json:"path"`              // 文件路径
+	Old       *FileInfo `json:"old,omitempty"`     // 变更前的元数据
+	New       *FileInfo `json:"new,omitempty"`     // 变更后的元数据
+	Changes   []string  `json:"changes,omitempty"` // 变更的字段
+	Timestamp time.Time `json:"timestamp"`         // 发现时间
+}
+
+// fileMonitor 文件完整性监控
+type fileMonitor struct {
+	paths        []string            // 监控路径
+	baselinePath string              // 基线保存位置，为空时不保存
+	baseline     map[string]FileInfo // 文件基线
+}
+
+// newFileMonitor 创建文件完整性监控，baselinePath 不为空时基线保存到该文件
+func newFileMonitor(paths []string, baselinePath string) *fileMonitor {
+	return &fileMonitor{paths: paths, baselinePath: baselinePath}
+}
+
+// scan 扫描监控路径并与基线比较。首次调用时与上次运行保存的基线比较，
+// 从而发现代理停止期间的变更；没有保存的基线时只建立基线
+func (m *fileMonitor) scan() []FileEvent {
+	if m.baseline == nil {
+		m.baseline = m.loadBaseline()
+	}
+
+	current := make(map[string]FileInfo)
+	for _, root := range m.paths {
+		m.walk(root, current)
+	}
+
+	if m.baseline == nil {
+		m.baseline = current
+		log.Printf("File integrity baseline built: %d files", len(current))
+		m.saveBaseline()
+		return nil
+	}
+
+	events := diffFiles(m.baseline, current, time.Now())
+	m.baseline = current
+	if len(events) > 0 {
+		m.saveBaseline()
+	}
+	return events
+}
+
+// savedBaseline 基线文件格式
+type savedBaseline struct {
+	Paths []string                 `json:"paths"` // 建立基线时的监控路径
+	Files map[string]baselineEntry `json:"files"`
+}
+
+// baselineEntry 基线中的文件，包含用于判断能否复用哈希的 inode 和 ctime
+type baselineEntry struct {
+	FileInfo
+	Inode uint64 `json:"inode"`
+	Ctime int64  `json:"ctime"`
+}
+
+// loadBaseline 读取上次运行保存的基线，不存在、无法解析或监控路径已改变时返回 nil
+func (m *fileMonitor) loadBaseline() map[string]FileInfo {
+	if m.baselinePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(m.baselinePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read file integrity baseline: %v", err)
+		}
+		return nil
+	}
+
+	var saved savedBaseline
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Printf("Failed to parse file integrity baseline %s: %v, rebuilding", m.baselinePath, err)
+		return nil
+	}
+	if !reflect.DeepEqual(saved.Paths, m.paths) {
+		log.Printf("Watch paths changed since %s was saved, rebuilding file integrity baseline", m.baselinePath)
+		return nil
+	}
+
+	baseline := make(map[string]FileInfo, len(saved.Files))
+	for path, entry := range saved.Files {
+		info := entry.FileInfo
+		info.Path = path
+		info.inode, info.ctime = entry.Inode, entry.Ctime
+		baseline[path] = info
+	}
+	log.Printf("Loaded file integrity baseline: %d files", len(baseline))
+	return baseline
+}
+
+// saveBaseline 保存当前基线，仅所有者可读写
+func (m *fileMonitor) saveBaseline() {
+	if m.baselinePath == "" {
+		return
+	}
+
+	saved := savedBaseline{Paths: m.paths, Files: make(map[string]baselineEntry, len(m.baseline))}
+	for path, info := range m.baseline {
+		saved.Files[path] = baselineEntry{FileInfo: info, Inode: info.inode, Ctime: info.ctime}
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		log.Printf("Failed to encode file integrity baseline: %v", err)
+		return
+	}
+
+	tmp := m.baselinePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		log.Printf("Failed to save file integrity baseline: %v", err)
+		return
+	}
+	if err := os.Rename(tmp, m.baselinePath); err != nil {
+		log.Printf("Failed to save file integrity baseline: %v", err)
+		os.Remove(tmp)
+	}
+}
+
+// walk 遍历单个监控路径
+func (m *fileMonitor) walk(root string, current map[string]FileInfo) {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 无权限或遍历期间被删除的条目直接跳过
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := m.stat(path)
+		if err != nil {
+			return nil
+		}
+		current[path] = info
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to scan watch path %s: %v", root, err)
+	}
+}
+
+// stat 获取文件元数据，内容未变化时复用基线中的哈希
+func (m *fileMonitor) stat(path string) (FileInfo, error) {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	info := FileInfo{
+		Path:    path,
+		Size:    fi.Size(),
+		Mode:    fi.Mode().String(),
+		ModTime: fi.ModTime(),
+	}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		info.UID = st.Uid
+		info.GID = st.Gid
+		info.inode = st.Ino
+		info.ctime = st.Ctim.Nano()
+	}
+	info.Owner = lookupUser(info.UID)
+
+	switch {
+	case fi.Mode()&fs.ModeSymlink != 0:
+		info.Link, _ = os.Readlink(path)
+	case fi.Mode().IsRegular():
+		// ctime 无法被用户态伪造，只有 inode、大小、mtime、ctime 全部一致时才复用哈希
+		if old, ok := m.baseline[path]; ok && old.SHA256 != "" &&
+			old.inode == info.inode && old.Size == info.Size &&
+			old.ModTime.Equal(info.ModTime) && old.ctime == info.ctime {
+			info.SHA256 = old.SHA256
+		} else if info.Size <= maxHashSize {
+			info.SHA256, _ = hashFile(path)
+		}
+	}
+
+	return info, nil
+}
+
+// hashFile 计算文件的 SHA-256
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// diffFiles 比较两次扫描结果，生成按路径排序的变更事件
+func diffFiles(old, current map[string]FileInfo, now time.Time) []FileEvent {
+	var events []FileEvent
+
+	for path, newInfo := range current {
+		oldInfo, exists := old[path]
+		if !exists {
+			n := newInfo
+			events = append(events, FileEvent{Type: FileAdded, Path: path, New: &n, Timestamp: now})
+			continue
+		}
+
+		if changes := compareFiles(oldInfo, newInfo); len(changes) > 0 {
+			o, n := oldInfo, newInfo
+			events = append(events, FileEvent{
+				Type:      FileModified,
+				Path:      path,
+				Old:       &o,
+				New:       &n,
+				Changes:   changes,
+				Timestamp: now,
+			})
+		}
+	}
+
+	for path, oldInfo := range old {
+		if _, exists := current[path]; !exists {
+			o := oldInfo
+			events = append(events, FileEvent{Type: FileRemoved, Path: path, Old: &o, Timestamp: now})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Path < events[j].Path
+	})
+
+	return events
+}
+
+// compareFiles 返回发生变化的字段
+func compareFiles(a, b FileInfo) []string {
+	var changes []string
+
+	if a.Size != b.Size {
+		changes = append(changes, "size")
+	}
+	if a.Mode != b.Mode {
+		changes = append(changes, "mode")
+	}
+	if a.UID != b.UID || a.GID != b.GID {
+		changes = append(changes, "owner")
+	}
+	if !a.ModTime.Equal(b.ModTime) {
+		changes = append(changes, "mtime")
+	}
+	if a.SHA256 != b.SHA256 {
+		changes = append(changes, "sha256")
+	}
+	if a.Link != b.Link {
+		changes = append(changes, "link")
+	}
+	if a.inode != b.inode {
+		changes = append(changes, "inode")
+	}
+
+	return changes
+}
@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileMonitorPersistedBaseline(t *testing.T) {
+	dir := t.TempDir()
+	watch := filepath.Join(dir, "watch")
+	if err := os.Mkdir(watch, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(watch, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("passwd", "root:x:0:0")
+	write("hosts", "127.0.0.1 localhost")
+	baselinePath := filepath.Join(dir, "fim-baseline.json")
+
+	// 首次运行只建立基线并保存
+	if events := newFileMonitor([]string{watch}, baselinePath).scan(); len(events) != 0 {
+		t.Fatalf("first scan returned %d events", len(events))
+	}
+	info, err := os.Stat(baselinePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("baseline mode = %o, want 600", mode)
+	}
+
+	// 代理停止期间的变更在重启后的首次扫描中报告
+	write("passwd", "root:x:0:0\nevil:x:0:0")
+	write("cron", "* * * * * root sh")
+	if err := os.Remove(filepath.Join(watch, "hosts")); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newFileMonitor([]string{watch}, baselinePath)
+	events := restarted.scan()
+	want := []struct{ typ, name string }{
+		{FileAdded, "cron"},
+		{FileRemoved, "hosts"},
+		{FileModified, "passwd"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("scan after restart returned %+v", events)
+	}
+	for i, w := range want {
+		if events[i].Type != w.typ || events[i].Path != filepath.Join(watch, w.name) {
+			t.Errorf("event %d = %s %s, want %s %s", i, events[i].Type, events[i].Path, w.typ, w.name)
+		}
+	}
+	if changes := events[2].Changes; len(changes) == 0 || changes[0] != "size" {
+		t.Errorf("passwd changes = %v", changes)
+	}
+
+	// 保存的基线已更新，再次重启不重复报告
+	if events := newFileMonitor([]string{watch}, baselinePath).scan(); len(events) != 0 {
+		t.Errorf("second restart returned %+v", events)
+	}
+
+	// 监控路径改变后重新建立基线
+	if events := newFileMonitor([]string{watch, dir}, baselinePath).scan(); len(events) != 0 {
+		t.Errorf("scan with new watch paths returned %d events", len(events))
+	}
+}
+
+func TestFileMonitorCorruptBaseline(t *testing.T) {
+	dir := t.TempDir()
+	baselinePath := filepath.Join(dir, "fim-baseline.json")
+	if err := os.WriteFile(baselinePath, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "file"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newFileMonitor([]string{dir}, baselinePath)
+	if events := m.scan(); len(events) != 0 {
+		t.Errorf("scan with corrupt baseline returned %d events", len(events))
+	}
+	if m.loadBaseline() == nil {
+		t.Error("baseline was not rewritten after rebuilding")
+	}
+}
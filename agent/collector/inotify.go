@@ -0,0 +1,299 @@
+package collector
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// 实时文件事件类型
+const (
+	WatchCreate   = "create"
+	WatchModify   = "modify"
+	WatchDelete   = "delete"
+	WatchAttrib   = "attrib"
+	WatchMoveFrom = "move_from"
+	WatchMoveTo   = "move_to"
+	WatchOverflow = "overflow"
+)
+
+// watchMask 需要关注的 inotify 事件
+const watchMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_DELETE | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF |
+	syscall.IN_DONT_FOLLOW
+
+// WatchEvent 实时文件变更事件
+type WatchEvent struct {
+	Op        string    `json:"op"`               // 事件类型
+	Path      string    `json:"path"`             // 文件路径
+	IsDir     bool      `json:"is_dir"`           // 是否为目录
+	Cookie    uint32    `json:"cookie,omitempty"` // 关联 move_from/move_to 的标识
+	Timestamp time.Time `json:"timestamp"`        // 事件时间
+}
+
+// inotifyWatcher 基于 inotify 的递归文件监控
+type inotifyWatcher struct {
+	fd      int                       // inotify 文件描述符
+	file    *os.File                  // 包装 fd 以便由 runtime 轮询
+	handler func(WatchEvent)          // 事件回调
+	mu      sync.Mutex                // 保护以下字段
+	watches map[int32]string          // wd 到目录路径的映射
+	filters map[int32]map[string]bool // 单文件监控时允许的文件名，nil 表示不过滤
+	limited bool                      // 是否已达到内核 watch 数量上限
+
+	last WatchEvent // 上一个事件，用于合并连续的重复事件
+}
+
+// newInotifyWatcher 创建 inotify 监控
+func newInotifyWatcher(handler func(WatchEvent)) (*inotifyWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	// 非阻塞描述符交给 runtime 轮询，Close 时可以唤醒阻塞中的 Read
+	return &inotifyWatcher{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		handler: handler,
+		watches: make(map[int32]string),
+		filters: make(map[int32]map[string]bool),
+	}, nil
+}
+
+// Add 添加监控路径，目录会被递归监控，普通文件通过其父目录监控
+func (w *inotifyWatcher) Add(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		dir, name := filepath.Split(path)
+		wd, err := w.addWatch(filepath.Clean(dir))
+		if err != nil {
+			return err
+		}
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if filter, ok := w.filters[wd]; ok && filter != nil {
+			filter[name] = true
+		} else if !ok {
+			w.filters[wd] = map[string]bool{name: true}
+		}
+		return nil
+	}
+
+	w.addRecursive(path, false)
+	return nil
+}
+
+// addRecursive 递归监控目录，emit 为 true 时为目录中已存在的文件补发 create 事件
+func (w *inotifyWatcher) addRecursive(root string, emit bool) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		if emit && path != root {
+			w.emit(WatchEvent{Op: WatchCreate, Path: path, IsDir: d.IsDir(), Timestamp: time.Now()})
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		wd, err := w.addWatch(path)
+		if err != nil {
+			if errors.Is(err, syscall.ENOSPC) {
+				return filepath.SkipAll
+			}
+			return filepath.SkipDir
+		}
+
+		// 目录监控不过滤文件名
+		w.mu.Lock()
+		w.filters[wd] = nil
+		w.mu.Unlock()
+		return nil
+	})
+}
+
+// addWatch 为单个目录添加 watch
+func (w *inotifyWatcher) addWatch(dir string) (int32, error) {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, watchMask)
+	if err != nil {
+		if errors.Is(err, syscall.ENOSPC) {
+			w.mu.Lock()
+			if !w.limited {
+				w.limited = true
+				log.Printf("inotify watch limit reached at %s, raise fs.inotify.max_user_watches; remaining paths fall back to polling", dir)
+			}
+			w.mu.Unlock()
+		}
+		return -1, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.watches[int32(wd)] = dir
+	return int32(wd), nil
+}
+
+// Run 读取并分发 inotify 事件，直到 Close 被调用
+func (w *inotifyWatcher) Run() {
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				log.Printf("inotify read failed: %v", err)
+			}
+			return
+		}
+
+		w.parse(buf[:n])
+	}
+}
+
+// parse 解析一次读取到的全部 inotify_event
+func (w *inotifyWatcher) parse(buf []byte) {
+	now := time.Now()
+
+	for offset := 0; offset+syscall.SizeofInotifyEvent <= len(buf); {
+		raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + syscall.SizeofInotifyEvent
+		nameEnd := nameStart + int(raw.Len)
+		if nameEnd > len(buf) {
+			return
+		}
+
+		name := string(buf[nameStart:nameEnd])
+		for len(name) > 0 && name[len(name)-1] == 0 {
+			name = name[:len(name)-1]
+		}
+
+		w.handle(raw.Wd, raw.Mask, raw.Cookie, name, now)
+		offset = nameEnd
+	}
+}
+
+// handle 处理单个事件
+func (w *inotifyWatcher) handle(wd int32, mask, cookie uint32, name string, now time.Time) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		log.Println("inotify event queue overflowed, some file events were lost")
+		w.emit(WatchEvent{Op: WatchOverflow, Timestamp: now})
+		return
+	}
+
+	w.mu.Lock()
+	dir, ok := w.watches[wd]
+	filter := w.filters[wd]
+	w.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	// 内核已移除该 watch（目录被删除或文件系统被卸载）
+	if mask&syscall.IN_IGNORED != 0 {
+		w.remove(wd)
+		return
+	}
+
+	if filter != nil && !filter[name] {
+		return
+	}
+
+	path := dir
+	if name != "" {
+		path = filepath.Join(dir, name)
+	}
+	isDir := mask&syscall.IN_ISDIR != 0
+
+	var op string
+	switch {
+	case mask&syscall.IN_CREATE != 0:
+		op = WatchCreate
+	case mask&syscall.IN_MODIFY != 0:
+		op = WatchModify
+	case mask&(syscall.IN_DELETE|syscall.IN_DELETE_SELF) != 0:
+		op = WatchDelete
+	case mask&syscall.IN_ATTRIB != 0:
+		op = WatchAttrib
+	case mask&(syscall.IN_MOVED_FROM|syscall.IN_MOVE_SELF) != 0:
+		op = WatchMoveFrom
+	case mask&syscall.IN_MOVED_TO != 0:
+		op = WatchMoveTo
+	default:
+		return
+	}
+
+	// 自身删除事件对应的父目录事件已经上报过
+	if mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 {
+		return
+	}
+
+	w.emit(WatchEvent{Op: op, Path: path, IsDir: isDir, Cookie: cookie, Timestamp: now})
+
+	if !isDir || filter != nil {
+		return
+	}
+
+	switch op {
+	case WatchCreate, WatchMoveTo:
+		// 新建或移入的子目录需要加入监控
+		w.addRecursive(path, true)
+	case WatchMoveFrom:
+		// 移出的子目录路径已失效，移入后会重新添加
+		w.forget(path)
+	}
+}
+
+// forget 移除目录及其子目录的全部 watch
+func (w *inotifyWatcher) forget(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	prefix := dir + string(filepath.Separator)
+	for wd, path := range w.watches {
+		if path == dir || strings.HasPrefix(path, prefix) {
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.watches, wd)
+			delete(w.filters, wd)
+		}
+	}
+}
+
+// remove 清理已失效的 watch
+func (w *inotifyWatcher) remove(wd int32) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.watches, wd)
+	delete(w.filters, wd)
+}
+
+// emit 分发事件，合并短时间内连续的相同事件（例如大文件写入产生的多次 modify）
+func (w *inotifyWatcher) emit(event WatchEvent) {
+	if event.Op == w.last.Op && event.Path == w.last.Path && event.Op != WatchOverflow &&
+		event.Timestamp.Sub(w.last.Timestamp) < time.Second {
+		return
+	}
+	w.last = event
+
+	w.handler(event)
+}
+
+// Close 停止监控
+func (w *inotifyWatcher) Close() error {
+	return w.file.Close()
+}
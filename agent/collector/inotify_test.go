@@ -0,0 +1,146 @@
+package collector
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// packInotifyEvent 按 struct inotify_event 打包：wd、mask、cookie、len 后跟以 NUL 填充的文件名
+func packInotifyEvent(wd int32, mask, cookie uint32, name string, padded int) []byte {
+	b := make([]byte, 16+padded)
+	binary.NativeEndian.PutUint32(b[0:], uint32(wd))
+	binary.NativeEndian.PutUint32(b[4:], mask)
+	binary.NativeEndian.PutUint32(b[8:], cookie)
+	binary.NativeEndian.PutUint32(b[12:], uint32(padded))
+	copy(b[16:], name)
+	return b
+}
+
+// newTestWatcher 不打开 inotify 的监控，只用于测试事件解析
+func newTestWatcher(events *[]WatchEvent) *inotifyWatcher {
+	return &inotifyWatcher{
+		fd:      -1,
+		handler: func(ev WatchEvent) { *events = append(*events, ev) },
+		watches: map[int32]string{1: "/etc", 2: "/home/alice"},
+		filters: map[int32]map[string]bool{1: {"passwd": true, "shadow": true}, 2: nil},
+	}
+}
+
+func TestInotifyParse(t *testing.T) {
+	if syscall.SizeofInotifyEvent != 16 {
+		t.Fatalf("SizeofInotifyEvent = %d", syscall.SizeofInotifyEvent)
+	}
+
+	var buf []byte
+	// 文件名补齐到 16 字节
+	buf = append(buf, packInotifyEvent(1, syscall.IN_MODIFY, 0, "passwd", 16)...)
+	// 不在过滤列表中的文件被忽略
+	buf = append(buf, packInotifyEvent(1, syscall.IN_MODIFY, 0, "hosts", 16)...)
+	// 名称恰好 16 字节时补齐到 32 字节
+	buf = append(buf, packInotifyEvent(2, syscall.IN_CREATE, 0, "0123456789abcdef", 32)...)
+	buf = append(buf, packInotifyEvent(2, syscall.IN_MOVED_FROM, 77, ".bashrc", 16)...)
+	buf = append(buf, packInotifyEvent(2, syscall.IN_MOVED_TO, 77, ".bashrc.bak", 16)...)
+	buf = append(buf, packInotifyEvent(1, syscall.IN_ATTRIB, 0, "shadow", 16)...)
+	buf = append(buf, packInotifyEvent(2, syscall.IN_DELETE, 0, "notes.txt", 16)...)
+	// 连续相同的事件合并
+	buf = append(buf, packInotifyEvent(2, syscall.IN_DELETE, 0, "notes.txt", 16)...)
+	// 未知 wd 和自身删除事件不上报
+	buf = append(buf, packInotifyEvent(9, syscall.IN_CREATE, 0, "x", 16)...)
+	buf = append(buf, packInotifyEvent(2, syscall.IN_DELETE_SELF, 0, "", 0)...)
+	// 队列溢出
+	buf = append(buf, packInotifyEvent(-1, syscall.IN_Q_OVERFLOW, 0, "", 0)...)
+	// 内核移除 watch 后清理映射
+	buf = append(buf, packInotifyEvent(2, syscall.IN_IGNORED, 0, "", 0)...)
+	// 被截断的事件不解析
+	buf = append(buf, packInotifyEvent(1, syscall.IN_MODIFY, 0, "passwd", 16)[:20]...)
+
+	var events []WatchEvent
+	w := newTestWatcher(&events)
+	w.parse(buf)
+
+	type result struct {
+		op, path string
+		cookie   uint32
+	}
+	var got []result
+	for _, ev := range events {
+		got = append(got, result{ev.Op, ev.Path, ev.Cookie})
+	}
+	want := []result{
+		{WatchModify, "/etc/passwd", 0},
+		{WatchCreate, "/home/alice/0123456789abcdef", 0},
+		{WatchMoveFrom, "/home/alice/.bashrc", 77},
+		{WatchMoveTo, "/home/alice/.bashrc.bak", 77},
+		{WatchAttrib, "/etc/shadow", 0},
+		{WatchDelete, "/home/alice/notes.txt", 0},
+		{WatchOverflow, "", 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events:\n got  %v\n want %v", got, want)
+	}
+	if _, ok := w.watches[2]; ok {
+		t.Error("watch not removed after IN_IGNORED")
+	}
+	if _, ok := w.watches[1]; !ok {
+		t.Error("unrelated watch removed")
+	}
+}
+
+func TestInotifyWatcher(t *testing.T) {
+	dir := t.TempDir()
+	events := make(chan WatchEvent, 64)
+	w, err := newInotifyWatcher(func(ev WatchEvent) { events <- ev })
+	if err != nil {
+		t.Skipf("inotify unavailable: %v", err)
+	}
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		w.Run()
+		close(done)
+	}()
+	defer func() {
+		w.Close()
+		<-done
+	}()
+
+	expect := func(op, path string) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case ev := <-events:
+				if ev.Op == op && ev.Path == path {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("no %s event for %s", op, path)
+			}
+		}
+	}
+
+	// 新建子目录被加入监控，其中的文件变更同样上报
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	expect(WatchCreate, sub)
+
+	file := filepath.Join(sub, "file")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect(WatchCreate, file)
+
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	expect(WatchDelete, file)
+}
@@ -0,0 +1,161 @@
+package collector
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"syscall"
+)
+
+// 单行日志的最大长度，超出的部分被丢弃
+const maxLogLine = 64 * 1024
+
+// maxLogRead 每次轮询最多读取的字节数，日志暴增时分多次读完
+const maxLogRead = 4 << 20
+
+// logTailer 跟踪日志文件的新增内容，处理 logrotate 的改名轮转和 copytruncate 截断
+//
+// 轮转后先读完旧文件剩余的内容，再从头读取新文件。文本日志按行切分，wtmp 等二进制日志按固定长度的记录切分。
+type logTailer struct {
+	path       string
+	recordSize int // 二进制记录长度，为 0 时按行切分
+	file       *os.File
+	dev        uint64
+	ino        uint64
+	offset     int64
+	partial    []byte // 尚未读完的不完整行或记录
+	started    bool   // 首次打开时从文件末尾开始，不重放历史日志
+}
+
+// newLogTailer 创建文本日志跟踪
+func newLogTailer(path string) *logTailer {
+	return &logTailer{path: path}
+}
+
+// newRecordTailer 创建固定长度记录的二进制日志跟踪
+func newRecordTailer(path string, recordSize int) *logTailer {
+	return &logTailer{path: path, recordSize: recordSize}
+}
+
+// poll 返回上次轮询以来新增的完整行或记录，行不含换行符
+func (t *logTailer) poll() ([][]byte, error) {
+	if t.file == nil {
+		if err := t.open(); err != nil {
+			if os.IsNotExist(err) {
+				t.started = true
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+
+	lines, err := t.read()
+	if err != nil {
+		return lines, err
+	}
+	// 单次读取量达到上限，当前文件还没读完，下次轮询再检查轮转
+	if current, err := t.file.Stat(); err == nil && current.Size() > t.offset {
+		return lines, nil
+	}
+
+	info, err := os.Stat(t.path)
+	switch {
+	case err != nil && os.IsNotExist(err):
+		// 已被改名且新文件尚未创建，保留旧文件等待后续写入
+		return lines, nil
+	case err != nil:
+		return lines, err
+	}
+
+	st, _ := info.Sys().(*syscall.Stat_t)
+	if st != nil && (uint64(st.Dev) != t.dev || st.Ino != t.ino) {
+		// 已轮转：旧文件在上面读完，切换到新文件从头读取
+		t.close()
+		if err := t.open(); err != nil {
+			return lines, err
+		}
+		more, err := t.read()
+		return append(lines, more...), err
+	}
+	if info.Size() < t.offset {
+		// copytruncate 截断了文件
+		t.offset = 0
+		t.partial = nil
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			return lines, err
+		}
+		more, err := t.read()
+		return append(lines, more...), err
+	}
+	return lines, nil
+}
+
+// open 打开日志文件，首次打开时定位到末尾
+func (t *logTailer) open() error {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	t.file = file
+	t.offset = 0
+	t.partial = nil
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		t.dev, t.ino = uint64(st.Dev), st.Ino
+	}
+	if !t.started {
+		t.started = true
+		end := info.Size()
+		if t.recordSize > 0 {
+			end -= end % int64(t.recordSize)
+		}
+		t.offset, err = file.Seek(end, io.SeekStart)
+		return err
+	}
+	return nil
+}
+
+// read 读取到文件末尾，返回完整的行或记录
+func (t *logTailer) read() ([][]byte, error) {
+	buf, err := io.ReadAll(io.LimitReader(t.file, maxLogRead))
+	t.offset += int64(len(buf))
+	if len(buf) == 0 {
+		return nil, err
+	}
+
+	var records [][]byte
+	data := append(t.partial, buf...)
+	if t.recordSize > 0 {
+		for len(data) >= t.recordSize {
+			records = append(records, data[:t.recordSize])
+			data = data[t.recordSize:]
+		}
+	} else {
+		for {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			records = append(records, bytes.TrimRight(data[:i], "\r"))
+			data = data[i+1:]
+		}
+		if len(data) > maxLogLine {
+			data = nil
+		}
+	}
+	t.partial = append([]byte(nil), data...)
+	return records, err
+}
+
+// close 关闭当前文件
+func (t *logTailer) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// appendFile 向文件追加内容
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pollLines 轮询一次并返回字符串形式的行
+func pollLines(t *testing.T, tailer *logTailer) []string {
+	t.Helper()
+	records, err := tailer.poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, r := range records {
+		lines = append(lines, string(r))
+	}
+	return lines
+}
+
+func expectLines(t *testing.T, step string, got []string, want ...string) {
+	t.Helper()
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s: got %q, want %q", step, got, want)
+	}
+}
+
+func TestLogTailerRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.log")
+	appendFile(t, path, "history\n")
+
+	tailer := newLogTailer(path)
+	defer tailer.close()
+
+	// 首次打开从末尾开始，不重放历史日志
+	expectLines(t, "first poll", pollLines(t, tailer))
+
+	appendFile(t, path, "one\ntwo\r\npart")
+	expectLines(t, "append", pollLines(t, tailer), "one", "two")
+	appendFile(t, path, "ial\n")
+	expectLines(t, "partial line", pollLines(t, tailer), "partial")
+
+	// logrotate 改名轮转：先读完旧文件，再从头读取新文件
+	appendFile(t, path, "before rotate\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path+".1", "late write\n")
+	expectLines(t, "renamed without new file", pollLines(t, tailer), "before rotate", "late write")
+
+	appendFile(t, path, "new file\n")
+	appendFile(t, path+".1", "last old\n")
+	expectLines(t, "rotate", pollLines(t, tailer), "last old", "new file")
+
+	appendFile(t, path, "after rotate\n")
+	expectLines(t, "after rotate", pollLines(t, tailer), "after rotate")
+}
+
+func TestLogTailerCopyTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secure")
+	appendFile(t, path, "history\n")
+
+	tailer := newLogTailer(path)
+	defer tailer.close()
+	pollLines(t, tailer)
+
+	appendFile(t, path, "one\ntwo\n")
+	expectLines(t, "append", pollLines(t, tailer), "one", "two")
+
+	// copytruncate：文件被截断后从头读取
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "x\n")
+	expectLines(t, "truncate", pollLines(t, tailer), "x")
+
+	appendFile(t, path, "three\n")
+	expectLines(t, "after truncate", pollLines(t, tailer), "three")
+}
+
+func TestLogTailerMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	tailer := newLogTailer(path)
+	defer tailer.close()
+
+	// 启动时不存在的文件出现后从头读取
+	expectLines(t, "missing", pollLines(t, tailer))
+	appendFile(t, path, "created\n")
+	expectLines(t, "created", pollLines(t, tailer), "created")
+}
+
+func TestRecordTailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wtmp")
+	appendFile(t, path, "AAAAB") // 首次打开时定位到最后一条完整记录之后
+
+	tailer := newRecordTailer(path, 4)
+	defer tailer.close()
+	expectLines(t, "first poll", pollLines(t, tailer))
+
+	appendFile(t, path, "BBBCCCCDD")
+	expectLines(t, "records", pollLines(t, tailer), "BBBB", "CCCC")
+	appendFile(t, path, "DD")
+	expectLines(t, "completed record", pollLines(t, tailer), "DDDD")
+}
@@ -0,0 +1,365 @@
+package collector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 进程事件类型
+const (
+	ProcExec     = "exec"
+	ProcExit     = "exit"
+	ProcOverflow = "overflow"
+)
+
+// 进程事件来源
+const (
+	SourceNetlink = "netlink" // 内核 proc connector 实时事件
+	SourcePoll    = "poll"    // 无法使用 proc connector 时轮询 /proc 得到的事件
+)
+
+// ProcessEvent 进程执行/退出事件，exec 事件的字段在事件发生时从 /proc 读取
+type ProcessEvent struct {
+	Op         string    `json:"op"`                    // 事件类型
+	PID        int       `json:"pid"`                   // 进程ID
+	PPID       int       `json:"ppid"`                  // 父进程ID
+	ParentName string    `json:"parent_name,omitempty"` // 父进程名称
+	Name       string    `json:"name,omitempty"`        // 进程名称
+	Exe        string    `json:"exe,omitempty"`         // 可执行文件路径
+	Cmdline    string    `json:"cmdline,omitempty"`     // 命令行
+	UID        uint32    `json:"uid"`                   // 有效用户ID
+	User       string    `json:"user,omitempty"`        // 用户名
+	Cwd        string    `json:"cwd,omitempty"`         // 工作目录
+	ExitCode   int       `json:"exit_code,omitempty"`   // 退出码，被信号终止时为 128+信号
+	Source     string    `json:"source"`                // 事件来源（netlink/poll）
+	Timestamp  time.Time `json:"timestamp"`             // 事件时间
+}
+
+// proc connector 协议常量（linux/connector.h、linux/cn_proc.h）
+const (
+	netlinkConnector = 11 // NETLINK_CONNECTOR
+	cnIdxProc        = 1  // CN_IDX_PROC
+	cnValProc        = 1  // CN_VAL_PROC
+
+	procCnMcastListen = 1 // PROC_CN_MCAST_LISTEN
+
+	procEventFork = 0x00000001
+	procEventExec = 0x00000002
+	procEventExit = 0x80000000
+
+	sizeofCnMsg = 20 // struct cn_msg: cb_id(8) seq(4) ack(4) len(2) flags(2)
+)
+
+// maxTrackedProcs 跟踪的进程数量上限，超出时清空以免内存无限增长
+const maxTrackedProcs = 65536
+
+// procConnector 基于 netlink proc connector 的进程事件监控
+type procConnector struct {
+	fd      int
+	file    *os.File
+	handler func(ProcessEvent)
+
+	parents map[int]int          // fork 事件记录的父进程，进程退出后 /proc 已不可读时使用
+	execs   map[int]ProcessEvent // 已上报 exec 的进程，只为这些进程上报 exit
+}
+
+// newProcConnector 打开 proc connector 并订阅进程事件，需要 root 权限
+func newProcConnector(handler func(ProcessEvent)) (*procConnector, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, netlinkConnector)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: cnIdxProc}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("bind: %w", err)
+	}
+
+	// 订阅消息：nlmsghdr + cn_msg + PROC_CN_MCAST_LISTEN
+	msg := make([]byte, syscall.NLMSG_HDRLEN+sizeofCnMsg+4)
+	binary.LittleEndian.PutUint32(msg[0:], uint32(len(msg)))
+	binary.LittleEndian.PutUint16(msg[4:], syscall.NLMSG_DONE)
+	binary.LittleEndian.PutUint32(msg[12:], uint32(os.Getpid()))
+	cn := msg[syscall.NLMSG_HDRLEN:]
+	binary.LittleEndian.PutUint32(cn[0:], cnIdxProc)
+	binary.LittleEndian.PutUint32(cn[4:], cnValProc)
+	binary.LittleEndian.PutUint16(cn[16:], 4)
+	binary.LittleEndian.PutUint32(cn[sizeofCnMsg:], procCnMcastListen)
+
+	if err := syscall.Sendto(fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("subscribe: %w", err)
+	}
+
+	// 非阻塞描述符交给 runtime 轮询，Close 时可以唤醒阻塞中的 Read
+	return &procConnector{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "proc-connector"),
+		handler: handler,
+		parents: make(map[int]int),
+		execs:   make(map[int]ProcessEvent),
+	}, nil
+}
+
+// Run 读取并分发进程事件，直到 Close 被调用
+func (p *procConnector) Run() {
+	buf := make([]byte, 64*1024)
+
+	for {
+		n, err := p.file.Read(buf)
+		if err != nil {
+			if errors.Is(err, syscall.ENOBUFS) {
+				// 接收缓冲区溢出，期间的事件已丢失
+				log.Println("proc connector receive buffer overflowed, some process events were lost")
+				p.handler(ProcessEvent{Op: ProcOverflow, Source: SourceNetlink, Timestamp: time.Now()})
+				continue
+			}
+			if !errors.Is(err, os.ErrClosed) {
+				log.Printf("proc connector read failed: %v", err)
+			}
+			return
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, msg := range msgs {
+			p.parse(msg.Data)
+		}
+	}
+}
+
+// parse 解析 cn_msg 中的 proc_event
+func (p *procConnector) parse(data []byte) {
+	if len(data) < sizeofCnMsg+16 {
+		return
+	}
+	if binary.LittleEndian.Uint32(data[0:]) != cnIdxProc || binary.LittleEndian.Uint32(data[4:]) != cnValProc {
+		return
+	}
+
+	// proc_event: what(4) cpu(4) timestamp_ns(8) event_data
+	ev := data[sizeofCnMsg:]
+	what := binary.LittleEndian.Uint32(ev[0:])
+	body := ev[16:]
+	now := time.Now()
+
+	switch what {
+	case procEventFork:
+		// parent_pid parent_tgid child_pid child_tgid，忽略线程创建
+		if len(body) < 16 {
+			return
+		}
+		parent := int(binary.LittleEndian.Uint32(body[4:]))
+		childPID := int(binary.LittleEndian.Uint32(body[8:]))
+		childTGID := int(binary.LittleEndian.Uint32(body[12:]))
+		if childPID != childTGID {
+			return
+		}
+		if len(p.parents) >= maxTrackedProcs {
+			p.parents = make(map[int]int)
+		}
+		p.parents[childTGID] = parent
+
+	case procEventExec:
+		// process_pid process_tgid
+		if len(body) < 8 {
+			return
+		}
+		pid := int(binary.LittleEndian.Uint32(body[4:]))
+		event := readExecEvent(pid, now)
+		event.Source = SourceNetlink
+		if event.PPID == 0 {
+			event.PPID = p.parents[pid]
+			event.ParentName = readProcComm(event.PPID)
+		}
+		if len(p.execs) >= maxTrackedProcs {
+			p.execs = make(map[int]ProcessEvent)
+		}
+		p.execs[pid] = event
+		p.handler(event)
+
+	case procEventExit:
+		// process_pid process_tgid exit_code exit_signal，只关注整个进程退出
+		if len(body) < 16 {
+			return
+		}
+		pid := int(binary.LittleEndian.Uint32(body[0:]))
+		tgid := int(binary.LittleEndian.Uint32(body[4:]))
+		if pid != tgid {
+			return
+		}
+		delete(p.parents, tgid)
+		exec, ok := p.execs[tgid]
+		if !ok {
+			return
+		}
+		delete(p.execs, tgid)
+
+		p.handler(exitEvent(exec, exitCode(binary.LittleEndian.Uint32(body[8:])), now))
+	}
+}
+
+// Close 停止监控
+func (p *procConnector) Close() error {
+	return p.file.Close()
+}
+
+// exitEvent 根据 exec 事件生成退出事件，保留进程标识便于关联
+func exitEvent(exec ProcessEvent, code int, now time.Time) ProcessEvent {
+	return ProcessEvent{
+		Op:         ProcExit,
+		PID:        exec.PID,
+		PPID:       exec.PPID,
+		ParentName: exec.ParentName,
+		Name:       exec.Name,
+		Exe:        exec.Exe,
+		UID:        exec.UID,
+		User:       exec.User,
+		ExitCode:   code,
+		Source:     exec.Source,
+		Timestamp:  now,
+	}
+}
+
+// exitCode 将 wait 状态转换为 shell 风格的退出码
+func exitCode(status uint32) int {
+	ws := syscall.WaitStatus(status)
+	if ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ws.ExitStatus()
+}
+
+// procPoller 无法使用 proc connector 时定时扫描 /proc，根据进程的出现、消失和名称变化生成事件
+//
+// 两次扫描之间启动并退出的进程无法被发现。
+type procPoller struct {
+	handler func(ProcessEvent)
+	known   map[procKey]string       // 进程 -> 上次看到的名称
+	execs   map[procKey]ProcessEvent // 已上报 exec 的进程，只为这些进程上报 exit
+}
+
+// newProcPoller 创建轮询监控，首次扫描只建立基线
+func newProcPoller(handler func(ProcessEvent)) *procPoller {
+	p := &procPoller{handler: handler, execs: make(map[procKey]ProcessEvent)}
+	p.known = p.scan()
+	return p
+}
+
+// Run 每隔 interval 扫描一次，直到 stopCh 关闭
+func (p *procPoller) Run(interval time.Duration, stopCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.poll()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// scan 读取当前全部进程
+func (p *procPoller) scan() map[procKey]string {
+	current := make(map[procKey]string)
+
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return current
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := readProcStat(pid)
+		if err != nil {
+			continue
+		}
+		current[procKey{pid: pid, startTime: stat.StartTime}] = readProcComm(pid)
+	}
+	return current
+}
+
+// poll 比较两次扫描结果
+func (p *procPoller) poll() {
+	now := time.Now()
+	current := p.scan()
+
+	for key, name := range current {
+		// 新进程或在原进程中 exec 了新程序
+		if prev, ok := p.known[key]; !ok || prev != name {
+			event := readExecEvent(key.pid, now)
+			event.Source = SourcePoll
+			p.execs[key] = event
+			p.handler(event)
+		}
+	}
+	for key, exec := range p.execs {
+		if _, ok := current[key]; !ok {
+			// 轮询无法获得退出码
+			delete(p.execs, key)
+			p.handler(exitEvent(exec, 0, now))
+		}
+	}
+
+	p.known = current
+}
+
+// readExecEvent 读取刚执行的进程信息，进程已退出时只保留 PID
+func readExecEvent(pid int, now time.Time) ProcessEvent {
+	event := ProcessEvent{Op: ProcExec, PID: pid, User: "unknown", Timestamp: now}
+
+	event.Name = readProcComm(pid)
+	if cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		event.Cmdline = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	}
+	event.Exe, _ = os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	event.Cwd, _ = os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+	event.PPID = readProcPPID(pid)
+	if event.PPID > 0 {
+		event.ParentName = readProcComm(event.PPID)
+	}
+	if uid, ok := readProcUID(pid); ok {
+		event.UID = uid
+		event.User = lookupUser(uid)
+	}
+
+	return event
+}
+
+// readProcComm 读取进程名称，进程不存在时返回空字符串
+func readProcComm(pid int) string {
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
+
+// readProcPPID 从 /proc/<pid>/status 读取父进程ID
+func readProcPPID(pid int) int {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return 0
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "PPid:") {
+			ppid, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "PPid:")))
+			return ppid
+		}
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// passwdPath 用户数据库路径
+const passwdPath = "/etc/passwd"
+
+// userCache UID 到用户名的缓存，按 /etc/passwd 的修改时间失效
+type userCache struct {
+	mu      sync.Mutex
+	modTime time.Time
+	names   map[uint32]string
+}
+
+var users = &userCache{}
+
+// lookupUser 将 UID 解析为用户名，无法解析时返回 UID 字符串
+func lookupUser(uid uint32) string {
+	return users.lookup(uid)
+}
+
+// lookup 查询用户名
+func (u *userCache) lookup(uid uint32) string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.refresh()
+
+	if name, ok := u.names[uid]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(uid), 10)
+}
+
+// refresh 在 /etc/passwd 发生变化时重新加载
+func (u *userCache) refresh() {
+	info, err := os.Stat(passwdPath)
+	if err != nil {
+		if u.names == nil {
+			u.names = make(map[uint32]string)
+		}
+		return
+	}
+
+	if u.names != nil && info.ModTime().Equal(u.modTime) {
+		return
+	}
+
+	names := make(map[uint32]string)
+
+	file, err := os.Open(passwdPath)
+	if err != nil {
+		u.names = names
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// 格式: name:password:uid:gid:gecos:home:shell
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) < 3 {
+			continue
+		}
+
+		uid, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		// 同一 UID 可能对应多个用户名，保留第一个
+		if _, exists := names[uint32(uid)]; !exists {
+			names[uint32(uid)] = fields[0]
+		}
+	}
+
+	u.names = names
+	u.modTime = info.ModTime()
+}
@@ -0,0 +1,288 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 登录记录文件（glibc 默认路径）
+const (
+	utmpPath = "/var/run/utmp" // 当前登录
+	wtmpPath = "/var/log/wtmp" // 登录/注销/开关机历史
+	btmpPath = "/var/log/btmp" // 失败的登录
+)
+
+// 登录记录类型
+const (
+	LoginLogin    = "login"    // 登录
+	LoginLogout   = "logout"   // 注销
+	LoginFailed   = "failed"   // 登录失败（btmp）
+	LoginBoot     = "boot"     // 系统启动
+	LoginShutdown = "shutdown" // 系统关机
+)
+
+// struct utmp 的记录类型（utmp.h）
+const (
+	utRunLevel    = 1
+	utBootTime    = 2
+	utUserProcess = 7
+	utDeadProcess = 8
+)
+
+// utmpRecordSize x86_64 glibc 中 struct utmp 的长度
+//
+//	ut_type(2) pad(2) ut_pid(4) ut_line(32) ut_id(4) ut_user(32) ut_host(256)
+//	ut_exit(4) ut_session(4) ut_tv(8) ut_addr_v6(16) unused(20)
+const utmpRecordSize = 384
+
+// utmpRecord 解码后的 struct utmp
+type utmpRecord struct {
+	Type int16
+	PID  int32
+	Line string
+	User string
+	Host string
+	Time time.Time
+	Addr string
+}
+
+// LoginSession 当前登录的会话（utmp）
+type LoginSession struct {
+	User      string    `json:"user"`           // 用户名
+	TTY       string    `json:"tty"`            // 终端（pts/0、tty1 等）
+	Host      string    `json:"host,omitempty"` // 远程主机名或 X 显示
+	Addr      string    `json:"addr,omitempty"` // 远程地址
+	PID       int       `json:"pid"`            // 登录进程ID
+	LoginTime time.Time `json:"login_time"`     // 登录时间
+	Idle      int64     `json:"idle"`           // 终端空闲时间（秒），无法获取时为 0
+}
+
+// LoginRecord 新增的登录/注销/失败登录/开关机记录（wtmp、btmp）
+type LoginRecord struct {
+	Type      string    `json:"type"`               // 记录类型
+	User      string    `json:"user,omitempty"`     // 用户名，注销记录按同一终端的登录记录补全
+	TTY       string    `json:"tty,omitempty"`      // 终端
+	Host      string    `json:"host,omitempty"`     // 远程主机名
+	Addr      string    `json:"addr,omitempty"`     // 远程地址
+	PID       int       `json:"pid,omitempty"`      // 登录进程ID
+	Duration  int64     `json:"duration,omitempty"` // 注销记录的会话时长（秒）
+	Source    string    `json:"source"`             // 来源文件（wtmp/btmp）
+	Timestamp time.Time `json:"timestamp"`          // 记录时间
+}
+
+// parseUtmpRecord 解码一条 struct utmp，数值均为小端序
+func parseUtmpRecord(b []byte) utmpRecord {
+	rec := utmpRecord{
+		Type: int16(binary.LittleEndian.Uint16(b[0:])),
+		PID:  int32(binary.LittleEndian.Uint32(b[4:])),
+		Line: cString(b[8:40]),
+		User: cString(b[44:76]),
+		Host: cString(b[76:332]),
+	}
+	sec := int32(binary.LittleEndian.Uint32(b[340:]))
+	usec := int32(binary.LittleEndian.Uint32(b[344:]))
+	rec.Time = time.Unix(int64(sec), int64(usec)*int64(time.Microsecond))
+
+	// ut_addr_v6 以网络字节序保存，IPv4 只占用第一个字
+	addr := b[348:364]
+	switch {
+	case bytes.Equal(addr, make([]byte, 16)):
+	case bytes.Equal(addr[4:], make([]byte, 12)):
+		rec.Addr = net.IP(addr[:4]).String()
+	default:
+		rec.Addr = net.IP(addr).String()
+	}
+	return rec
+}
+
+// cString 截取到第一个 NUL 的字符串
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// readUtmp 读取整个 utmp 格式的文件
+func readUtmp(path string) ([]utmpRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]utmpRecord, 0, len(data)/utmpRecordSize)
+	for len(data) >= utmpRecordSize {
+		records = append(records, parseUtmpRecord(data[:utmpRecordSize]))
+		data = data[utmpRecordSize:]
+	}
+	return records, nil
+}
+
+// sessionMonitor 读取当前会话，跟踪 wtmp/btmp 的新增记录
+type sessionMonitor struct {
+	utmp   string // 当前登录文件
+	wtmp   *logTailer
+	btmp   *logTailer
+	logins map[string]LoginRecord // 各终端最近一次登录，用于补全注销记录
+	failed map[string]bool        // 已记录过读取失败的文件
+}
+
+// newSessionMonitor 使用系统默认路径创建会话监控
+func newSessionMonitor() *sessionMonitor {
+	return newSessionMonitorAt(utmpPath, wtmpPath, btmpPath)
+}
+
+// newSessionMonitorAt 创建会话监控，wtmp/btmp 从当前末尾开始跟踪
+func newSessionMonitorAt(utmp, wtmp, btmp string) *sessionMonitor {
+	m := &sessionMonitor{
+		utmp:   utmp,
+		wtmp:   newRecordTailer(wtmp, utmpRecordSize),
+		btmp:   newRecordTailer(btmp, utmpRecordSize),
+		logins: make(map[string]LoginRecord),
+		failed: make(map[string]bool),
+	}
+	// 立即定位到末尾，启动到首次采集之间写入的记录不会丢失
+	_, err := m.wtmp.poll()
+	m.logError(wtmp, err)
+	_, err = m.btmp.poll()
+	m.logError(btmp, err)
+	return m
+}
+
+// sessions 返回当前登录的会话，忽略登录进程已退出的残留记录
+func (m *sessionMonitor) sessions() []LoginSession {
+	records, err := readUtmp(m.utmp)
+	if err != nil {
+		m.logError(m.utmp, err)
+		return []LoginSession{}
+	}
+	m.failed[m.utmp] = false
+
+	now := time.Now()
+	sessions := make([]LoginSession, 0)
+	for _, rec := range records {
+		if rec.Type != utUserProcess || rec.User == "" {
+			continue
+		}
+		if rec.PID > 0 && syscall.Kill(int(rec.PID), 0) == syscall.ESRCH {
+			continue
+		}
+
+		sessions = append(sessions, LoginSession{
+			User:      rec.User,
+			TTY:       rec.Line,
+			Host:      rec.Host,
+			Addr:      rec.Addr,
+			PID:       int(rec.PID),
+			LoginTime: rec.Time,
+			Idle:      ttyIdle(rec.Line, now),
+		})
+
+		// 启动前已登录的会话注销时也能补全用户
+		if _, ok := m.logins[rec.Line]; !ok {
+			m.logins[rec.Line] = LoginRecord{User: rec.User, Host: rec.Host, Addr: rec.Addr, Timestamp: rec.Time}
+		}
+	}
+	return sessions
+}
+
+// records 返回上次调用以来 wtmp 和 btmp 中新增的记录
+func (m *sessionMonitor) records() []LoginRecord {
+	var result []LoginRecord
+
+	raw, err := m.wtmp.poll()
+	m.logError(m.wtmp.path, err)
+	for _, b := range raw {
+		if rec, ok := m.wtmpRecord(parseUtmpRecord(b)); ok {
+			result = append(result, rec)
+		}
+	}
+
+	raw, err = m.btmp.poll()
+	m.logError(m.btmp.path, err)
+	for _, b := range raw {
+		rec := parseUtmpRecord(b)
+		result = append(result, LoginRecord{
+			Type:      LoginFailed,
+			User:      rec.User,
+			TTY:       rec.Line,
+			Host:      rec.Host,
+			Addr:      rec.Addr,
+			PID:       int(rec.PID),
+			Source:    "btmp",
+			Timestamp: rec.Time,
+		})
+	}
+	return result
+}
+
+// wtmpRecord 将 wtmp 记录转换为登录记录，不关心的类型返回 false
+func (m *sessionMonitor) wtmpRecord(rec utmpRecord) (LoginRecord, bool) {
+	lr := LoginRecord{
+		User:      rec.User,
+		TTY:       rec.Line,
+		Host:      rec.Host,
+		Addr:      rec.Addr,
+		PID:       int(rec.PID),
+		Source:    "wtmp",
+		Timestamp: rec.Time,
+	}
+
+	switch {
+	case rec.Type == utUserProcess && rec.User != "":
+		lr.Type = LoginLogin
+		m.logins[rec.Line] = lr
+	case rec.Type == utDeadProcess && rec.Line != "":
+		// 注销记录通常只有终端，用户和来源取自同一终端的登录记录
+		lr.Type = LoginLogout
+		if login, ok := m.logins[rec.Line]; ok {
+			lr.User, lr.Host, lr.Addr = login.User, login.Host, login.Addr
+			if d := rec.Time.Sub(login.Timestamp); d > 0 {
+				lr.Duration = int64(d / time.Second)
+			}
+			delete(m.logins, rec.Line)
+		}
+	case rec.Type == utBootTime:
+		lr.Type = LoginBoot
+		lr.User, lr.TTY = "", ""
+	case rec.Type == utRunLevel && rec.User == "shutdown":
+		lr.Type = LoginShutdown
+		lr.User, lr.TTY = "", ""
+	default:
+		return LoginRecord{}, false
+	}
+	return lr, true
+}
+
+// logError 记录文件读取失败，同一文件持续失败时只记录一次
+func (m *sessionMonitor) logError(path string, err error) {
+	if err == nil || os.IsNotExist(err) {
+		m.failed[path] = false
+		return
+	}
+	if !m.failed[path] {
+		log.Printf("Failed to read %s: %v", path, err)
+	}
+	m.failed[path] = true
+}
+
+// ttyIdle 根据终端设备的访问时间计算空闲时间
+func ttyIdle(line string, now time.Time) int64 {
+	if line == "" || strings.HasPrefix(line, ":") {
+		return 0
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat("/dev/"+line, &st); err != nil {
+		return 0
+	}
+	atime := time.Unix(st.Atim.Sec, st.Atim.Nsec)
+	if idle := now.Sub(atime); idle > 0 {
+		return int64(idle / time.Second)
+	}
+	return 0
+}
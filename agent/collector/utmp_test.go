@@ -0,0 +1,213 @@
+package collector
+
+import (
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// utmpEntry 测试中手工打包的 struct utmp 字段
+type utmpEntry struct {
+	typ  int16
+	pid  int32
+	line string
+	id   string
+	user string
+	host string
+	sec  int32
+	usec int32
+	addr []byte
+}
+
+// pack 按 x86_64 glibc 的偏移打包为 384 字节
+func (e utmpEntry) pack() []byte {
+	b := make([]byte, 384)
+	binary.LittleEndian.PutUint16(b[0:], uint16(e.typ))
+	binary.LittleEndian.PutUint32(b[4:], uint32(e.pid))
+	copy(b[8:40], e.line)
+	copy(b[40:44], e.id)
+	copy(b[44:76], e.user)
+	copy(b[76:332], e.host)
+	binary.LittleEndian.PutUint16(b[332:], 0)  // ut_exit.e_termination
+	binary.LittleEndian.PutUint16(b[334:], 0)  // ut_exit.e_exit
+	binary.LittleEndian.PutUint32(b[336:], 99) // ut_session
+	binary.LittleEndian.PutUint32(b[340:], uint32(e.sec))
+	binary.LittleEndian.PutUint32(b[344:], uint32(e.usec))
+	copy(b[348:364], e.addr)
+	return b
+}
+
+func TestParseUtmpRecord(t *testing.T) {
+	// 字段填满时不以 NUL 结尾，解析不能越过字段边界
+	longLine := "pts/0123456789012345678901234567" // 32 字节
+	longUser := "u1234567890123456789012345678901" // 32 字节
+
+	tests := []struct {
+		name  string
+		entry utmpEntry
+		want  utmpRecord
+	}{
+		{
+			name: "ssh login over IPv4",
+			entry: utmpEntry{typ: utUserProcess, pid: 4242, line: "pts/0", id: "ts/0", user: "alice",
+				host: "203.0.113.5", sec: 1760656633, usec: 250000, addr: []byte{203, 0, 113, 5}},
+			want: utmpRecord{Type: utUserProcess, PID: 4242, Line: "pts/0", User: "alice", Host: "203.0.113.5",
+				Time: time.Unix(1760656633, 250000000), Addr: "203.0.113.5"},
+		},
+		{
+			name: "IPv6 address",
+			entry: utmpEntry{typ: utUserProcess, pid: 1, line: "pts/1", user: "bob", host: "2001:db8::7", sec: 1,
+				addr: []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 7}},
+			want: utmpRecord{Type: utUserProcess, PID: 1, Line: "pts/1", User: "bob", Host: "2001:db8::7",
+				Time: time.Unix(1, 0), Addr: "2001:db8::7"},
+		},
+		{
+			name:  "fields filling their arrays",
+			entry: utmpEntry{typ: utDeadProcess, pid: -1, line: longLine, id: "abcd", user: longUser, sec: -1},
+			want:  utmpRecord{Type: utDeadProcess, PID: -1, Line: longLine, User: longUser, Time: time.Unix(-1, 0)},
+		},
+		{
+			name:  "boot record",
+			entry: utmpEntry{typ: utBootTime, line: "~", id: "~~", user: "reboot", host: "6.1.0-13-amd64", sec: 1700000000},
+			want:  utmpRecord{Type: utBootTime, Line: "~", User: "reboot", Host: "6.1.0-13-amd64", Time: time.Unix(1700000000, 0)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.entry.pack()
+			if len(b) != utmpRecordSize {
+				t.Fatalf("packed %d bytes, want %d", len(b), utmpRecordSize)
+			}
+			got := parseUtmpRecord(b)
+			if !got.Time.Equal(tt.want.Time) {
+				t.Errorf("time = %v, want %v", got.Time, tt.want.Time)
+			}
+			got.Time, tt.want.Time = time.Time{}, time.Time{}
+			if got != tt.want {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// exitedPID 返回一个已退出进程的 PID
+func exitedPID(t *testing.T) int32 {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot run true: %v", err)
+	}
+	return int32(cmd.Process.Pid)
+}
+
+// writeUtmp 写入 utmp 格式的文件
+func writeUtmp(t *testing.T, path string, entries ...utmpEntry) {
+	t.Helper()
+	var data []byte
+	for _, e := range entries {
+		data = append(data, e.pack()...)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionsSkipExitedLogins(t *testing.T) {
+	dir := t.TempDir()
+	utmp := filepath.Join(dir, "utmp")
+	live := int32(os.Getpid())
+
+	writeUtmp(t, utmp,
+		utmpEntry{typ: utBootTime, user: "reboot", sec: 1},
+		utmpEntry{typ: utUserProcess, pid: live, line: "pts/0", user: "alice", host: "203.0.113.5", sec: 100, addr: []byte{203, 0, 113, 5}},
+		// 登录进程已退出但未清理的残留记录
+		utmpEntry{typ: utUserProcess, pid: exitedPID(t), line: "pts/1", user: "mallory", sec: 200},
+		utmpEntry{typ: utDeadProcess, pid: live, line: "pts/2", sec: 300},
+		utmpEntry{typ: utUserProcess, pid: live, line: "tty1", sec: 400},
+		utmpEntry{typ: utUserProcess, pid: 0, line: ":0", user: "bob", host: ":0", sec: 500},
+	)
+	// 末尾不完整的记录被忽略
+	f, err := os.OpenFile(utmp, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(make([]byte, 100))
+	f.Close()
+
+	m := newSessionMonitorAt(utmp, filepath.Join(dir, "wtmp"), filepath.Join(dir, "btmp"))
+	sessions := m.sessions()
+	if len(sessions) != 2 {
+		t.Fatalf("sessions = %+v, want alice and bob", sessions)
+	}
+	if s := sessions[0]; s.User != "alice" || s.TTY != "pts/0" || s.Addr != "203.0.113.5" || s.PID != int(live) || !s.LoginTime.Equal(time.Unix(100, 0)) {
+		t.Errorf("session 0 = %+v", s)
+	}
+	if s := sessions[1]; s.User != "bob" || s.TTY != ":0" || s.Idle != 0 {
+		t.Errorf("session 1 = %+v", s)
+	}
+	if _, ok := m.logins["pts/1"]; ok {
+		t.Error("exited login recorded for logout completion")
+	}
+
+	// 文件不存在时返回空列表
+	m = newSessionMonitorAt(filepath.Join(dir, "missing"), filepath.Join(dir, "wtmp"), filepath.Join(dir, "btmp"))
+	if sessions := m.sessions(); sessions == nil || len(sessions) != 0 {
+		t.Errorf("sessions with missing utmp = %#v", sessions)
+	}
+}
+
+func TestSessionRecords(t *testing.T) {
+	dir := t.TempDir()
+	wtmp, btmp := filepath.Join(dir, "wtmp"), filepath.Join(dir, "btmp")
+	writeUtmp(t, wtmp, utmpEntry{typ: utUserProcess, line: "pts/9", user: "history", sec: 1})
+
+	m := newSessionMonitorAt(filepath.Join(dir, "utmp"), wtmp, btmp)
+
+	// 创建监控之后写入的记录都会被读到，历史记录不重放
+	appendUtmp := func(path string, entries ...utmpEntry) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		for _, e := range entries {
+			f.Write(e.pack())
+		}
+	}
+	appendUtmp(wtmp,
+		utmpEntry{typ: utUserProcess, pid: 10, line: "pts/0", user: "alice", host: "203.0.113.5", sec: 1000, addr: []byte{203, 0, 113, 5}},
+		utmpEntry{typ: utDeadProcess, pid: 10, line: "pts/0", sec: 1600},
+		utmpEntry{typ: utDeadProcess, line: "pts/5", sec: 1700},
+		utmpEntry{typ: utRunLevel, user: "shutdown", line: "~~", sec: 1800},
+		utmpEntry{typ: utBootTime, user: "reboot", line: "~", sec: 1900},
+		utmpEntry{typ: utRunLevel, user: "runlevel", line: "~~", sec: 1901},
+	)
+	appendUtmp(btmp, utmpEntry{typ: 6, line: "ssh:notty", user: "root", host: "198.51.100.9", sec: 2000, addr: []byte{198, 51, 100, 9}})
+
+	records := m.records()
+	want := []LoginRecord{
+		{Type: LoginLogin, User: "alice", TTY: "pts/0", Host: "203.0.113.5", Addr: "203.0.113.5", PID: 10, Source: "wtmp"},
+		{Type: LoginLogout, User: "alice", TTY: "pts/0", Host: "203.0.113.5", Addr: "203.0.113.5", PID: 10, Duration: 600, Source: "wtmp"},
+		{Type: LoginLogout, TTY: "pts/5", Source: "wtmp"},
+		{Type: LoginShutdown, Source: "wtmp"},
+		{Type: LoginBoot, Source: "wtmp"},
+		{Type: LoginFailed, User: "root", TTY: "ssh:notty", Host: "198.51.100.9", Addr: "198.51.100.9", Source: "btmp"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("records = %+v", records)
+	}
+	for i := range want {
+		got := records[i]
+		got.Timestamp = time.Time{}
+		if got != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+	if records := m.records(); len(records) != 0 {
+		t.Errorf("second poll returned %d records", len(records))
+	}
+}
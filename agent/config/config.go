@@ -8,33 +8,93 @@ import (
 
 // Config Agent 配置结构
 type Config struct {
-	ServerHost     string `json:"server_host"` // 服务器地址
-	ServerPort     int    `json:"server_port"` // 服务器端口
-	ReportInterval int    `json:"report_interval"` // 上报间隔（秒）
-	LogLevel       string `json:"log_level"`       // 日志级别
+	ServerHost         string `json:"server_host"`          // 服务器地址
+	ServerPort         int    `json:"server_port"`          // 服务器端口
+	ReportInterval     int    `json:"report_interval"`      // 上报间隔（秒）
+	FullReportInterval int    `json:"full_report_interval"` // 全量快照间隔（秒），其余上报只发送进程和连接的变化，0 表示始终全量上报
+	LogLevel           string `json:"log_level"`            // 日志级别
+	APIKey             string `json:"api_key"`              // 上报认证密钥（服务端 ingest_api_key 或 api_key）
+	Compress           bool   `json:"compress"`             // 是否使用 gzip 压缩上报数据
+
+	// 注册配置
+	EnrollmentToken string `json:"enrollment_token"` // 注册令牌，首次启动时用于向服务端注册
+	CredentialsFile string `json:"credentials_file"` // 注册后保存代理ID和密钥的文件
+
+	// TLS 配置
+	TLS TLSConfig `json:"tls"`
+
+	// 离线缓存配置
+	SpoolDir         string `json:"spool_dir"`          // 发送失败的上报缓存目录，为空时不缓存
+	SpoolMaxSizeMB   int    `json:"spool_max_size_mb"`  // 缓存总大小上限（MB），超出时丢弃最早的上报
+	RetryMinInterval int    `json:"retry_min_interval"` // 首次重试等待时间（秒），之后指数增长
+	RetryMaxInterval int    `json:"retry_max_interval"` // 最长重试等待时间（秒）
 
 	// 采集配置
-	CollectProcess bool `json:"collect_process"` // 是否采集进程信息
-	CollectFile    bool `json:"collect_file"`    // 是否采集文件信息
-	CollectNetwork bool `json:"collect_network"` // 是否采集网络信息
-	CollectSystem  bool `json:"collect_system"`  // 是否采集系统信息
+	CollectProcess       bool `json:"collect_process"`        // 是否采集进程信息
+	CollectProcessEvents bool `json:"collect_process_events"` // 是否实时监控进程执行和退出
+	CollectFile          bool `json:"collect_file"`           // 是否采集文件信息
+	CollectNetwork       bool `json:"collect_network"`        // 是否采集网络信息
+	CollectSystem        bool `json:"collect_system"`         // 是否采集系统信息
+	CollectSessions      bool `json:"collect_sessions"`       // 是否采集登录会话（utmp/wtmp/btmp）
 
 	// 监控路径
-	WatchPaths []string `json:"watch_paths"` // 监控的文件路径列表
+	WatchPaths      []string `json:"watch_paths"`       // 监控的文件路径列表
+	FIMBaselineFile string   `json:"fim_baseline_file"` // 文件完整性基线保存位置，重启后与其比较，为空时只保存在内存中
+
+	// 内核审计配置
+	Audit AuditConfig `json:"audit"`
+
+	// 认证日志配置
+	AuthLog AuthLogConfig `json:"auth_log"`
+}
+
+// AuditConfig 内核审计（NETLINK_AUDIT）采集配置
+type AuditConfig struct {
+	Enabled bool     `json:"enabled"` // 是否采集审计事件，需要 root 权限
+	Rules   []string `json:"rules"`   // auditctl 语法的审计规则，启动时安装、退出时删除
+}
+
+// AuthLogConfig 认证日志（sshd/sudo/su/useradd）采集配置
+type AuthLogConfig struct {
+	Enabled             bool     `json:"enabled"`               // 是否跟踪认证日志
+	Paths               []string `json:"paths"`                 // 认证日志路径，不存在的文件会在出现后开始跟踪
+	BruteForceThreshold int      `json:"brute_force_threshold"` // 同一来源在窗口内登录失败达到该次数时产生暴力破解事件
+	BruteForceWindow    int      `json:"brute_force_window"`    // 暴力破解检测的滑动窗口（秒）
+}
+
+// TLSConfig Agent 与服务端之间的 TLS 配置
+type TLSConfig struct {
+	Enabled          bool   `json:"enabled"`            // 是否使用 HTTPS
+	CAFile           string `json:"ca_file"`            // 签发服务端证书的 CA
+	CertFile         string `json:"cert_file"`          // 客户端证书，CN 为代理ID
+	KeyFile          string `json:"key_file"`           // 客户端私钥
+	ServerName       string `json:"server_name"`        // 校验服务端证书时使用的名称，默认为 server_host
+	ServerCertSHA256 string `json:"server_cert_sha256"` // 固定的服务端证书 SHA-256 指纹
 }
 
 // DefaultConfig 默认配置
 func DefaultConfig() *Config {
 	return &Config{
-		ServerHost:     "127.0.0.1",
-		ServerPort:     8848,
-		ReportInterval: 30,
-		LogLevel:       "info",
+		ServerHost:         "127.0.0.1",
+		ServerPort:         8848,
+		ReportInterval:     30,
+		FullReportInterval: 300,
+		LogLevel:           "info",
+		Compress:           true,
 
-		CollectProcess: true,
-		CollectFile:    true,
-		CollectNetwork: true,
-		CollectSystem:  true,
+		CredentialsFile: "agent-credentials.json",
+
+		SpoolDir:         "spool",
+		SpoolMaxSizeMB:   100,
+		RetryMinInterval: 5,
+		RetryMaxInterval: 300,
+
+		CollectProcess:       true,
+		CollectProcessEvents: true,
+		CollectFile:          true,
+		CollectNetwork:       true,
+		CollectSystem:        true,
+		CollectSessions:      true,
 
 		WatchPaths: []string{
 			"/etc",
@@ -43,13 +103,32 @@ func DefaultConfig() *Config {
 			"/usr/bin",
 			"/usr/sbin",
 		},
+		FIMBaselineFile: "fim-baseline.json",
+
+		Audit: AuditConfig{
+			Rules: []string{
+				"-a always,exit -F arch=b64 -S execve,execveat -k exec",
+				"-a always,exit -F arch=b64 -S connect -F success=1 -k network_connect",
+				"-a always,exit -F arch=b64 -S ptrace -k ptrace",
+				"-a always,exit -F arch=b64 -S init_module,finit_module,delete_module -k kernel_module",
+				"-a always,exit -F arch=b64 -S setuid,setgid,setreuid,setregid,setresuid,setresgid -k setuid",
+				"-w /etc/passwd -p wa -k identity",
+				"-w /etc/shadow -p wa -k identity",
+				"-w /etc/sudoers -p wa -k privilege",
+			},
+		},
+
+		AuthLog: AuthLogConfig{
+			Enabled:             true,
+			Paths:               []string{"/var/log/auth.log", "/var/log/secure"},
+			BruteForceThreshold: 5,
+			BruteForceWindow:    300,
+		},
 	}
 }
 
 // Load 加载配置文件
-func Load() *Config {
-	configPath := "config.json"
-
+func Load(configPath string) *Config {
 	// 如果配置文件不存在，创建默认配置
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		config := DefaultConfig()
@@ -64,21 +143,47 @@ func Load() *Config {
 		return DefaultConfig()
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
+	// 未出现的字段保留默认值
+	config := DefaultConfig()
+	if err := json.Unmarshal(data, config); err != nil {
 		log.Printf("Failed to parse config file: %v, using default config", err)
 		return DefaultConfig()
 	}
+	config.normalize()
 
-	return &config
+	return config
 }
 
-// Save 保存配置到文件
+// normalize 修正无效的缓存、重试和暴力破解检测配置
+func (c *Config) normalize() {
+	defaults := DefaultConfig()
+	if c.AuthLog.BruteForceThreshold <= 0 {
+		c.AuthLog.BruteForceThreshold = defaults.AuthLog.BruteForceThreshold
+	}
+	if c.AuthLog.BruteForceWindow <= 0 {
+		c.AuthLog.BruteForceWindow = defaults.AuthLog.BruteForceWindow
+	}
+	if c.SpoolMaxSizeMB <= 0 {
+		c.SpoolMaxSizeMB = defaults.SpoolMaxSizeMB
+	}
+	if c.RetryMinInterval <= 0 {
+		c.RetryMinInterval = defaults.RetryMinInterval
+	}
+	if c.RetryMaxInterval < c.RetryMinInterval {
+		c.RetryMaxInterval = c.RetryMinInterval
+	}
+}
+
+// Save 保存配置到文件。配置中包含上报密钥和注册令牌，仅所有者可读写，
+// 已存在的文件也会收紧权限
 func (c *Config) Save(path string) error {
 	data, err := json.MarshalIndent(c, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
 }
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveRestrictsPermissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	// 旧版本以 0644 写入的配置文件
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.APIKey = "secret"
+	if err := cfg.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("config mode = %o, want 600", mode)
+	}
+	if got := Load(path); got.APIKey != "secret" {
+		t.Errorf("api_key after reload = %q", got.APIKey)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"mini-hids/agent/collector"
+)
+
+// 上报类型
+const (
+	reportFull  = "full"  // 全量快照
+	reportDelta = "delta" // 相对上一次上报的增量
+)
+
+// sectionDelta 列表型数据的增量
+type sectionDelta[T any] struct {
+	Added   []T      `json:"added,omitempty"`   // 新增的条目
+	Changed []T      `json:"changed,omitempty"` // 内容变化的条目
+	Removed []string `json:"removed,omitempty"` // 消失的条目键
+}
+
+// deltaEncoder 增量上报编码器，定期发送全量快照，其余时间只发送进程和连接的变化
+type deltaEncoder struct {
+	interval  time.Duration // 全量快照间隔，0 表示始终发送全量
+	seq       uint64        // 最近一次上报的序号
+	lastFull  time.Time     // 最近一次全量快照时间
+	forceFull bool          // 下一次强制发送全量快照
+
+	processes map[string]collector.ProcessInfo       // 已上报的进程
+	network   map[string]collector.NetworkConnection // 已上报的连接
+}
+
+// newDeltaEncoder 创建增量上报编码器
+func newDeltaEncoder(interval time.Duration) *deltaEncoder {
+	return &deltaEncoder{interval: interval, forceFull: true}
+}
+
+// processKey 进程键，PID 加启动时间避免 PID 复用时混淆
+func processKey(p collector.ProcessInfo) string {
+	return fmt.Sprintf("%d:%d", p.PID, p.StartTime)
+}
+
+// connectionKey 连接键，包含 inode 以区分端口复用的多个 socket
+func connectionKey(c collector.NetworkConnection) string {
+	return fmt.Sprintf("%s|%s:%d|%s:%d|%d", c.Protocol, c.LocalAddr, c.LocalPort, c.RemoteAddr, c.RemotePort, c.Inode)
+}
+
+// processChanged 进程的身份或稳定属性是否变化。CPU 和内存每次采集都在波动，
+// 只随全量快照更新，否则几乎每个进程都会出现在 changed 中
+func processChanged(old, cur collector.ProcessInfo) bool {
+	return old.PPID != cur.PPID || old.Name != cur.Name || old.ParentName != cur.ParentName ||
+		old.Exe != cur.Exe || old.Cmdline != cur.Cmdline || old.Cwd != cur.Cwd ||
+		old.TTY != cur.TTY || old.User != cur.User || old.UID != cur.UID
+}
+
+// connectionChanged 连接状态等字段是否变化
+func connectionChanged(old, cur collector.NetworkConnection) bool {
+	return old != cur
+}
+
+// encode 将采集数据编码为全量或增量上报，返回上报类型和序号
+func (e *deltaEncoder) encode(data map[string]interface{}, now time.Time) (string, uint64) {
+	e.seq++
+
+	processes, hasProcesses := data["processes"].([]collector.ProcessInfo)
+	network, hasNetwork := data["network"].([]collector.NetworkConnection)
+
+	if e.forceFull || e.interval <= 0 || now.Sub(e.lastFull) >= e.interval {
+		e.forceFull = false
+		e.lastFull = now
+		e.processes = indexBy(processes, processKey)
+		e.network = indexBy(network, connectionKey)
+		return reportFull, e.seq
+	}
+
+	if hasProcesses {
+		delete(data, "processes")
+		data["process_delta"], e.processes = diffSection(e.processes, processes, processKey, processChanged)
+	}
+	if hasNetwork {
+		delete(data, "network")
+		data["network_delta"], e.network = diffSection(e.network, network, connectionKey, connectionChanged)
+	}
+	return reportDelta, e.seq
+}
+
+// resync 服务端要求重新同步或上报失败时，下一次发送全量快照
+func (e *deltaEncoder) resync() {
+	e.forceFull = true
+}
+
+// indexBy 按键建立索引
+func indexBy[T any](items []T, key func(T) string) map[string]T {
+	index := make(map[string]T, len(items))
+	for _, item := range items {
+		index[key(item)] = item
+	}
+	return index
+}
+
+// diffSection 比较两次采集的列表，返回增量和新的索引。
+// 未变化的条目在索引中保留上次上报的值，与服务端还原出的快照一致
+func diffSection[T any](prev map[string]T, items []T, key func(T) string, changed func(old, cur T) bool) (sectionDelta[T], map[string]T) {
+	var delta sectionDelta[T]
+	current := make(map[string]T, len(items))
+
+	for _, item := range items {
+		k := key(item)
+		old, ok := prev[k]
+		switch {
+		case !ok:
+			delta.Added = append(delta.Added, item)
+		case changed(old, item):
+			delta.Changed = append(delta.Changed, item)
+		default:
+			item = old
+		}
+		current[k] = item
+	}
+	for k := range prev {
+		if _, ok := current[k]; !ok {
+			delta.Removed = append(delta.Removed, k)
+		}
+	}
+
+	return delta, current
+}
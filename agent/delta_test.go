@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"mini-hids/agent/collector"
+)
+
+func TestDeltaIgnoresResourceUsage(t *testing.T) {
+	enc := newDeltaEncoder(time.Hour)
+	now := time.Now()
+
+	proc := func(pid int, cmdline string, cpu float64, rss uint64) collector.ProcessInfo {
+		return collector.ProcessInfo{
+			PID: pid, PPID: 1, Name: "p", Exe: "/usr/bin/p", Cmdline: cmdline, UID: 1000,
+			CPU: "x", Memory: "y", CPUPercent: cpu, RSSBytes: rss, StartTime: 100,
+		}
+	}
+	collect := func(procs ...collector.ProcessInfo) map[string]interface{} {
+		return map[string]interface{}{"processes": procs}
+	}
+
+	if typ, _ := enc.encode(collect(proc(1, "p", 1, 1<<20), proc(2, "p", 1, 1<<20)), now); typ != reportFull {
+		t.Fatalf("first report type = %s, want %s", typ, reportFull)
+	}
+
+	// 只有 CPU 和内存变化的进程不出现在增量中
+	busy := proc(1, "p", 95.5, 512<<20)
+	busy.CPU, busy.Memory = "95.5%", "512MB"
+	data := collect(busy, proc(2, "p --reloaded", 1, 1<<20), proc(3, "p", 0, 0))
+	if typ, _ := enc.encode(data, now.Add(time.Minute)); typ != reportDelta {
+		t.Fatalf("second report type = %s, want %s", typ, reportDelta)
+	}
+	delta, ok := data["process_delta"].(sectionDelta[collector.ProcessInfo])
+	if !ok {
+		t.Fatalf("process_delta = %T", data["process_delta"])
+	}
+	if len(delta.Changed) != 1 || delta.Changed[0].PID != 2 {
+		t.Errorf("changed = %+v, want only the process whose cmdline changed", delta.Changed)
+	}
+	if len(delta.Added) != 1 || delta.Added[0].PID != 3 {
+		t.Errorf("added = %+v, want pid 3", delta.Added)
+	}
+	if len(delta.Removed) != 0 {
+		t.Errorf("removed = %v, want none", delta.Removed)
+	}
+	if _, ok := data["processes"]; ok {
+		t.Error("delta report still contains the full process list")
+	}
+
+	// 全量快照携带最新的资源占用
+	enc.resync()
+	data = collect(busy)
+	if typ, _ := enc.encode(data, now.Add(2*time.Minute)); typ != reportFull {
+		t.Fatalf("report after resync type = %s, want %s", typ, reportFull)
+	}
+	if procs := data["processes"].([]collector.ProcessInfo); procs[0].CPUPercent != 95.5 {
+		t.Errorf("full report cpu_percent = %v, want 95.5", procs[0].CPUPercent)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Credentials 注册后获得的代理凭据
+type Credentials struct {
+	AgentID     string    `json:"agent_id"`
+	AgentSecret string    `json:"agent_secret"`
+	ServerHost  string    `json:"server_host"`
+	EnrolledAt  time.Time `json:"enrolled_at"`
+}
+
+// loadCredentials 读取本地保存的凭据，文件不存在时返回 nil
+func loadCredentials(path string) (*Credentials, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("parse credentials %s: %w", path, err)
+	}
+	if creds.AgentID == "" || creds.AgentSecret == "" {
+		return nil, fmt.Errorf("credentials %s: missing agent_id or agent_secret", path)
+	}
+	return &creds, nil
+}
+
+// saveCredentials 保存凭据，仅所有者可读写
+func saveCredentials(path string, creds *Credentials) error {
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ensureCredentials 加载凭据，首次启动且配置了注册令牌时向服务端注册
+// 未配置注册令牌时返回 nil，使用主机名和 api_key 上报
+func (a *Agent) ensureCredentials() (*Credentials, error) {
+	path := a.config.CredentialsFile
+	if path == "" {
+		return nil, nil
+	}
+
+	creds, err := loadCredentials(path)
+	if err != nil || creds != nil {
+		return creds, err
+	}
+
+	if a.config.EnrollmentToken == "" {
+		return nil, nil
+	}
+
+	// 注册失败时重试，直到成功或 Agent 停止
+	backoff := 5 * time.Second
+	for {
+		creds, err = a.enroll()
+		if err == nil {
+			break
+		}
+		log.Printf("Enrollment failed: %v, retrying in %s", err, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-a.stopCh:
+			return nil, errors.New("stopped before enrollment completed")
+		}
+		backoff = min(backoff*2, 5*time.Minute)
+	}
+
+	if err := saveCredentials(path, creds); err != nil {
+		return nil, fmt.Errorf("save credentials: %w", err)
+	}
+
+	log.Printf("Enrolled as agent %s", creds.AgentID)
+	return creds, nil
+}
+
+// enroll 使用注册令牌向服务端注册
+func (a *Agent) enroll() (*Credentials, error) {
+	body, err := json.Marshal(map[string]string{"hostname": hostname()})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := a.newRequest("/api/agent/enroll", body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.config.EnrollmentToken)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		AgentID     string `json:"agent_id"`
+		AgentSecret string `json:"agent_secret"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if result.AgentID == "" || result.AgentSecret == "" {
+		return nil, errors.New("server returned empty credentials")
+	}
+
+	return &Credentials{
+		AgentID:     result.AgentID,
+		AgentSecret: result.AgentSecret,
+		ServerHost:  a.config.ServerHost,
+		EnrolledAt:  time.Now(),
+	}, nil
+}
@@ -1,8 +1,9 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
@@ -17,46 +18,151 @@ import (
 
 // Agent 主结构体
 type Agent struct {
-	config    *config.Config
-	collector *collector.Collector
-	stopCh    chan struct{}
+	config      *config.Config
+	collector   *collector.Collector
+	credentials *Credentials  // 注册凭据，未注册时为 nil
+	certAgentID string        // 客户端证书中的代理ID
+	client      *http.Client  // 访问服务端的 HTTP 客户端
+	delta       *deltaEncoder // 增量上报编码器
+	spool       *spool        // 发送失败的上报缓存，未启用时为 nil
+	retry       backoff       // 补发缓存的退避状态
+	retryTimer  *time.Timer   // 下一次补发缓存的定时器
+	rejected    bool          // 服务端拒绝了代理的凭据，期间不缓存上报也不补发
+	stopCh      chan struct{}
 }
 
 // AgentData 上报数据结构
 type AgentData struct {
-	AgentID   string                 `json:"agent_id"`
-	Hostname  string                 `json:"hostname"`
-	Timestamp time.Time              `json:"timestamp"`
-	Data      map[string]interface{} `json:"data"`
+	AgentID        string                 `json:"agent_id"`
+	Hostname       string                 `json:"hostname"`
+	CollectedAt    time.Time              `json:"collected_at"`    // 采集时间（代理时钟）
+	ReportInterval int                    `json:"report_interval"` // 上报间隔（秒），服务端据此判断代理是否失联
+	ReportType     string                 `json:"report_type"`     // 上报类型（full/delta）
+	Seq            uint64                 `json:"seq"`             // 上报序号，服务端据此发现丢失的增量
+	Data           map[string]interface{} `json:"data"`
+}
+
+// spoolDrainBatch 每轮补发的最大上报数量
+const spoolDrainBatch = 100
+
+// statusError 服务端返回的非成功状态码
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("server returned status: %d", e.code)
+}
+
+// retryable 判断发送失败后是否值得重试：网络错误、服务端错误和限流可以重试，
+// 请求本身有问题（如格式错误、过大）或认证失败时重试也不会成功
+func retryable(err error) bool {
+	var se *statusError
+	if !errors.As(err, &se) {
+		return true
+	}
+	switch se.code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return se.code >= 500
+}
+
+// authFailure 判断是否为服务端拒绝代理凭据（密钥错误、代理已吊销等）
+func authFailure(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && (se.code == http.StatusUnauthorized || se.code == http.StatusForbidden)
+}
+
+// reportResponse 上报响应
+type reportResponse struct {
+	Status string `json:"status"`
+	Resync bool   `json:"resync"` // 服务端无法还原增量，要求下一次发送全量快照
 }
 
 // NewAgent 创建新的 Agent 实例
-func NewAgent() *Agent {
-	cfg := config.Load()
-	return &Agent{
-		config:    cfg,
-		collector: collector.New(cfg),
-		stopCh:    make(chan struct{}),
+func NewAgent(configPath string) (*Agent, error) {
+	cfg := config.Load(configPath)
+
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	certAgentID, err := clientCertAgentID(cfg)
+	if err != nil {
+		return nil, err
 	}
+
+	agent := &Agent{
+		config:      cfg,
+		collector:   collector.New(cfg),
+		certAgentID: certAgentID,
+		client:      client,
+		delta:       newDeltaEncoder(time.Duration(cfg.FullReportInterval) * time.Second),
+		retry: backoff{
+			min: time.Duration(cfg.RetryMinInterval) * time.Second,
+			max: time.Duration(cfg.RetryMaxInterval) * time.Second,
+		},
+		stopCh: make(chan struct{}),
+	}
+
+	if cfg.SpoolDir != "" {
+		agent.spool, err = openSpool(cfg.SpoolDir, int64(cfg.SpoolMaxSizeMB)<<20)
+		if err != nil {
+			return nil, fmt.Errorf("open spool: %w", err)
+		}
+		if n := agent.spool.count(); n > 0 {
+			log.Printf("Found %d spooled reports in %s", n, cfg.SpoolDir)
+		}
+	}
+
+	return agent, nil
 }
 
 // Start 启动 Agent
 func (a *Agent) Start() error {
 	log.Println("Starting Mini-HIDS Agent...")
 
+	// 监听停止信号
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		close(a.stopCh)
+	}()
+
+	// 使用客户端证书时以证书 CN 为代理ID，否则加载或申请注册凭据
+	if a.certAgentID != "" {
+		log.Printf("Using client certificate identity %s", a.certAgentID)
+	} else {
+		creds, err := a.ensureCredentials()
+		if err != nil {
+			return err
+		}
+		a.credentials = creds
+	}
+
 	// 启动数据采集器
-	go a.collector.Start(a.stopCh)
+	collectorDone := make(chan struct{})
+	go func() {
+		a.collector.Start(a.stopCh)
+		close(collectorDone)
+	}()
 
 	// 启动数据上报协程
 	go a.startReporting()
 
 	// 等待停止信号
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-
-	<-sigCh
+	<-a.stopCh
 	log.Println("Received stop signal, shutting down...")
-	close(a.stopCh)
+
+	// 等待采集器清理（如删除审计规则）
+	select {
+	case <-collectorDone:
+	case <-time.After(5 * time.Second):
+		log.Println("Timed out waiting for collector to stop")
+	}
 
 	return nil
 }
@@ -66,10 +172,20 @@ func (a *Agent) startReporting() {
 	ticker := time.NewTicker(time.Duration(a.config.ReportInterval) * time.Second)
 	defer ticker.Stop()
 
+	// 启动时先补发上次未发送的上报
+	a.retryTimer = time.NewTimer(time.Hour)
+	a.retryTimer.Stop()
+	if a.spool != nil && a.spool.count() > 0 {
+		a.retryTimer.Reset(0)
+	}
+	defer a.retryTimer.Stop()
+
 	for {
 		select {
 		case <-ticker.C:
 			a.reportData()
+		case <-a.retryTimer.C:
+			a.drainSpool()
 		case <-a.stopCh:
 			return
 		}
@@ -84,53 +200,254 @@ func (a *Agent) reportData() {
 		return
 	}
 
-	hostname, _ := os.Hostname()
+	hostname := hostname()
 
-	// 将所有数据类型合并到一个请求中
+	// 优先使用客户端证书或注册时分配的ID，否则使用hostname
+	agentID := hostname
+	if a.certAgentID != "" {
+		agentID = a.certAgentID
+	} else if a.credentials != nil {
+		agentID = a.credentials.AgentID
+	}
+
+	// 将所有数据类型合并到一个请求中，进程和连接在两次全量快照之间只发送变化
+	now := time.Now()
+	reportType, seq := a.delta.encode(data, now)
 	agentData := AgentData{
-		AgentID:   hostname, // 使用hostname作为AgentID
-		Hostname:  hostname,
-		Timestamp: time.Now(),
-		Data:      data,
+		AgentID:        agentID,
+		Hostname:       hostname,
+		CollectedAt:    now,
+		ReportInterval: a.config.ReportInterval,
+		ReportType:     reportType,
+		Seq:            seq,
+		Data:           data,
 	}
 
-	// 发送到服务端
-	if err := a.sendToServer(agentData); err != nil {
-		log.Printf("Failed to send data to server: %v", err)
+	payload, err := json.Marshal(agentData)
+	if err != nil {
+		log.Printf("Failed to encode report: %v", err)
+		a.delta.resync()
+		return
 	}
+	a.deliver(payload, seq)
 }
 
-// sendToServer 发送数据到服务端
-func (a *Agent) sendToServer(data AgentData) error {
-	jsonData, err := json.Marshal(data)
+// deliver 发送一次上报，失败且可以重试时写入缓存
+func (a *Agent) deliver(payload []byte, seq uint64) {
+	// 缓存中还有未发送的上报时排在其后，保证服务端按顺序收到增量
+	if a.spool != nil && a.spool.count() > 0 && !a.rejected {
+		a.spoolReport(payload, seq)
+		return
+	}
+
+	resync, err := a.sendToServer(payload)
 	if err != nil {
-		return err
+		if authFailure(err) {
+			a.rejectCredentials(err)
+			a.delta.resync()
+			return
+		}
+		if a.spool == nil || !retryable(err) {
+			// 上报被丢弃，服务端的状态已不可信，下一次发送全量快照
+			log.Printf("Failed to send data to server: %v", err)
+			a.delta.resync()
+			return
+		}
+		a.spoolReport(payload, seq)
+		a.scheduleRetry(err)
+		return
+	}
+	if a.rejected {
+		// 服务端重新接受凭据（例如恢复了密钥），继续补发缓存
+		log.Printf("Server accepted agent credentials again, resuming reports")
+		a.rejected = false
+		if a.spool != nil && a.spool.count() > 0 {
+			a.retryTimer.Reset(0)
+		}
+	}
+	if resync {
+		log.Printf("Server requested a full resync after report %d", seq)
+		a.delta.resync()
 	}
+}
+
+// rejectCredentials 服务端拒绝凭据时不再缓存和重试，之后的上报直接发送，失败即丢弃。
+// 已缓存的上报保留在磁盘上，修正凭据后重启代理或服务端重新接受凭据时补发
+func (a *Agent) rejectCredentials(err error) {
+	if a.rejected {
+		log.Printf("Report dropped, server still rejects agent credentials: %v", err)
+		return
+	}
+	a.rejected = true
+	a.retryTimer.Stop()
+
+	hint := "check api_key"
+	if a.certAgentID != "" {
+		hint = "check the client certificate"
+	} else if a.credentials != nil {
+		hint = fmt.Sprintf("the agent may have been revoked; remove %s to re-enroll", a.config.CredentialsFile)
+	}
+	log.Printf("Server rejected agent credentials (%v): %s. Reports are dropped and no longer spooled or retried", err, hint)
+}
 
-	url := fmt.Sprintf("http://%s:%d/api/agent/data", a.config.ServerHost, a.config.ServerPort)
+// hostname 返回本机主机名，获取失败时返回 "unknown"，服务端拒绝空主机名
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+// spoolReport 将上报写入磁盘缓存，等待恢复连接后补发
+func (a *Agent) spoolReport(payload []byte, seq uint64) {
+	dropped, err := a.spool.push(payload)
 	if err != nil {
-		return err
+		log.Printf("Failed to spool report %d: %v", seq, err)
+		a.delta.resync()
+		return
+	}
+	if dropped > 0 {
+		log.Printf("Spool is full, dropped %d oldest reports", dropped)
+	}
+}
+
+// scheduleRetry 按指数退避安排下一次补发
+func (a *Agent) scheduleRetry(cause error) {
+	delay := a.retry.next()
+	log.Printf("Failed to send data to server: %v; %d reports spooled, retrying in %s",
+		cause, a.spool.count(), delay.Round(time.Second))
+	a.retryTimer.Reset(delay)
+}
+
+// drainSpool 从最早的上报开始补发缓存，失败时退避重试
+func (a *Agent) drainSpool() {
+	// 每轮最多补发一批，其余留到下一轮，避免长时间阻塞新的采集上报
+	sent := 0
+	for sent < spoolDrainBatch {
+		id, payload, ok, err := a.spool.peek()
+		if err != nil {
+			a.scheduleRetry(fmt.Errorf("read spool: %w", err))
+			return
+		}
+		if !ok {
+			break
+		}
+
+		resync, err := a.sendToServer(payload)
+		if err != nil {
+			if authFailure(err) {
+				a.rejectCredentials(err)
+				return
+			}
+			if retryable(err) {
+				a.scheduleRetry(err)
+				return
+			}
+			// 服务端拒绝的上报重试也不会成功，丢弃后要求全量同步
+			log.Printf("Server rejected spooled report, dropping it: %v", err)
+			a.spool.remove(id)
+			a.delta.resync()
+			continue
+		}
+
+		a.spool.remove(id)
+		a.retry.reset()
+		sent++
+		if resync {
+			a.delta.resync()
+		}
+	}
+
+	if n := a.spool.count(); n > 0 {
+		log.Printf("Sent %d spooled reports, %d remaining", sent, n)
+		a.retryTimer.Reset(time.Second)
+		return
 	}
-	req.Header.Set("Content-Type", "application/json")
+	a.retry.reset()
+	if sent > 0 {
+		log.Printf("Spool drained, sent %d reports", sent)
+	}
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// sendToServer 发送上报到服务端，返回服务端是否要求全量重新同步
+func (a *Agent) sendToServer(payload []byte) (bool, error) {
+	body, encoding := payload, ""
+	if a.config.Compress {
+		compressed, err := gzipBytes(payload)
+		if err != nil {
+			return false, err
+		}
+		body, encoding = compressed, "gzip"
+	}
+
+	req, err := a.newRequest("/api/agent/data", body)
 	if err != nil {
-		return err
+		return false, err
+	}
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	// 附带发送时间，服务端据此估计时钟偏差（补发的上报采集时间早于发送时间）
+	req.Header.Set("X-Agent-Time", time.Now().Format(time.RFC3339Nano))
+	if a.credentials != nil {
+		req.Header.Set("X-Agent-ID", a.credentials.AgentID)
+		req.Header.Set("Authorization", "Bearer "+a.credentials.AgentSecret)
+	} else if a.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.config.APIKey)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return false, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned status: %d", resp.StatusCode)
+		return false, &statusError{code: resp.StatusCode}
 	}
 
-	return nil
+	var result reportResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, nil
+	}
+	return result.Resync, nil
+}
+
+// printAuditLog 将 audit.log 解析为与上报中 audit_events 相同的结构，用于检查规则效果和解析结果
+func printAuditLog(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	enc := json.NewEncoder(os.Stdout)
+	invalid, err := collector.ParseAuditLog(file, func(ev collector.AuditEvent) {
+		enc.Encode(ev)
+	})
+	if invalid > 0 {
+		log.Printf("Skipped %d malformed lines", invalid)
+	}
+	return err
 }
 
 func main() {
-	agent := NewAgent()
+	configPath := flag.String("config", "config.json", "path to agent config file")
+	parseAudit := flag.String("parse-audit", "", "parse a recorded audit.log, print structured events as JSON lines and exit")
+	flag.Parse()
+
+	if *parseAudit != "" {
+		if err := printAuditLog(*parseAudit); err != nil {
+			log.Fatalf("Failed to parse audit log: %v", err)
+		}
+		return
+	}
+
+	agent, err := NewAgent(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to initialize agent: %v", err)
+	}
 	if err := agent.Start(); err != nil {
 		log.Fatalf("Agent failed to start: %v", err)
 	}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mini-hids/agent/config"
+)
+
+// newTestAgent 创建向 ts 上报、启用磁盘缓存的代理
+func newTestAgent(t *testing.T, ts *httptest.Server) *Agent {
+	t.Helper()
+
+	host, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	cfg.ServerHost = host
+	cfg.ServerPort, _ = strconv.Atoi(port)
+	cfg.Compress = false
+
+	sp, err := openSpool(t.TempDir(), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Agent{
+		config:     cfg,
+		client:     ts.Client(),
+		delta:      newDeltaEncoder(time.Hour),
+		spool:      sp,
+		retry:      backoff{min: time.Second, max: time.Minute},
+		retryTimer: time.NewTimer(time.Hour),
+	}
+	a.retryTimer.Stop()
+	t.Cleanup(func() { a.retryTimer.Stop() })
+	return a
+}
+
+// timerArmed 补发定时器是否已安排
+func timerArmed(a *Agent) bool {
+	return a.retryTimer.Stop()
+}
+
+func TestAuthFailureNotRetried(t *testing.T) {
+	for _, code := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		t.Run(strconv.Itoa(code), func(t *testing.T) {
+			var status, requests atomic.Int32
+			status.Store(int32(code))
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.WriteHeader(int(status.Load()))
+				w.Write([]byte(`{"status":"ok"}`))
+			}))
+			defer ts.Close()
+			a := newTestAgent(t, ts)
+
+			// 认证失败的上报不写入缓存，也不安排重试
+			a.deliver([]byte(`{"seq":1}`), 1)
+			if !a.rejected {
+				t.Error("agent did not record the credential rejection")
+			}
+			if n := a.spool.count(); n != 0 {
+				t.Errorf("spooled %d reports after status %d", n, code)
+			}
+			if timerArmed(a) {
+				t.Error("retry scheduled after authentication failure")
+			}
+			if !a.delta.forceFull {
+				t.Error("dropped report did not force a full resync")
+			}
+
+			// 补发缓存时遇到认证失败，停止补发并保留缓存
+			if _, err := a.spool.push([]byte(`{"seq":2}`)); err != nil {
+				t.Fatal(err)
+			}
+			before := requests.Load()
+			a.drainSpool()
+			if n := requests.Load() - before; n != 1 {
+				t.Errorf("drain sent %d requests, want 1", n)
+			}
+			if n := a.spool.count(); n != 1 {
+				t.Errorf("spool has %d reports after rejected drain, want 1", n)
+			}
+			if timerArmed(a) {
+				t.Error("retry scheduled after rejected drain")
+			}
+
+			// 仍被拒绝时新的上报直接发送，不排在缓存之后
+			a.deliver([]byte(`{"seq":3}`), 3)
+			if n := a.spool.count(); n != 1 {
+				t.Errorf("spool has %d reports while rejected, want 1", n)
+			}
+
+			// 服务端重新接受凭据后恢复补发
+			status.Store(http.StatusOK)
+			a.deliver([]byte(`{"seq":4}`), 4)
+			if a.rejected {
+				t.Error("agent still rejected after a successful report")
+			}
+			if !timerArmed(a) {
+				t.Error("spool drain not resumed after credentials were accepted")
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&net.OpError{Op: "dial"}, true},
+		{&statusError{code: http.StatusInternalServerError}, true},
+		{&statusError{code: http.StatusServiceUnavailable}, true},
+		{&statusError{code: http.StatusTooManyRequests}, true},
+		{&statusError{code: http.StatusRequestTimeout}, true},
+		{&statusError{code: http.StatusUnauthorized}, false},
+		{&statusError{code: http.StatusForbidden}, false},
+		{&statusError{code: http.StatusBadRequest}, false},
+		{&statusError{code: http.StatusRequestEntityTooLarge}, false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// spoolExt 缓存文件扩展名
+const spoolExt = ".json"
+
+// spoolEntry 缓存中的一份上报
+type spoolEntry struct {
+	id   uint64 // 文件序号，越小越早
+	size int64  // 文件大小
+}
+
+// spool 磁盘上报缓存，发送失败的上报按顺序写入目录，恢复连接后从最早的开始补发
+type spool struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64        // 缓存总大小上限，超出时丢弃最早的上报
+	entries  []spoolEntry // 按序号升序排列
+	size     int64        // 当前总大小
+	nextID   uint64
+}
+
+// openSpool 打开缓存目录，加载上次未发送的上报
+func openSpool(dir string, maxBytes int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &spool{dir: dir, maxBytes: maxBytes, nextID: 1}
+	for _, f := range files {
+		name := f.Name()
+		// 清理写入中断留下的临时文件
+		if strings.HasSuffix(name, ".tmp") {
+			os.Remove(filepath.Join(dir, name))
+			continue
+		}
+		if f.IsDir() || !strings.HasSuffix(name, spoolExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, spoolExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		s.entries = append(s.entries, spoolEntry{id: id, size: info.Size()})
+		s.size += info.Size()
+		if id >= s.nextID {
+			s.nextID = id + 1
+		}
+	}
+	sort.Slice(s.entries, func(i, j int) bool { return s.entries[i].id < s.entries[j].id })
+
+	return s, nil
+}
+
+// path 返回缓存文件路径，序号定宽保证按文件名排序即按写入顺序
+func (s *spool) path(id uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", id, spoolExt))
+}
+
+// count 返回缓存中的上报数量
+func (s *spool) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// push 将上报写入缓存末尾，返回为腾出空间而丢弃的上报数量
+func (s *spool) push(payload []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := int64(len(payload))
+	if size > s.maxBytes {
+		return 0, fmt.Errorf("report of %d bytes exceeds spool size %d", size, s.maxBytes)
+	}
+
+	// 先写临时文件再重命名，避免崩溃后留下不完整的上报
+	id := s.nextID
+	tmp := s.path(id) + ".tmp"
+	if err := os.WriteFile(tmp, payload, 0600); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	if err := os.Rename(tmp, s.path(id)); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	s.nextID++
+
+	dropped := 0
+	for len(s.entries) > 0 && s.size+size > s.maxBytes {
+		s.removeLocked(s.entries[0].id)
+		dropped++
+	}
+
+	s.entries = append(s.entries, spoolEntry{id: id, size: size})
+	s.size += size
+	return dropped, nil
+}
+
+// peek 读取最早的上报，缓存为空时返回 ok=false
+func (s *spool) peek() (id uint64, payload []byte, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.entries) > 0 {
+		id = s.entries[0].id
+		payload, err = os.ReadFile(s.path(id))
+		if err == nil {
+			return id, payload, true, nil
+		}
+		if !os.IsNotExist(err) {
+			return 0, nil, false, err
+		}
+		// 文件已被外部删除，跳过
+		s.removeLocked(id)
+	}
+	return 0, nil, false, nil
+}
+
+// remove 删除已发送的上报
+func (s *spool) remove(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeLocked(id)
+}
+
+// removeLocked 删除指定上报，调用方需持有锁
+func (s *spool) removeLocked(id uint64) {
+	for i, e := range s.entries {
+		if e.id != id {
+			continue
+		}
+		if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove spooled report %d: %v", id, err)
+		}
+		s.size -= e.size
+		s.entries = append(s.entries[:i], s.entries[i+1:]...)
+		return
+	}
+}
+
+// backoff 带随机抖动的指数退避
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	attempt int
+}
+
+// next 返回下一次重试前的等待时间，取 [d/2, d] 之间的随机值，避免大量代理同时重连
+func (b *backoff) next() time.Duration {
+	d := b.min << uint(b.attempt)
+	if d > b.max || d <= 0 {
+		d = b.max
+	} else {
+		b.attempt++
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// reset 发送成功后重置退避
+func (b *backoff) reset() {
+	b.attempt = 0
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"mini-hids/agent/config"
+)
+
+// newHTTPClient 根据 TLS 配置创建访问服务端的 HTTP 客户端
+func newHTTPClient(cfg *config.Config) (*http.Client, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if !cfg.TLS.Enabled {
+		return client, nil
+	}
+
+	tlsConfig, err := buildTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Transport = &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	return client, nil
+}
+
+// buildTLSConfig 加载 CA、客户端证书，并配置服务端证书指纹校验
+func buildTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.TLS.ServerName,
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = cfg.ServerHost
+	}
+
+	if cfg.TLS.CAFile != "" {
+		pem, err := os.ReadFile(cfg.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.TLS.ServerCertSHA256 != "" {
+		pin, err := parseFingerprint(cfg.TLS.ServerCertSHA256)
+		if err != nil {
+			return nil, err
+		}
+
+		// 只配置指纹时（例如自签名证书）跳过证书链校验，仅以指纹为准
+		if cfg.TLS.CAFile == "" {
+			tlsConfig.InsecureSkipVerify = true
+		}
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("server presented no certificate")
+			}
+			sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+			if subtle.ConstantTimeCompare(sum[:], pin) != 1 {
+				return fmt.Errorf("server certificate fingerprint mismatch: got %s", hex.EncodeToString(sum[:]))
+			}
+			return nil
+		}
+	} else if cfg.TLS.CAFile == "" {
+		return nil, errors.New("tls: ca_file or server_cert_sha256 is required to verify the server")
+	}
+
+	return tlsConfig, nil
+}
+
+// parseFingerprint 解析十六进制 SHA-256 指纹，允许使用冒号分隔
+func parseFingerprint(value string) ([]byte, error) {
+	pin, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(value), ":", ""))
+	if err != nil || len(pin) != sha256.Size {
+		return nil, fmt.Errorf("tls.server_cert_sha256: invalid SHA-256 fingerprint %q", value)
+	}
+	return pin, nil
+}
+
+// clientCertAgentID 返回客户端证书的 CN，作为代理ID
+func clientCertAgentID(cfg *config.Config) (string, error) {
+	if !cfg.TLS.Enabled || cfg.TLS.CertFile == "" {
+		return "", nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	if err != nil {
+		return "", err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return "", err
+	}
+	return leaf.Subject.CommonName, nil
+}
+
+// serverURL 返回服务端接口地址
+func (a *Agent) serverURL(path string) string {
+	scheme := "http"
+	if a.config.TLS.Enabled {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%d%s", scheme, a.config.ServerHost, a.config.ServerPort, path)
+}
+
+// gzipBytes 使用 gzip 压缩数据
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// newRequest 创建发往服务端的 JSON 请求
+func (a *Agent) newRequest(path string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest("POST", a.serverURL(path), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mini-hids/server/alerts"
+	"mini-hids/server/rules"
+)
+
+// maxAlertPageSize 告警列表单页最大数量
+const maxAlertPageSize = 500
+
+// handleListAlerts 查询告警列表，支持 agent_id、rule_id、severity、status、assignee、since、limit、offset 参数
+func (s *Server) handleListAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := alerts.Filter{
+		AgentID:  query.Get("agent_id"),
+		RuleID:   query.Get("rule_id"),
+		Severity: query.Get("severity"),
+		Status:   query.Get("status"),
+		Assignee: query.Get("assignee"),
+		Limit:    50,
+	}
+
+	if filter.Severity != "" && rules.SeverityRank(filter.Severity) == 0 {
+		http.Error(w, "Invalid severity parameter", http.StatusBadRequest)
+		return
+	}
+	if filter.Status != "" && !alerts.ValidStatus(filter.Status) {
+		http.Error(w, "Invalid status parameter", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if filter.Since, err = parseTimeParam(query.Get("since")); err != nil {
+		http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+		return
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = min(n, maxAlertPageSize)
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid offset parameter", http.StatusBadRequest)
+			return
+		}
+		filter.Offset = n
+	}
+
+	list, total := s.alerts.List(filter)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"alerts": list,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// handleAlert 获取（GET）或更新（PATCH）单条告警
+func (s *Server) handleAlert(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/api/alerts/")
+	if id == "" || strings.Contains(id, "/") {
+		http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		alert, ok := s.alerts.Get(id)
+		if !ok {
+			http.Error(w, "Alert not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(alert)
+
+	case "PATCH":
+		var patch alerts.Patch
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBody)).Decode(&patch); err != nil {
+			http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+			return
+		}
+
+		alert, err := s.alerts.Update(id, patch)
+		switch {
+		case errors.Is(err, alerts.ErrNotFound):
+			http.Error(w, "Alert not found", http.StatusNotFound)
+			return
+		case errors.Is(err, alerts.ErrInvalidStatus):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			logErrorf("Failed to update alert %s: %v", id, err)
+			http.Error(w, "Failed to update alert", http.StatusInternalServerError)
+			return
+		}
+
+		logInfof("Alert %s updated: status=%s assignee=%q", alert.ID, alert.Status, alert.Assignee)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(alert)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
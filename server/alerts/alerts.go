@@ -0,0 +1,400 @@
+package alerts
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"mini-hids/server/rules"
+)
+
+// 告警状态
+const (
+	StatusOpen         = "open"
+	StatusAcknowledged = "acknowledged"
+	StatusResolved     = "resolved"
+	StatusSuppressed   = "suppressed"
+)
+
+// MaxAlerts 保留的告警数量上限，超出时优先清理最早的已解决告警
+const MaxAlerts = 10000
+
+// flushInterval 计数等非关键变更的落盘间隔
+const flushInterval = 5 * time.Second
+
+// 告警错误
+var (
+	ErrNotFound      = errors.New("alerts: alert not found")
+	ErrInvalidStatus = errors.New("alerts: invalid status")
+)
+
+// Alert 告警，同一代理上同一规则的重复命中合并为一条告警
+type Alert struct {
+	ID         string                 `json:"id"`                    // 告警ID
+	AgentID    string                 `json:"agent_id"`              // 代理ID
+	Hostname   string                 `json:"hostname"`              // 主机名
+	RuleID     string                 `json:"rule_id"`               // 规则ID
+	RuleName   string                 `json:"rule_name"`             // 规则名称
+	Severity   string                 `json:"severity"`              // 告警级别
+	Section    string                 `json:"section"`               // 命中的数据段
+	Status     string                 `json:"status"`                // 告警状态
+	Assignee   string                 `json:"assignee"`              // 处理人
+	FirstSeen  time.Time              `json:"first_seen"`            // 首次命中时间
+	LastSeen   time.Time              `json:"last_seen"`             // 最近命中时间
+	Count      int                    `json:"count"`                 // 命中次数
+	Evidence   map[string]interface{} `json:"evidence"`              // 最近一次命中的证据
+	UpdatedAt  time.Time              `json:"updated_at"`            // 最近更新时间
+	ResolvedAt *time.Time             `json:"resolved_at,omitempty"` // 解决时间
+}
+
+// Filter 告警查询条件，空字段不过滤
+type Filter struct {
+	AgentID  string
+	RuleID   string
+	Severity string
+	Status   string
+	Assignee string
+	Since    time.Time // 最近命中时间不早于该时间
+	Offset   int
+	Limit    int
+}
+
+// Patch 告警更新内容，nil 字段保持不变
+type Patch struct {
+	Status   *string `json:"status"`
+	Assignee *string `json:"assignee"`
+}
+
+// Store 告警存储，保存在 JSON 文件中
+type Store struct {
+	mu     sync.RWMutex
+	path   string
+	alerts map[string]*Alert
+	active map[string]string // 规则+代理 到未解决告警ID的映射
+	dirty  bool
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// Open 打开告警存储，path 为空时仅保存在内存中
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:   path,
+		alerts: make(map[string]*Alert),
+		active: make(map[string]string),
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return nil, err
+		default:
+			var list []*Alert
+			if err := json.Unmarshal(data, &list); err != nil {
+				return nil, fmt.Errorf("parse alerts %s: %w", path, err)
+			}
+			for _, alert := range list {
+				s.alerts[alert.ID] = alert
+				if alert.Status != StatusResolved {
+					s.active[dedupKey(alert.RuleID, alert.AgentID)] = alert.ID
+				}
+			}
+		}
+	}
+
+	go s.flushLoop()
+	return s, nil
+}
+
+// dedupKey 告警合并键
+func dedupKey(ruleID, agentID string) string {
+	return ruleID + "\x00" + agentID
+}
+
+// Record 记录一次规则命中，返回对应告警以及是否为新建告警
+func (s *Store) Record(m rules.Match) (Alert, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := dedupKey(m.RuleID, m.AgentID)
+	if id, ok := s.active[key]; ok {
+		alert := s.alerts[id]
+		alert.Count++
+		alert.Hostname = m.Hostname
+		alert.Evidence = m.Evidence
+		if m.Timestamp.After(alert.LastSeen) {
+			alert.LastSeen = m.Timestamp
+		}
+		s.dirty = true
+		return *alert, false, nil
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Alert{}, false, err
+	}
+
+	alert := &Alert{
+		ID:        id,
+		AgentID:   m.AgentID,
+		Hostname:  m.Hostname,
+		RuleID:    m.RuleID,
+		RuleName:  m.RuleName,
+		Severity:  m.Severity,
+		Section:   m.Section,
+		Status:    StatusOpen,
+		FirstSeen: m.Timestamp,
+		LastSeen:  m.Timestamp,
+		Count:     1,
+		Evidence:  m.Evidence,
+		UpdatedAt: m.Timestamp,
+	}
+	s.alerts[id] = alert
+	s.active[key] = id
+	s.prune()
+	s.dirty = true
+
+	return *alert, true, nil
+}
+
+// Get 获取告警
+func (s *Store) Get(id string) (Alert, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	alert, ok := s.alerts[id]
+	if !ok {
+		return Alert{}, false
+	}
+	return *alert, true
+}
+
+// List 按条件查询告警，按最近命中时间倒序，返回当前页与总数
+func (s *Store) List(f Filter) ([]Alert, int) {
+	s.mu.RLock()
+	matched := make([]Alert, 0)
+	for _, alert := range s.alerts {
+		if f.matches(alert) {
+			matched = append(matched, *alert)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].LastSeen.Equal(matched[j].LastSeen) {
+			return matched[i].LastSeen.After(matched[j].LastSeen)
+		}
+		return matched[i].ID < matched[j].ID
+	})
+
+	total := len(matched)
+	start := min(max(f.Offset, 0), total)
+	end := total
+	if f.Limit > 0 {
+		end = min(start+f.Limit, total)
+	}
+	return matched[start:end], total
+}
+
+// matches 判断告警是否满足查询条件
+func (f Filter) matches(alert *Alert) bool {
+	return (f.AgentID == "" || alert.AgentID == f.AgentID) &&
+		(f.RuleID == "" || alert.RuleID == f.RuleID) &&
+		(f.Severity == "" || alert.Severity == f.Severity) &&
+		(f.Status == "" || alert.Status == f.Status) &&
+		(f.Assignee == "" || alert.Assignee == f.Assignee) &&
+		(f.Since.IsZero() || !alert.LastSeen.Before(f.Since))
+}
+
+// ValidStatus 判断告警状态是否合法
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusOpen, StatusAcknowledged, StatusResolved, StatusSuppressed:
+		return true
+	default:
+		return false
+	}
+}
+
+// Update 更新告警状态或处理人，立即落盘
+func (s *Store) Update(id string, p Patch) (Alert, error) {
+	if p.Status != nil && !ValidStatus(*p.Status) {
+		return Alert{}, ErrInvalidStatus
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alert, ok := s.alerts[id]
+	if !ok {
+		return Alert{}, ErrNotFound
+	}
+
+	key := dedupKey(alert.RuleID, alert.AgentID)
+	now := time.Now()
+
+	if p.Status != nil && *p.Status != alert.Status {
+		switch {
+		case *p.Status == StatusResolved:
+			// 已解决的告警不再合并新的命中，再次命中时新建告警
+			alert.ResolvedAt = &now
+			if s.active[key] == id {
+				delete(s.active, key)
+			}
+		case alert.Status == StatusResolved:
+			// 重新打开时，若已有新的未解决告警则不能再合并
+			if _, exists := s.active[key]; exists {
+				return Alert{}, fmt.Errorf("%w: a newer unresolved alert exists for this rule and agent", ErrInvalidStatus)
+			}
+			alert.ResolvedAt = nil
+			s.active[key] = id
+		}
+		alert.Status = *p.Status
+	}
+	if p.Assignee != nil {
+		alert.Assignee = *p.Assignee
+	}
+	alert.UpdatedAt = now
+
+	s.dirty = true
+	if err := s.save(); err != nil {
+		return Alert{}, err
+	}
+	return *alert, nil
+}
+
+// Counts 按状态统计告警数量
+func (s *Store) Counts() map[string]int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := map[string]int{
+		StatusOpen:         0,
+		StatusAcknowledged: 0,
+		StatusResolved:     0,
+		StatusSuppressed:   0,
+	}
+	for _, alert := range s.alerts {
+		counts[alert.Status]++
+	}
+	return counts
+}
+
+// prune 超出上限时清理最早的已解决告警，调用方需持有写锁
+func (s *Store) prune() {
+	over := len(s.alerts) - MaxAlerts
+	if over <= 0 {
+		return
+	}
+
+	var resolved []*Alert
+	for _, alert := range s.alerts {
+		if alert.Status == StatusResolved {
+			resolved = append(resolved, alert)
+		}
+	}
+	sort.Slice(resolved, func(i, j int) bool {
+		return resolved[i].LastSeen.Before(resolved[j].LastSeen)
+	})
+
+	for i := 0; i < over && i < len(resolved); i++ {
+		delete(s.alerts, resolved[i].ID)
+	}
+}
+
+// flushLoop 定期将变更落盘
+func (s *Store) flushLoop() {
+	defer close(s.doneCh)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if err := s.save(); err != nil {
+				log.Printf("Failed to save alerts: %v", err)
+			}
+			s.mu.Unlock()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// Close 停止后台落盘并保存未写入的变更
+func (s *Store) Close() error {
+	close(s.stopCh)
+	<-s.doneCh
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+// save 原子地写入告警文件，调用方需持有写锁
+func (s *Store) save() error {
+	if s.path == "" || !s.dirty {
+		return nil
+	}
+
+	list := make([]*Alert, 0, len(s.alerts))
+	for _, alert := range s.alerts {
+		list = append(list, alert)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].FirstSeen.Before(list[j].FirstSeen)
+	})
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// newID 生成告警ID
+func newID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
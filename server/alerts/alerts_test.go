@@ -0,0 +1,292 @@
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mini-hids/server/rules"
+)
+
+// openTestStore 打开内存告警存储
+func openTestStore(t *testing.T, path string) *Store {
+	t.Helper()
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func match(ruleID, agentID string, at time.Time) rules.Match {
+	return rules.Match{
+		RuleID:    ruleID,
+		RuleName:  "rule " + ruleID,
+		Severity:  rules.SeverityHigh,
+		Section:   "processes",
+		AgentID:   agentID,
+		Hostname:  "host-" + agentID,
+		Evidence:  map[string]interface{}{"at": at.Unix()},
+		Timestamp: at,
+	}
+}
+
+func record(t *testing.T, s *Store, m rules.Match) (Alert, bool) {
+	t.Helper()
+	alert, created, err := s.Record(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return alert, created
+}
+
+func setStatus(s *Store, id, status string) (Alert, error) {
+	return s.Update(id, Patch{Status: &status})
+}
+
+func TestRecordDedup(t *testing.T) {
+	s := openTestStore(t, "")
+	base := time.Now().Add(-time.Hour)
+
+	first, created := record(t, s, match("r1", "a1", base))
+	if !created || first.Count != 1 || first.Status != StatusOpen {
+		t.Fatalf("first match: created %v, %+v", created, first)
+	}
+
+	// 同一规则和代理的命中合并，最近命中时间只向后移动
+	second, created := record(t, s, match("r1", "a1", base.Add(time.Minute)))
+	if created || second.ID != first.ID || second.Count != 2 || !second.LastSeen.Equal(base.Add(time.Minute)) {
+		t.Errorf("second match: created %v, %+v", created, second)
+	}
+	late, _ := record(t, s, match("r1", "a1", base.Add(30*time.Second)))
+	if late.Count != 3 || !late.LastSeen.Equal(base.Add(time.Minute)) || !late.FirstSeen.Equal(base) {
+		t.Errorf("out-of-order match: %+v", late)
+	}
+	if late.Evidence["at"] != base.Add(30*time.Second).Unix() {
+		t.Errorf("evidence not updated to the latest match: %v", late.Evidence)
+	}
+
+	// 不同规则或不同代理各自成为新告警
+	if other, created := record(t, s, match("r2", "a1", base)); !created || other.ID == first.ID {
+		t.Error("match of another rule merged into existing alert")
+	}
+	if other, created := record(t, s, match("r1", "a2", base)); !created || other.ID == first.ID {
+		t.Error("match on another agent merged into existing alert")
+	}
+	if _, total := s.List(Filter{}); total != 3 {
+		t.Errorf("%d alerts, want 3", total)
+	}
+
+	// 确认和屏蔽的告警仍然合并新的命中
+	for _, status := range []string{StatusAcknowledged, StatusSuppressed} {
+		if _, err := setStatus(s, first.ID, status); err != nil {
+			t.Fatal(err)
+		}
+		if alert, created := record(t, s, match("r1", "a1", base.Add(2*time.Minute))); created || alert.ID != first.ID {
+			t.Errorf("match after %s created a new alert", status)
+		}
+	}
+}
+
+func TestResolveAndReopen(t *testing.T) {
+	s := openTestStore(t, "")
+	base := time.Now().Add(-time.Hour)
+
+	old, _ := record(t, s, match("r1", "a1", base))
+	resolved, err := setStatus(s, old.ID, StatusResolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.ResolvedAt == nil {
+		t.Error("resolved alert has no resolved_at")
+	}
+
+	// 已解决的告警可以重新打开，之后的命中继续合并到它
+	reopened, err := setStatus(s, old.ID, StatusOpen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.ResolvedAt != nil {
+		t.Error("reopened alert still has resolved_at")
+	}
+	if alert, created := record(t, s, match("r1", "a1", base.Add(time.Minute))); created || alert.ID != old.ID {
+		t.Error("match after reopen did not merge into the reopened alert")
+	}
+
+	// 解决后再次命中新建告警
+	if _, err := setStatus(s, old.ID, StatusResolved); err != nil {
+		t.Fatal(err)
+	}
+	newer, created := record(t, s, match("r1", "a1", base.Add(2*time.Minute)))
+	if !created || newer.ID == old.ID {
+		t.Fatal("match after resolve merged into the resolved alert")
+	}
+
+	// 已有更新的未解决告警时不能重新打开旧告警
+	_, err = setStatus(s, old.ID, StatusAcknowledged)
+	if !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("reopen with newer active alert: error %v, want ErrInvalidStatus", err)
+	}
+	if alert, _ := s.Get(old.ID); alert.Status != StatusResolved {
+		t.Errorf("blocked reopen changed status to %s", alert.Status)
+	}
+
+	// 新告警解决后，旧告警可以重新打开
+	if _, err := setStatus(s, newer.ID, StatusResolved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := setStatus(s, old.ID, StatusAcknowledged); err != nil {
+		t.Errorf("reopen after newer alert was resolved: %v", err)
+	}
+	if alert, created := record(t, s, match("r1", "a1", base.Add(3*time.Minute))); created || alert.ID != old.ID {
+		t.Error("match did not merge into the reopened alert")
+	}
+}
+
+func TestUpdateErrors(t *testing.T) {
+	s := openTestStore(t, "")
+	alert, _ := record(t, s, match("r1", "a1", time.Now()))
+
+	if _, err := setStatus(s, alert.ID, "closed"); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("invalid status: error %v", err)
+	}
+	if _, err := setStatus(s, "missing", StatusResolved); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing alert: error %v", err)
+	}
+
+	assignee := "alice"
+	updated, err := s.Update(alert.ID, Patch{Assignee: &assignee})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Assignee != "alice" || updated.Status != StatusOpen {
+		t.Errorf("assign: %+v", updated)
+	}
+}
+
+func TestListPagination(t *testing.T) {
+	s := openTestStore(t, "")
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 25; i++ {
+		record(t, s, match(fmt.Sprintf("r%02d", i), "a1", base.Add(time.Duration(i)*time.Minute)))
+	}
+
+	tests := []struct {
+		offset, limit int
+		first, n      int // 第一条的规则序号与返回数量
+	}{
+		{0, 0, 24, 25},
+		{0, 10, 24, 10},
+		{10, 10, 14, 10},
+		{20, 10, 4, 5},
+		{25, 10, 0, 0},
+		{100, 10, 0, 0},
+		{-5, 3, 24, 3},
+	}
+	for _, tt := range tests {
+		page, total := s.List(Filter{Offset: tt.offset, Limit: tt.limit})
+		if total != 25 {
+			t.Errorf("offset %d limit %d: total %d, want 25", tt.offset, tt.limit, total)
+		}
+		if len(page) != tt.n {
+			t.Errorf("offset %d limit %d: %d alerts, want %d", tt.offset, tt.limit, len(page), tt.n)
+			continue
+		}
+		// 按最近命中时间倒序
+		for i, alert := range page {
+			if want := fmt.Sprintf("r%02d", tt.first-i); alert.RuleID != want {
+				t.Errorf("offset %d limit %d: [%d] = %s, want %s", tt.offset, tt.limit, i, alert.RuleID, want)
+				break
+			}
+		}
+	}
+
+	// 过滤后的总数与分页
+	if _, err := setStatus(s, mustFind(t, s, "r03").ID, StatusResolved); err != nil {
+		t.Fatal(err)
+	}
+	page, total := s.List(Filter{Status: StatusOpen, Since: base.Add(10 * time.Minute), Limit: 5})
+	if total != 15 || len(page) != 5 || page[0].RuleID != "r24" {
+		t.Errorf("filtered: total %d, %d alerts, first %s", total, len(page), page[0].RuleID)
+	}
+	if page, total := s.List(Filter{Status: StatusResolved}); total != 1 || page[0].RuleID != "r03" {
+		t.Errorf("resolved filter: total %d", total)
+	}
+}
+
+// mustFind 按规则ID查找告警
+func mustFind(t *testing.T, s *Store, ruleID string) Alert {
+	t.Helper()
+	page, _ := s.List(Filter{RuleID: ruleID})
+	if len(page) != 1 {
+		t.Fatalf("%d alerts for rule %s", len(page), ruleID)
+	}
+	return page[0]
+}
+
+func TestReloadFromDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now().Add(-time.Hour)
+	resolved, _ := record(t, s, match("r1", "a1", base))
+	if _, err := setStatus(s, resolved.ID, StatusResolved); err != nil {
+		t.Fatal(err)
+	}
+	active, _ := record(t, s, match("r1", "a1", base.Add(time.Minute)))
+	record(t, s, match("r1", "a1", base.Add(2*time.Minute)))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := openTestStore(t, path)
+	if alert, ok := reopened.Get(active.ID); !ok || alert.Count != 2 {
+		t.Fatalf("active alert after reload: %+v", alert)
+	}
+	// 重新加载后未解决的告警继续合并命中
+	if alert, created := record(t, reopened, match("r1", "a1", base.Add(3*time.Minute))); created || alert.ID != active.ID {
+		t.Error("match after reload did not merge into the active alert")
+	}
+	if _, err := setStatus(reopened, resolved.ID, StatusOpen); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("reopen after reload: error %v, want ErrInvalidStatus", err)
+	}
+}
+
+func TestPruneResolved(t *testing.T) {
+	s := openTestStore(t, "")
+	base := time.Now().Add(-24 * time.Hour)
+
+	// 先产生 10 条已解决告警，再填满上限
+	for i := 0; i < 10; i++ {
+		alert, _ := record(t, s, match("resolved", fmt.Sprintf("a%d", i), base.Add(time.Duration(i)*time.Second)))
+		if _, err := setStatus(s, alert.ID, StatusResolved); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < MaxAlerts-10; i++ {
+		record(t, s, match("open", fmt.Sprintf("a%d", i), base.Add(time.Hour)))
+	}
+	// 超出上限时清理最早的已解决告警，未解决的告警不受影响
+	for i := 0; i < 3; i++ {
+		record(t, s, match("extra", fmt.Sprintf("a%d", i), base.Add(2*time.Hour)))
+	}
+
+	if _, total := s.List(Filter{}); total != MaxAlerts {
+		t.Errorf("%d alerts, want %d", total, MaxAlerts)
+	}
+	page, total := s.List(Filter{Status: StatusResolved})
+	if total != 7 {
+		t.Fatalf("%d resolved alerts, want 7", total)
+	}
+	if oldest := page[len(page)-1]; oldest.AgentID != "a3" {
+		t.Errorf("oldest remaining resolved alert is on %s, want a3", oldest.AgentID)
+	}
+	if counts := s.Counts(); counts[StatusOpen] != MaxAlerts-7 {
+		t.Errorf("counts = %v", counts)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"mini-hids/server/registry"
+)
+
+// agentIDHeader 已注册代理上报时携带代理ID的请求头，密钥通过 Authorization 传递
+const agentIDHeader = "X-Agent-ID"
+
+// contextKey 请求上下文键
+type contextKey int
+
+// agentIDKey 已认证的代理ID
+const agentIDKey contextKey = iota
+
+// authScope 接口所需的权限范围
+type authScope int
+
+const (
+	scopeIngest authScope = iota // 代理数据上报
+	scopeRead                    // 查询接口，非 GET 请求需要管理密钥
+	scopeAdmin                   // 管理操作
+)
+
+// authRole 密钥对应的角色
+type authRole int
+
+const (
+	roleNone      authRole = iota
+	roleAdmin              // api_key
+	roleIngest             // ingest_api_key
+	roleDashboard          // dashboard_api_key
+)
+
+// authMiddleware API 认证中间件，启用认证时要求 Authorization: Bearer <key>
+func (s *Server) authMiddleware(scope authScope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if scope == scopeIngest {
+			// 客户端证书的 CN 即代理ID
+			if agentID, ok := clientCertAgent(r); ok {
+				s.certAuth(agentID, next)(w, r)
+				return
+			}
+			if s.config.TLS.RequireClientCert {
+				logWarnf("Rejected request %s %s from %s: client certificate required", r.Method, r.URL.Path, r.RemoteAddr)
+				http.Error(w, "Client certificate required", http.StatusUnauthorized)
+				return
+			}
+
+			// 已注册代理使用自己的凭据上报，与 enable_auth 无关
+			if r.Header.Get(agentIDHeader) != "" {
+				s.agentAuth(next)(w, r)
+				return
+			}
+		}
+
+		if !s.config.Security.EnableAuth {
+			next(w, r)
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok {
+			logDebugf("Rejected unauthenticated request %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mini-hids"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		role := s.roleForToken(token)
+		if role == roleNone {
+			logWarnf("Rejected request %s %s from %s: invalid API key", r.Method, r.URL.Path, r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mini-hids", error="invalid_token"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !roleAllowed(role, scope, r.Method) {
+			logWarnf("Rejected request %s %s from %s: API key not permitted", r.Method, r.URL.Path, r.RemoteAddr)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// agentAuth 校验已注册代理的凭据，并将代理ID写入请求上下文
+func (s *Server) agentAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		agentID := r.Header.Get(agentIDHeader)
+		secret, _ := bearerToken(r)
+
+		if err := s.registry.Authenticate(agentID, secret); err != nil {
+			logWarnf("Rejected agent %s from %s: %v", agentID, r.RemoteAddr, err)
+			if errors.Is(err, registry.ErrRevoked) {
+				http.Error(w, "Agent revoked", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="mini-hids", error="invalid_token"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), agentIDKey, agentID)))
+	}
+}
+
+// certAuth 使用客户端证书认证代理，证书中的代理ID不能被请求内容覆盖
+func (s *Server) certAuth(agentID string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// 记录到注册表后，不带证书的请求不能再冒用该ID上报
+		if err := s.registry.RecordCertAgent(agentID); err != nil {
+			logErrorf("Failed to record certificate agent %s: %v", agentID, err)
+		}
+
+		// 同时携带注册凭据时，两者必须指向同一代理
+		if headerID := r.Header.Get(agentIDHeader); headerID != "" {
+			if headerID != agentID {
+				logWarnf("Rejected agent %s from %s: client certificate is for %s", headerID, r.RemoteAddr, agentID)
+				http.Error(w, "Agent ID does not match client certificate", http.StatusForbidden)
+				return
+			}
+			s.agentAuth(next)(w, r)
+			return
+		}
+
+		if agent, ok := s.registry.Get(agentID); ok && agent.Revoked {
+			logWarnf("Rejected agent %s from %s: %v", agentID, r.RemoteAddr, registry.ErrRevoked)
+			http.Error(w, "Agent revoked", http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), agentIDKey, agentID)))
+	}
+}
+
+// authenticatedAgent 返回通过凭据或客户端证书认证的代理ID
+func authenticatedAgent(r *http.Request) (string, bool) {
+	agentID, ok := r.Context().Value(agentIDKey).(string)
+	return agentID, ok
+}
+
+// bearerToken 从 Authorization 头中提取 Bearer 令牌
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// roleForToken 根据密钥确定角色，比较时间与密钥内容无关
+func (s *Server) roleForToken(token string) authRole {
+	sec := s.config.Security
+	role := roleNone
+
+	for _, candidate := range []struct {
+		key  string
+		role authRole
+	}{
+		{sec.APIKey, roleAdmin},
+		{sec.IngestAPIKey, roleIngest},
+		{sec.DashboardAPIKey, roleDashboard},
+	} {
+		if candidate.key != "" && subtle.ConstantTimeCompare([]byte(token), []byte(candidate.key)) == 1 {
+			role = candidate.role
+		}
+	}
+
+	return role
+}
+
+// roleAllowed 判断角色是否可以访问接口
+func roleAllowed(role authRole, scope authScope, method string) bool {
+	if role == roleAdmin {
+		return true
+	}
+
+	switch scope {
+	case scopeIngest:
+		return role == roleIngest
+	case scopeRead:
+		return role == roleDashboard && (method == http.MethodGet || method == http.MethodHead)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"testing"
+	"time"
+
+	"mini-hids/server/storage"
+)
+
+// agentHeader 已注册代理上报时携带的凭据
+func agentHeader(agentID, secret string) http.Header {
+	return http.Header{
+		agentIDHeader:   {agentID},
+		"Authorization": {"Bearer " + secret},
+	}
+}
+
+// bearerHeader 使用共享密钥的请求头
+func bearerHeader(key string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + key}}
+}
+
+func testReport(agentID string) AgentData {
+	return AgentData{
+		AgentID:   agentID,
+		Hostname:  "host",
+		Timestamp: time.Now().Add(-time.Minute),
+		Data:      map[string]interface{}{},
+	}
+}
+
+func TestEnrolledAgentImpersonation(t *testing.T) {
+	for _, enableAuth := range []bool{false, true} {
+		name := "auth disabled"
+		if enableAuth {
+			name = "shared ingest key"
+		}
+		t.Run(name, func(t *testing.T) {
+			store := storage.NewMemoryStore(0)
+			s := newTestServer(t, store)
+			s.config.Security.EnableAuth = enableAuth
+			s.config.Security.IngestAPIKey = "ingest-key"
+
+			victim, secret, err := s.registry.Enroll("victim")
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, otherSecret, err := s.registry.Enroll("other")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var shared http.Header
+			if enableAuth {
+				shared = bearerHeader("ingest-key")
+			}
+
+			// 不带该代理凭据，仅在请求体中声明已注册代理的ID
+			if rec := postReport(t, s, testReport(victim.ID), shared); rec.Code != http.StatusForbidden {
+				t.Errorf("report claiming enrolled agent: status %d, want 403", rec.Code)
+			}
+			if n := store.Count(); n != 0 {
+				t.Fatalf("impersonated report was stored (%d records)", n)
+			}
+
+			// 已注册代理以自己的凭据上报，请求体中的其他ID不生效
+			if rec := postReport(t, s, testReport(victim.ID), agentHeader(other.ID, otherSecret)); rec.Code != http.StatusOK {
+				t.Fatalf("report with own credentials: status %d", rec.Code)
+			}
+			if data, _ := store.Latest(victim.ID, 1); len(data) != 0 {
+				t.Error("report from another agent was stored under the claimed agent ID")
+			}
+			if data, _ := store.Latest(other.ID, 1); len(data) != 1 {
+				t.Error("report was not stored under the authenticated agent ID")
+			}
+
+			if rec := postReport(t, s, testReport(victim.ID), agentHeader(victim.ID, secret)); rec.Code != http.StatusOK {
+				t.Fatalf("report with victim credentials: status %d", rec.Code)
+			}
+			if data, _ := store.Latest(victim.ID, 1); len(data) != 1 {
+				t.Error("report with the agent's own credentials was not stored")
+			}
+
+			// 未注册的代理仍可使用共享密钥或在关闭认证时上报
+			if rec := postReport(t, s, testReport("legacy-agent"), shared); rec.Code != http.StatusOK {
+				t.Errorf("report from unenrolled agent: status %d, want 200", rec.Code)
+			}
+		})
+	}
+}
+
+func TestRevokedAgentCannotReport(t *testing.T) {
+	store := storage.NewMemoryStore(0)
+	s := newTestServer(t, store)
+	s.config.Security.IngestAPIKey = "ingest-key"
+
+	agent, secret, err := s.registry.Enroll("revoked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := postReport(t, s, testReport(agent.ID), agentHeader(agent.ID, secret)); rec.Code != http.StatusOK {
+		t.Fatalf("report before revocation: status %d", rec.Code)
+	}
+	if err := s.registry.Revoke(agent.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		enableAuth bool
+		header     http.Header
+	}{
+		{"own credentials", false, agentHeader(agent.ID, secret)},
+		{"no credentials", false, nil},
+		{"shared ingest key", true, bearerHeader("ingest-key")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.config.Security.EnableAuth = tt.enableAuth
+			if rec := postReport(t, s, testReport(agent.ID), tt.header); rec.Code != http.StatusForbidden {
+				t.Errorf("status %d, want 403", rec.Code)
+			}
+		})
+	}
+
+	if n := store.Count(); n != 1 {
+		t.Errorf("stored %d records, want only the report before revocation", n)
+	}
+}
+
+// clientCert 模拟已通过 client_ca_file 验证的客户端证书
+func clientCert(cn string) *tls.ConnectionState {
+	return &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}},
+	}
+}
+
+func TestCertAgentImpersonation(t *testing.T) {
+	for _, enableAuth := range []bool{false, true} {
+		name := "auth disabled"
+		if enableAuth {
+			name = "shared ingest key"
+		}
+		t.Run(name, func(t *testing.T) {
+			store := storage.NewMemoryStore(0)
+			s := newTestServer(t, store)
+			s.config.Security.EnableAuth = enableAuth
+			s.config.Security.IngestAPIKey = "ingest-key"
+
+			var shared http.Header
+			if enableAuth {
+				shared = bearerHeader("ingest-key")
+			}
+
+			// 证书代理上报一次后，其 CN 被记录下来
+			if rec := postReportTLS(t, s, testReport("cert-agent"), nil, clientCert("cert-agent")); rec.Code != http.StatusOK {
+				t.Fatalf("report with client certificate: status %d", rec.Code)
+			}
+			if agent, ok := s.registry.Get("cert-agent"); !ok || !agent.CertAuth {
+				t.Fatalf("certificate agent not recorded: %+v", agent)
+			}
+
+			// 不带证书声明同一ID的上报被拒绝
+			if rec := postReport(t, s, testReport("cert-agent"), shared); rec.Code != http.StatusForbidden {
+				t.Errorf("report claiming certificate agent without certificate: status %d, want 403", rec.Code)
+			}
+			// 其他证书的代理也不能冒用
+			if rec := postReportTLS(t, s, testReport("cert-agent"), nil, clientCert("other-agent")); rec.Code != http.StatusOK {
+				t.Fatalf("report with other certificate: status %d", rec.Code)
+			}
+			if data, _ := store.Latest("cert-agent", 10); len(data) != 1 {
+				t.Errorf("certificate agent has %d reports, want 1", len(data))
+			}
+
+			// 证书代理的ID没有密钥，不能通过凭据认证
+			if rec := postReport(t, s, testReport("cert-agent"), agentHeader("cert-agent", "")); rec.Code != http.StatusUnauthorized {
+				t.Errorf("credentials for certificate agent: status %d, want 401", rec.Code)
+			}
+		})
+	}
+}
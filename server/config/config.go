@@ -0,0 +1,375 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"mini-hids/server/notify"
+)
+
+// 环境变量前缀，例如 MINI_HIDS_PORT 覆盖 port
+const envPrefix = "MINI_HIDS_"
+
+// Config 服务端配置结构
+type Config struct {
+	Port     int            `json:"port"`      // 服务端口
+	LogLevel string         `json:"log_level"` // 日志级别（debug/info/warn/error）
+	WebDir   string         `json:"web_dir"`   // Web 文件目录
+	RulesDir string         `json:"rules_dir"` // 检测规则目录
+	Database DatabaseConfig `json:"database"`  // 数据存储配置
+	Security SecurityConfig `json:"security"`  // 安全配置
+	TLS      TLSConfig      `json:"tls"`       // TLS 配置
+	Ingest   IngestConfig   `json:"ingest"`    // 数据上报配置
+
+	Monitor       MonitorConfig      `json:"monitor"`       // 代理存活监控配置
+	Notifications NotificationConfig `json:"notifications"` // 通知配置
+}
+
+// DatabaseConfig 数据存储配置
+type DatabaseConfig struct {
+	Type          string `json:"type"`           // 存储类型（disk/memory）
+	Path          string `json:"path"`           // 数据目录
+	RetentionDays int    `json:"retention_days"` // 数据保留天数，0 表示永久保留
+
+	MaxRecordsPerAgent int `json:"max_records_per_agent"` // 每个代理在内存中保留的记录数，0 使用存储默认值
+}
+
+// SecurityConfig 安全配置
+type SecurityConfig struct {
+	EnableAuth      bool   `json:"enable_auth"`       // 是否启用认证
+	APIKey          string `json:"api_key"`           // 管理密钥，可访问全部接口
+	IngestAPIKey    string `json:"ingest_api_key"`    // 上报密钥，仅可上报代理数据
+	DashboardAPIKey string `json:"dashboard_api_key"` // 只读密钥，仅可查询数据
+	EnrollmentToken string `json:"enrollment_token"`  // 代理注册令牌，为空时禁用注册
+	RegistryPath    string `json:"registry_path"`     // 代理注册表文件
+}
+
+// TLSConfig TLS 配置
+type TLSConfig struct {
+	Enabled           bool   `json:"enabled"`             // 是否启用 HTTPS
+	CertFile          string `json:"cert_file"`           // 服务端证书
+	KeyFile           string `json:"key_file"`            // 服务端私钥
+	ClientCAFile      string `json:"client_ca_file"`      // 签发代理客户端证书的 CA
+	RequireClientCert bool   `json:"require_client_cert"` // 上报接口是否必须使用客户端证书
+}
+
+// IngestConfig 数据上报配置
+type IngestConfig struct {
+	MaxBodyMB         int `json:"max_body_mb"`         // 上报请求体上限（MB，压缩后）
+	MaxDecompressedMB int `json:"max_decompressed_mb"` // 解压后的上报数据上限（MB）
+}
+
+// MonitorConfig 代理存活监控配置
+type MonitorConfig struct {
+	DefaultInterval int     `json:"default_interval"` // 代理未上报间隔时的默认间隔（秒）
+	LateFactor      float64 `json:"late_factor"`      // 超过 上报间隔×late_factor 未上报视为迟到
+	OfflineFactor   float64 `json:"offline_factor"`   // 超过 上报间隔×offline_factor 未上报视为离线
+
+	ClockSkewThreshold int `json:"clock_skew_threshold"` // 代理时钟与服务端相差超过该值（秒）时标记为时钟偏差
+}
+
+// NotificationConfig 通知配置
+type NotificationConfig struct {
+	MaxRetries int             `json:"max_retries"` // 投递失败后的最大重试次数
+	Webhooks   []WebhookConfig `json:"webhooks"`    // Webhook 目标
+}
+
+// WebhookConfig Webhook 目标配置
+type WebhookConfig struct {
+	Name        string   `json:"name"`         // 目标名称
+	URL         string   `json:"url"`          // 回调地址
+	Format      string   `json:"format"`       // 消息格式（generic/slack/dingtalk/wecom）
+	Secret      string   `json:"secret"`       // 签名密钥，为空时不签名
+	MinSeverity string   `json:"min_severity"` // 告警最低级别，默认 high
+	Events      []string `json:"events"`       // 订阅的事件（alert/agent_offline），为空时订阅全部
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Port:     8848,
+		LogLevel: "info",
+		WebDir:   "./web",
+		RulesDir: "./rules",
+
+		Database: DatabaseConfig{
+			Type: "disk",
+			Path: "./mini-hids-data",
+		},
+
+		Security: SecurityConfig{
+			EnableAuth:      false,
+			APIKey:          "",
+			IngestAPIKey:    "",
+			DashboardAPIKey: "",
+			EnrollmentToken: "",
+			RegistryPath:    "./mini-hids-agents.json",
+		},
+
+		Ingest: IngestConfig{
+			MaxBodyMB:         10,
+			MaxDecompressedMB: 64,
+		},
+
+		Monitor: MonitorConfig{
+			DefaultInterval: 30,
+			LateFactor:      2,
+			OfflineFactor:   6,
+
+			ClockSkewThreshold: 60,
+		},
+
+		Notifications: NotificationConfig{
+			MaxRetries: 3,
+			Webhooks:   []WebhookConfig{},
+		},
+	}
+}
+
+// Load 加载配置文件，并应用环境变量覆盖
+func Load(configPath string) (*Config, error) {
+	config := DefaultConfig()
+
+	data, err := os.ReadFile(configPath)
+	switch {
+	case os.IsNotExist(err):
+		// 如果配置文件不存在，创建默认配置
+		log.Printf("Config file %s not found, creating default config", configPath)
+		if err := config.Save(configPath); err != nil {
+			log.Printf("Failed to save default config: %v", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("read config file: %w", err)
+	default:
+		// 未出现的字段保留默认值
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("parse config file %s: %w", configPath, err)
+		}
+	}
+
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// applyEnv 使用环境变量覆盖配置，webhook 列表只能在配置文件中设置
+func (c *Config) applyEnv() error {
+	var errs []error
+
+	lookup := func(name string) (string, bool) {
+		return os.LookupEnv(envPrefix + name)
+	}
+	setString := func(name string, dst *string) {
+		if v, ok := lookup(name); ok {
+			*dst = v
+		}
+	}
+	setInt := func(name string, dst *int) {
+		if v, ok := lookup(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: invalid integer %q", envPrefix, name, v))
+			}
+			*dst = n
+		}
+	}
+	setFloat := func(name string, dst *float64) {
+		if v, ok := lookup(name); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: invalid number %q", envPrefix, name, v))
+			}
+			*dst = f
+		}
+	}
+	setBool := func(name string, dst *bool) {
+		if v, ok := lookup(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: invalid boolean %q", envPrefix, name, v))
+			}
+			*dst = b
+		}
+	}
+
+	setInt("PORT", &c.Port)
+	setString("LOG_LEVEL", &c.LogLevel)
+	setString("WEB_DIR", &c.WebDir)
+	setString("RULES_DIR", &c.RulesDir)
+
+	setString("DB_TYPE", &c.Database.Type)
+	setString("DB_PATH", &c.Database.Path)
+	setInt("DB_RETENTION_DAYS", &c.Database.RetentionDays)
+	setInt("DB_MAX_RECORDS_PER_AGENT", &c.Database.MaxRecordsPerAgent)
+
+	setBool("ENABLE_AUTH", &c.Security.EnableAuth)
+	setString("API_KEY", &c.Security.APIKey)
+	setString("INGEST_API_KEY", &c.Security.IngestAPIKey)
+	setString("DASHBOARD_API_KEY", &c.Security.DashboardAPIKey)
+	setString("ENROLLMENT_TOKEN", &c.Security.EnrollmentToken)
+	setString("REGISTRY_PATH", &c.Security.RegistryPath)
+
+	setBool("TLS_ENABLED", &c.TLS.Enabled)
+	setString("TLS_CERT_FILE", &c.TLS.CertFile)
+	setString("TLS_KEY_FILE", &c.TLS.KeyFile)
+	setString("TLS_CLIENT_CA_FILE", &c.TLS.ClientCAFile)
+	setBool("TLS_REQUIRE_CLIENT_CERT", &c.TLS.RequireClientCert)
+
+	setInt("INGEST_MAX_BODY_MB", &c.Ingest.MaxBodyMB)
+	setInt("INGEST_MAX_DECOMPRESSED_MB", &c.Ingest.MaxDecompressedMB)
+
+	setInt("MONITOR_DEFAULT_INTERVAL", &c.Monitor.DefaultInterval)
+	setFloat("MONITOR_LATE_FACTOR", &c.Monitor.LateFactor)
+	setFloat("MONITOR_OFFLINE_FACTOR", &c.Monitor.OfflineFactor)
+	setInt("MONITOR_CLOCK_SKEW_THRESHOLD", &c.Monitor.ClockSkewThreshold)
+
+	setInt("NOTIFY_MAX_RETRIES", &c.Notifications.MaxRetries)
+
+	return errors.Join(errs...)
+}
+
+// Validate 校验配置
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port: must be between 1 and 65535, got %d", c.Port))
+	}
+
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log_level: must be one of debug, info, warn, error, got %q", c.LogLevel))
+	}
+
+	switch c.Database.Type {
+	case "disk":
+		if c.Database.Path == "" {
+			errs = append(errs, errors.New("database.path: required for disk storage"))
+		}
+	case "memory":
+	default:
+		errs = append(errs, fmt.Errorf("database.type: must be disk or memory, got %q", c.Database.Type))
+	}
+
+	if c.Database.RetentionDays < 0 {
+		errs = append(errs, fmt.Errorf("database.retention_days: must not be negative, got %d", c.Database.RetentionDays))
+	}
+	if c.Database.MaxRecordsPerAgent < 0 {
+		errs = append(errs, fmt.Errorf("database.max_records_per_agent: must not be negative, got %d", c.Database.MaxRecordsPerAgent))
+	}
+
+	sec := c.Security
+	if sec.EnableAuth && sec.APIKey == "" && sec.IngestAPIKey == "" && sec.DashboardAPIKey == "" {
+		errs = append(errs, errors.New("security: at least one of api_key, ingest_api_key, dashboard_api_key is required when enable_auth is true"))
+	}
+	if duplicateKeys(sec.APIKey, sec.IngestAPIKey, sec.DashboardAPIKey, sec.EnrollmentToken) {
+		errs = append(errs, errors.New("security: api_key, ingest_api_key, dashboard_api_key and enrollment_token must be distinct"))
+	}
+	if sec.EnrollmentToken != "" && sec.RegistryPath == "" {
+		errs = append(errs, errors.New("security.registry_path: required when enrollment_token is set"))
+	}
+
+	if c.TLS.Enabled {
+		if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+			errs = append(errs, errors.New("tls: cert_file and key_file are required when enabled"))
+		}
+		if c.TLS.RequireClientCert && c.TLS.ClientCAFile == "" {
+			errs = append(errs, errors.New("tls.client_ca_file: required when require_client_cert is true"))
+		}
+	} else if c.TLS.RequireClientCert {
+		errs = append(errs, errors.New("tls.require_client_cert: requires tls.enabled"))
+	}
+
+	if c.Ingest.MaxBodyMB <= 0 {
+		errs = append(errs, fmt.Errorf("ingest.max_body_mb: must be positive, got %d", c.Ingest.MaxBodyMB))
+	}
+	if c.Ingest.MaxDecompressedMB < c.Ingest.MaxBodyMB {
+		errs = append(errs, fmt.Errorf("ingest.max_decompressed_mb: must be at least max_body_mb, got %d", c.Ingest.MaxDecompressedMB))
+	}
+	if c.Monitor.DefaultInterval <= 0 {
+		errs = append(errs, fmt.Errorf("monitor.default_interval: must be positive, got %d", c.Monitor.DefaultInterval))
+	}
+	if c.Monitor.LateFactor < 1 {
+		errs = append(errs, fmt.Errorf("monitor.late_factor: must be at least 1, got %g", c.Monitor.LateFactor))
+	}
+	if c.Monitor.OfflineFactor <= c.Monitor.LateFactor {
+		errs = append(errs, fmt.Errorf("monitor.offline_factor: must be greater than late_factor, got %g", c.Monitor.OfflineFactor))
+	}
+	if c.Monitor.ClockSkewThreshold <= 0 {
+		errs = append(errs, fmt.Errorf("monitor.clock_skew_threshold: must be positive, got %d", c.Monitor.ClockSkewThreshold))
+	}
+
+	if c.Notifications.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("notifications.max_retries: must not be negative, got %d", c.Notifications.MaxRetries))
+	}
+	names := make(map[string]bool)
+	for i, hook := range c.Notifications.Webhooks {
+		prefix := fmt.Sprintf("notifications.webhooks[%d]", i)
+		if hook.Name == "" {
+			errs = append(errs, fmt.Errorf("%s.name: required", prefix))
+		} else if names[hook.Name] {
+			errs = append(errs, fmt.Errorf("%s.name: duplicate name %q", prefix, hook.Name))
+		}
+		names[hook.Name] = true
+
+		if !strings.HasPrefix(hook.URL, "http://") && !strings.HasPrefix(hook.URL, "https://") {
+			errs = append(errs, fmt.Errorf("%s.url: must be an http or https URL, got %q", prefix, hook.URL))
+		}
+		if hook.Format != "" && !notify.ValidFormat(hook.Format) {
+			errs = append(errs, fmt.Errorf("%s.format: must be one of generic, slack, dingtalk, wecom, got %q", prefix, hook.Format))
+		}
+		switch hook.MinSeverity {
+		case "", "low", "medium", "high", "critical":
+		default:
+			errs = append(errs, fmt.Errorf("%s.min_severity: must be one of low, medium, high, critical, got %q", prefix, hook.MinSeverity))
+		}
+		for _, event := range hook.Events {
+			if event != "alert" && event != "agent_offline" {
+				errs = append(errs, fmt.Errorf("%s.events: unknown event %q, expected alert or agent_offline", prefix, event))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// duplicateKeys 判断非空密钥中是否有重复
+func duplicateKeys(keys ...string) bool {
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if seen[key] {
+			return true
+		}
+		seen[key] = true
+	}
+	return false
+}
+
+// Save 保存配置到文件
+func (c *Config) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// 配置中包含密钥，仅所有者可读写
+	return os.WriteFile(path, data, 0600)
+}
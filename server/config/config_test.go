@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig 写入配置文件并返回路径
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "server-config.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEnvOverrides(t *testing.T) {
+	path := writeConfig(t, `{"port": 9000, "ingest": {"max_body_mb": 5, "max_decompressed_mb": 20}}`)
+
+	env := map[string]string{
+		"PORT":                         "9100",
+		"DB_TYPE":                      "memory",
+		"DB_MAX_RECORDS_PER_AGENT":     "500",
+		"TLS_ENABLED":                  "true",
+		"TLS_CERT_FILE":                "server.crt",
+		"TLS_KEY_FILE":                 "server.key",
+		"TLS_CLIENT_CA_FILE":           "ca.crt",
+		"TLS_REQUIRE_CLIENT_CERT":      "true",
+		"INGEST_MAX_BODY_MB":           "20",
+		"INGEST_MAX_DECOMPRESSED_MB":   "128",
+		"MONITOR_DEFAULT_INTERVAL":     "15",
+		"MONITOR_LATE_FACTOR":          "1.5",
+		"MONITOR_OFFLINE_FACTOR":       "4",
+		"MONITOR_CLOCK_SKEW_THRESHOLD": "120",
+		"NOTIFY_MAX_RETRIES":           "0",
+	}
+	for name, v := range env {
+		t.Setenv(envPrefix+name, v)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9100 || cfg.Database.Type != "memory" || cfg.Database.MaxRecordsPerAgent != 500 {
+		t.Errorf("port/database = %d/%+v", cfg.Port, cfg.Database)
+	}
+	if !cfg.TLS.Enabled || !cfg.TLS.RequireClientCert || cfg.TLS.ClientCAFile != "ca.crt" {
+		t.Errorf("tls = %+v", cfg.TLS)
+	}
+	if cfg.Ingest.MaxBodyMB != 20 || cfg.Ingest.MaxDecompressedMB != 128 {
+		t.Errorf("ingest = %+v", cfg.Ingest)
+	}
+	want := MonitorConfig{DefaultInterval: 15, LateFactor: 1.5, OfflineFactor: 4, ClockSkewThreshold: 120}
+	if cfg.Monitor != want {
+		t.Errorf("monitor = %+v, want %+v", cfg.Monitor, want)
+	}
+	if cfg.Notifications.MaxRetries != 0 {
+		t.Errorf("notifications.max_retries = %d, want 0", cfg.Notifications.MaxRetries)
+	}
+}
+
+func TestEnvOverrideErrors(t *testing.T) {
+	path := writeConfig(t, `{}`)
+	t.Setenv(envPrefix+"TLS_REQUIRE_CLIENT_CERT", "maybe")
+	t.Setenv(envPrefix+"MONITOR_LATE_FACTOR", "fast")
+	t.Setenv(envPrefix+"INGEST_MAX_BODY_MB", "ten")
+
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("Load succeeded with invalid environment overrides")
+	}
+	for _, name := range []string{"TLS_REQUIRE_CLIENT_CERT", "MONITOR_LATE_FACTOR", "INGEST_MAX_BODY_MB"} {
+		if !strings.Contains(err.Error(), envPrefix+name) {
+			t.Errorf("error does not mention %s%s: %v", envPrefix, name, err)
+		}
+	}
+}
+
+func TestValidateWebhookFormat(t *testing.T) {
+	for _, format := range []string{"", "generic", "slack", "dingtalk", "wecom", "teams"} {
+		cfg := DefaultConfig()
+		cfg.Notifications.Webhooks = []WebhookConfig{{Name: "ops", URL: "https://example.com/hook", Format: format}}
+		err := cfg.Validate()
+		if format == "teams" {
+			if err == nil || !strings.Contains(err.Error(), "notifications.webhooks[0].format") {
+				t.Errorf("format %q: error = %v, want format error", format, err)
+			}
+		} else if err != nil {
+			t.Errorf("format %q: %v", format, err)
+		}
+	}
+}
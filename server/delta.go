@@ -0,0 +1,223 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// 上报类型
+const (
+	reportFull  = "full"
+	reportDelta = "delta"
+)
+
+// deltaSections 增量字段与其还原后的全量字段
+var deltaSections = []struct {
+	delta string
+	full  string
+	key   func(map[string]interface{}) string
+}{
+	{"process_delta", "processes", processKey},
+	{"network_delta", "network", connectionKey},
+}
+
+// agentSnapshot 代理最近的进程与连接全量状态
+type agentSnapshot struct {
+	mu       sync.Mutex
+	seq      uint64
+	valid    bool
+	sections map[string]map[string]interface{} // 全量字段 -> 条目键 -> 条目
+}
+
+// snapshotStore 各代理的全量状态，用于还原增量上报
+type snapshotStore struct {
+	mu     sync.Mutex
+	agents map[string]*agentSnapshot
+}
+
+// newSnapshotStore 创建全量状态存储
+func newSnapshotStore() *snapshotStore {
+	return &snapshotStore{agents: make(map[string]*agentSnapshot)}
+}
+
+// get 获取代理状态，不存在时创建
+func (s *snapshotStore) get(agentID string) *agentSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap, ok := s.agents[agentID]
+	if !ok {
+		snap = &agentSnapshot{}
+		s.agents[agentID] = snap
+	}
+	return snap
+}
+
+// snapshotUpdate 待提交的快照状态，数据入库成功后才提交
+//
+// 持有代理快照的锁直到 commit 或 discard，同一代理的上报按顺序处理。
+type snapshotUpdate struct {
+	snap     *agentSnapshot
+	seq      uint64
+	valid    bool
+	sections map[string]map[string]interface{}
+}
+
+// commit 提交新的快照状态和序号
+func (u *snapshotUpdate) commit() {
+	if u == nil {
+		return
+	}
+	u.snap.seq = u.seq
+	u.snap.valid = u.valid
+	u.snap.sections = u.sections
+	u.snap.mu.Unlock()
+}
+
+// discard 放弃更新，保留原有状态，代理重发同一序号的上报时会重新应用
+func (u *snapshotUpdate) discard() {
+	if u == nil {
+		return
+	}
+	u.snap.mu.Unlock()
+}
+
+// apply 用全量快照更新状态，或将增量应用到状态并还原为全量数据
+//
+// 增量序号不连续或缺少基准快照时丢弃其中的增量部分，返回 resync=true 要求代理重新发送全量快照；
+// 代理未收到响应而重发的已应用增量返回 duplicate=true，调用方应忽略该上报。
+// 其余情况返回的更新必须在入库成功后 commit，失败时 discard，否则重发的上报会被误判为重复。
+func (s *snapshotStore) apply(data *AgentData) (update *snapshotUpdate, resync, duplicate bool) {
+	if data.ReportType != reportFull && data.ReportType != reportDelta {
+		return nil, false, false
+	}
+
+	snap := s.get(data.AgentID)
+	snap.mu.Lock()
+	update = &snapshotUpdate{snap: snap, seq: snap.seq, valid: snap.valid, sections: snap.sections}
+
+	if data.ReportType == reportFull {
+		update.sections = make(map[string]map[string]interface{})
+		for _, section := range deltaSections {
+			items := make(map[string]interface{})
+			for _, item := range listItems(data.Data[section.full]) {
+				items[section.key(item)] = item
+			}
+			update.sections[section.full] = items
+		}
+		update.seq = data.Seq
+		update.valid = true
+		return update, false, false
+	}
+
+	if snap.valid && data.Seq <= snap.seq {
+		snap.mu.Unlock()
+		return nil, false, true
+	}
+	if !snap.valid || data.Seq != snap.seq+1 {
+		logWarnf("Agent %s delta report %d does not follow %d, requesting resync", data.AgentID, data.Seq, snap.seq)
+		update.valid = false
+		for _, section := range deltaSections {
+			delete(data.Data, section.delta)
+		}
+		return update, true, false
+	}
+
+	// 在副本上应用增量，入库失败时原状态不受影响
+	update.sections = make(map[string]map[string]interface{}, len(snap.sections))
+	for name, items := range snap.sections {
+		update.sections[name] = items
+	}
+	for _, section := range deltaSections {
+		delta, ok := data.Data[section.delta].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		delete(data.Data, section.delta)
+
+		items := make(map[string]interface{}, len(update.sections[section.full]))
+		for k, v := range update.sections[section.full] {
+			items[k] = v
+		}
+		for _, field := range []string{"added", "changed"} {
+			for _, item := range listItems(delta[field]) {
+				items[section.key(item)] = item
+			}
+		}
+		if removed, ok := delta["removed"].([]interface{}); ok {
+			for _, key := range removed {
+				if k, ok := key.(string); ok {
+					delete(items, k)
+				}
+			}
+		}
+		update.sections[section.full] = items
+
+		data.Data[section.full] = sortedItems(items)
+	}
+
+	update.seq = data.Seq
+	return update, false, false
+}
+
+// listItems 取出 JSON 数组中的对象
+func listItems(value interface{}) []map[string]interface{} {
+	list, _ := value.([]interface{})
+	items := make([]map[string]interface{}, 0, len(list))
+	for _, v := range list {
+		if item, ok := v.(map[string]interface{}); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// sortedItems 输出全量列表，按 PID 排序，PID 相同时按键排序
+func sortedItems(items map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	pid := func(k string) float64 {
+		n, _ := items[k].(map[string]interface{})["pid"].(float64)
+		return n
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if pi, pj := pid(keys[i]), pid(keys[j]); pi != pj {
+			return pi < pj
+		}
+		return keys[i] < keys[j]
+	})
+
+	list := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, items[k])
+	}
+	return list
+}
+
+// jsonNumber 将 JSON 数字格式化为整数字符串
+func jsonNumber(v interface{}) string {
+	n, _ := v.(float64)
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// jsonString 取出 JSON 字符串
+func jsonString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// processKey 进程键，与代理端的 PID:启动时间 一致
+func processKey(item map[string]interface{}) string {
+	return jsonNumber(item["pid"]) + ":" + jsonNumber(item["start_time"])
+}
+
+// connectionKey 连接键，与代理端的 协议|本地地址|远程地址|inode 一致
+func connectionKey(item map[string]interface{}) string {
+	return jsonString(item["protocol"]) +
+		"|" + jsonString(item["local_addr"]) + ":" + jsonNumber(item["local_port"]) +
+		"|" + jsonString(item["remote_addr"]) + ":" + jsonNumber(item["remote_port"]) +
+		"|" + jsonNumber(item["inode"])
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"mini-hids/server/rules"
+)
+
+// maxRecentDetections 内存中保留的最近命中记录数量
+const maxRecentDetections = 1000
+
+// detectionLog 最近的规则命中记录
+type detectionLog struct {
+	mu      sync.Mutex
+	matches []rules.Match
+}
+
+// add 追加命中记录，超出上限时丢弃最旧的记录
+func (d *detectionLog) add(matches []rules.Match) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.matches = append(d.matches, matches...)
+	if over := len(d.matches) - maxRecentDetections; over > 0 {
+		d.matches = append([]rules.Match(nil), d.matches[over:]...)
+	}
+}
+
+// recent 返回最近的命中记录（新的在前），agentID 为空时不过滤
+func (d *detectionLog) recent(agentID string, limit int) []rules.Match {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := []rules.Match{}
+	for i := len(d.matches) - 1; i >= 0 && len(result) < limit; i-- {
+		if agentID == "" || d.matches[i].AgentID == agentID {
+			result = append(result, d.matches[i])
+		}
+	}
+	return result
+}
+
+// detect 对上报数据执行检测规则，命中记录合并为告警
+func (s *Server) detect(data AgentData) {
+	if s.rules == nil {
+		return
+	}
+
+	matches := s.rules.Evaluate(data)
+	if len(matches) == 0 {
+		return
+	}
+
+	s.detections.add(matches)
+
+	for _, m := range matches {
+		alert, created, err := s.alerts.Record(m)
+		if err != nil {
+			logErrorf("Failed to record alert for rule %s on agent %s: %v", m.RuleID, m.AgentID, err)
+			continue
+		}
+		if created {
+			logWarnf("New %s alert %s on agent %s: %s", alert.Severity, alert.ID, alert.AgentID, alert.RuleName)
+			s.notifyAlert(alert)
+		}
+	}
+}
+
+// reloadRules 重新加载检测规则
+func (s *Server) reloadRules() {
+	if s.rules == nil {
+		return
+	}
+	if err := s.rules.Reload(); err != nil {
+		logErrorf("Failed to reload rules, keeping previous rules: %v", err)
+		return
+	}
+	logInfof("Reloaded %d detection rules from %s", len(s.rules.Rules()), s.rules.Dir())
+}
+
+// handleGetRules 获取已加载的检测规则
+func (s *Server) handleGetRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	list := []*rules.Rule{}
+	if s.rules != nil {
+		list = s.rules.Rules()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"rules": list})
+}
+
+// handleGetDetections 获取最近的规则命中记录，支持 agent_id 与 limit 参数
+func (s *Server) handleGetDetections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxRecentDetections)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"detections": s.detections.recent(r.URL.Query().Get("agent_id"), limit),
+	})
+}
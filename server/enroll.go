@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"mini-hids/server/registry"
+)
+
+// enrollRequest 注册请求
+type enrollRequest struct {
+	Hostname string `json:"hostname"`
+}
+
+// enrollResponse 注册响应
+type enrollResponse struct {
+	AgentID     string `json:"agent_id"`
+	AgentSecret string `json:"agent_secret"`
+}
+
+// handleEnroll 处理代理注册，使用共享注册令牌认证
+func (s *Server) handleEnroll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	enrollmentToken := s.config.Security.EnrollmentToken
+	if enrollmentToken == "" {
+		http.Error(w, "Enrollment disabled", http.StatusForbidden)
+		return
+	}
+
+	token, _ := bearerToken(r)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(enrollmentToken)) != 1 {
+		logWarnf("Rejected enrollment from %s: invalid enrollment token", r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="mini-hids", error="invalid_token"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req enrollRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+		return
+	}
+	if err := validateAgentName("hostname", req.Hostname, maxHostnameLen); err != nil {
+		logWarnf("Rejected enrollment from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "Invalid hostname", http.StatusBadRequest)
+		return
+	}
+
+	agent, secret, err := s.registry.Enroll(req.Hostname)
+	if err != nil {
+		logErrorf("Failed to enroll agent %s: %v", req.Hostname, err)
+		http.Error(w, "Failed to enroll agent", http.StatusInternalServerError)
+		return
+	}
+
+	logInfof("Enrolled agent %s (%s) from %s", agent.ID, agent.Hostname, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollResponse{AgentID: agent.ID, AgentSecret: secret})
+}
+
+// handleListEnrollments 获取已注册代理列表
+func (s *Server) handleListEnrollments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"agents": s.registry.List()})
+}
+
+// handleRevokeEnrollment 吊销代理凭据
+func (s *Server) handleRevokeEnrollment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	agentID := strings.TrimPrefix(r.URL.Path, "/api/enrollments/")
+	if agentID == "" || strings.Contains(agentID, "/") {
+		http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.registry.Revoke(agentID); err != nil {
+		if errors.Is(err, registry.ErrUnknownAgent) {
+			http.Error(w, "Agent not found", http.StatusNotFound)
+			return
+		}
+		logErrorf("Failed to revoke agent %s: %v", agentID, err)
+		http.Error(w, "Failed to revoke agent", http.StatusInternalServerError)
+		return
+	}
+
+	logInfof("Revoked agent %s", agentID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "revoked", "agent_id": agentID})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxJSONBody 其他 JSON 接口的请求体上限
+const maxJSONBody = 1 << 20
+
+// 代理ID和主机名的长度上限
+const (
+	maxAgentIDLen  = 128
+	maxHostnameLen = 255
+)
+
+// validateAgentName 检查代理ID或主机名：非空、不超过长度上限、不含 / 和控制字符
+//
+// 代理ID出现在 /api/agents/{id}/... 路径中，也和主机名一起写入存储索引。
+func validateAgentName(field, value string, limit int) error {
+	if value == "" {
+		return fmt.Errorf("missing %s", field)
+	}
+	if len(value) > limit {
+		return fmt.Errorf("%s longer than %d bytes", field, limit)
+	}
+	if !utf8.ValidString(value) {
+		return fmt.Errorf("%s is not valid UTF-8", field)
+	}
+	for _, r := range value {
+		if r == '/' || unicode.IsControl(r) {
+			return fmt.Errorf("%s contains invalid character %q", field, r)
+		}
+	}
+	return nil
+}
+
+// bodyTooLargeError 请求体超出上限
+type bodyTooLargeError struct {
+	what  string // 超出的是压缩前还是解压后的大小
+	limit int64
+}
+
+func (e *bodyTooLargeError) Error() string {
+	return fmt.Sprintf("%s exceeds %d bytes limit", e.what, e.limit)
+}
+
+// errUnsupportedEncoding 不支持的 Content-Encoding
+var errUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+
+// limitReader 读取超过 n 字节时返回 bodyTooLargeError，而不是像 io.LimitReader 那样静默截断
+type limitReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, l.err
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, l.err
+	}
+	return n, err
+}
+
+// decodeAgentBody 解码上报请求体，按 Content-Encoding 解压，并分别限制解压前后的大小
+func (s *Server) decodeAgentBody(r *http.Request, v interface{}) error {
+	maxBody := int64(s.config.Ingest.MaxBodyMB) << 20
+	maxDecoded := int64(s.config.Ingest.MaxDecompressedMB) << 20
+
+	if r.ContentLength > maxBody {
+		return &bodyTooLargeError{what: "request body", limit: maxBody}
+	}
+	var body io.Reader = &limitReader{r: r.Body, n: maxBody, err: &bodyTooLargeError{what: "request body", limit: maxBody}}
+
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return wrapBodyError(err)
+		}
+		defer zr.Close()
+		body = &limitReader{r: zr, n: maxDecoded, err: &bodyTooLargeError{what: "decompressed body", limit: maxDecoded}}
+	default:
+		return fmt.Errorf("%w: %s", errUnsupportedEncoding, encoding)
+	}
+
+	return wrapBodyError(json.NewDecoder(body).Decode(v))
+}
+
+// wrapBodyError 保留超限错误，其余读取或解析错误视为格式错误
+func wrapBodyError(err error) error {
+	var tooLarge *bodyTooLargeError
+	if err == nil || errors.As(err, &tooLarge) {
+		return err
+	}
+	return fmt.Errorf("invalid body: %w", err)
+}
+
+// writeBodyError 将请求体错误转换为 HTTP 状态码
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *bodyTooLargeError
+	switch {
+	case errors.As(err, &tooLarge):
+		http.Error(w, "Request too large: "+tooLarge.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, errUnsupportedEncoding):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	default:
+		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"mini-hids/server/storage"
+)
+
+func TestReportAgentNameValidation(t *testing.T) {
+	store := storage.NewMemoryStore(0)
+	s := newTestServer(t, store)
+
+	tests := []struct {
+		name     string
+		agentID  string
+		hostname string
+		want     int
+	}{
+		{"valid", "web-01.example.com", "web-01", http.StatusOK},
+		{"unicode hostname", "agent-2", "服务器-2", http.StatusOK},
+		{"missing agent_id", "", "host", http.StatusBadRequest},
+		{"missing hostname", "agent-3", "", http.StatusBadRequest},
+		{"slash in agent_id", "a/../b", "host", http.StatusBadRequest},
+		{"newline in agent_id", "agent\nforged log line", "host", http.StatusBadRequest},
+		{"control character in hostname", "agent-4", "host\x00", http.StatusBadRequest},
+		{"agent_id too long", strings.Repeat("a", maxAgentIDLen+1), "host", http.StatusBadRequest},
+		{"65536-byte hostname", "agent-5", strings.Repeat("h", 1<<16), http.StatusBadRequest},
+	}
+
+	stored := 0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := testReport(tt.agentID)
+			data.Hostname = tt.hostname
+			if rec := postReport(t, s, data, nil); rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+		if tt.want == http.StatusOK {
+			stored++
+		}
+	}
+	if n := store.Count(); n != stored {
+		t.Errorf("stored %d reports, want %d", n, stored)
+	}
+}
+
+func TestValidateAgentNameRejectsInvalidUTF8(t *testing.T) {
+	// JSON 编码会替换非法 UTF-8，无法经由上报接口构造，直接校验
+	if err := validateAgentName("agent_id", "agent-\xff", maxAgentIDLen); err == nil {
+		t.Error("validateAgentName accepted invalid UTF-8")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mini-hids/server/monitor"
+	"mini-hids/server/notify"
+)
+
+// seedMonitor 用存储中已有的代理初始化存活监控
+func (s *Server) seedMonitor() {
+	for _, summary := range s.store.Agents() {
+		var interval time.Duration
+		if latest, err := s.store.Latest(summary.AgentID, 1); err == nil && len(latest) > 0 {
+			interval = time.Duration(latest[0].ReportInterval) * time.Second
+		}
+		s.monitor.Seed(summary.AgentID, summary.Hostname, summary.LastSeen, interval)
+	}
+}
+
+// getAgentStatus 获取代理状态（online/late/offline）
+func (s *Server) getAgentStatus(agentID string) (monitor.AgentState, bool) {
+	return s.monitor.State(agentID)
+}
+
+// handleAgentEvent 处理代理状态变化事件
+func (s *Server) handleAgentEvent(ev monitor.Event) {
+	switch ev.Type {
+	case monitor.EventOnline:
+		logInfof("Agent %s (%s) reported for the first time", ev.AgentID, ev.Hostname)
+	case monitor.EventRecovered:
+		logInfof("Agent %s (%s) recovered from %s after %s", ev.AgentID, ev.Hostname, ev.From, ev.Silence)
+	case monitor.EventLate:
+		logWarnf("Agent %s (%s) is late, silent for %s", ev.AgentID, ev.Hostname, ev.Silence)
+	case monitor.EventOffline:
+		logWarnf("Agent %s (%s) is offline, silent for %s", ev.AgentID, ev.Hostname, ev.Silence)
+		s.notifier.Notify(notify.Event{
+			Type:      notify.EventAgentOffline,
+			Title:     fmt.Sprintf("Agent offline: %s", ev.Hostname),
+			Text:      fmt.Sprintf("Agent: %s (%s)\nLast seen: %s\nSilent for: %s", ev.Hostname, ev.AgentID, ev.LastSeen.Format(time.RFC3339), ev.Silence),
+			AgentID:   ev.AgentID,
+			Hostname:  ev.Hostname,
+			Data:      ev,
+			Timestamp: ev.Time,
+		})
+	}
+}
+
+// handleAgentEvents 查询代理状态变化历史，支持 agent_id、type、since、limit 参数
+func (s *Server) handleAgentEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	since, err := parseTimeParam(query.Get("since"))
+	if err != nil {
+		http.Error(w, "Invalid since parameter", http.StatusBadRequest)
+		return
+	}
+
+	limit := 100
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events": s.monitor.Events(query.Get("agent_id"), query.Get("type"), since, limit),
+	})
+}
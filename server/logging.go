@@ -0,0 +1,57 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// 日志级别
+const (
+	levelDebug = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+// logLevel 当前日志级别
+var logLevel = levelInfo
+
+// setLogLevel 设置日志级别
+func setLogLevel(level string) {
+	switch strings.ToLower(level) {
+	case "debug":
+		logLevel = levelDebug
+	case "warn":
+		logLevel = levelWarn
+	case "error":
+		logLevel = levelError
+	default:
+		logLevel = levelInfo
+	}
+}
+
+// logDebugf 输出调试日志
+func logDebugf(format string, v ...interface{}) {
+	if logLevel <= levelDebug {
+		log.Printf("[DEBUG] "+format, v...)
+	}
+}
+
+// logInfof 输出普通日志
+func logInfof(format string, v ...interface{}) {
+	if logLevel <= levelInfo {
+		log.Printf(format, v...)
+	}
+}
+
+// logWarnf 输出警告日志
+func logWarnf(format string, v ...interface{}) {
+	if logLevel <= levelWarn {
+		log.Printf("[WARN] "+format, v...)
+	}
+}
+
+// logErrorf 输出错误日志
+func logErrorf(format string, v ...interface{}) {
+	log.Printf("[ERROR] "+format, v...)
+}
@@ -2,41 +2,64 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
+
+	"mini-hids/server/alerts"
+	"mini-hids/server/config"
+	"mini-hids/server/monitor"
+	"mini-hids/server/notify"
+	"mini-hids/server/registry"
+	"mini-hids/server/rules"
+	"mini-hids/server/storage"
 )
 
 // AgentData 代理数据结构
-type AgentData struct {
-	AgentID   string                 `json:"agent_id"`
-	Hostname  string                 `json:"hostname"`
-	Timestamp time.Time              `json:"timestamp"`
-	Data      map[string]interface{} `json:"data"`
-}
+type AgentData = storage.AgentData
 
 // Server 服务器结构
 type Server struct {
-	port      int
-	mux       *http.ServeMux
-	dataStore map[string][]AgentData // 简单的内存存储
+	config   *config.Config
+	mux      *http.ServeMux
+	store    storage.Store      // 代理数据存储
+	registry *registry.Registry // 代理注册表
+	rules    *rules.Engine      // 检测规则引擎
+	alerts   *alerts.Store      // 告警存储
+	notifier *notify.Notifier   // Webhook 通知器
+	monitor  *monitor.Monitor   // 代理存活监控
+
+	snapshots  *snapshotStore // 各代理的全量状态，用于还原增量上报
+	skews      *skewTracker   // 各代理的时钟偏差
+	detections detectionLog   // 最近的规则命中记录
 }
 
 // NewServer 创建新的服务器
-func NewServer(port int) *Server {
+func NewServer(cfg *config.Config, store storage.Store, reg *registry.Registry, engine *rules.Engine, alertStore *alerts.Store, notifier *notify.Notifier) *Server {
 	mux := http.NewServeMux()
-	
+
 	server := &Server{
-		port:      port,
-		mux:       mux,
-		dataStore: make(map[string][]AgentData),
+		config:   cfg,
+		mux:      mux,
+		store:    store,
+		registry: reg,
+		rules:    engine,
+		alerts:   alertStore,
+		notifier: notifier,
+
+		snapshots: newSnapshotStore(),
+		skews:     newSkewTracker(time.Duration(cfg.Monitor.ClockSkewThreshold) * time.Second),
 	}
-	
+
 	server.setupRoutes()
 	return server
 }
@@ -44,28 +67,45 @@ func NewServer(port int) *Server {
 // setupRoutes 设置路由
 func (s *Server) setupRoutes() {
 	// API 路由
-	s.mux.HandleFunc("/api/agent/data", s.corsMiddleware(s.handleAgentData))
-	s.mux.HandleFunc("/api/agents", s.corsMiddleware(s.handleGetAgents))
-	s.mux.HandleFunc("/api/agents/", s.corsMiddleware(s.handleGetAgentData))
-	s.mux.HandleFunc("/api/stats", s.corsMiddleware(s.handleGetStats))
-	s.mux.HandleFunc("/api/health", s.corsMiddleware(s.handleHealth))
-	
-	// 静态文件服务
-	s.mux.HandleFunc("/", s.handleIndex)
+	s.mux.HandleFunc("/api/agent/enroll", s.corsMiddleware(s.handleEnroll))
+	s.mux.HandleFunc("/api/agent/data", s.corsMiddleware(s.authMiddleware(scopeIngest, s.handleAgentData)))
+	s.mux.HandleFunc("/api/agents", s.corsMiddleware(s.authMiddleware(scopeRead, s.handleGetAgents)))
+	s.mux.HandleFunc("/api/agents/", s.corsMiddleware(s.authMiddleware(scopeRead, s.handleGetAgentData)))
+	s.mux.HandleFunc("/api/agent-events", s.corsMiddleware(s.authMiddleware(scopeRead, s.handleAgentEvents)))
+	s.mux.HandleFunc("/api/stats", s.corsMiddleware(s.authMiddleware(scopeRead, s.handleGetStats)))
+	s.mux.HandleFunc("/api/enrollments", s.corsMiddleware(s.authMiddleware(scopeRead, s.handleListEnrollments)))
+	s.mux.HandleFunc("/api/enrollments/", s.corsMiddleware(s.authMiddleware(scopeAdmin, s.handleRevokeEnrollment)))
+	s.mux.HandleFunc("/api/rules", s.corsMiddleware(s.authMiddleware(scopeRead, s.handleGetRules)))
+	s.mux.HandleFunc("/api/detections", s.corsMiddleware(s.authMiddleware(scopeRead, s.handleGetDetections)))
+	s.mux.HandleFunc("/api/alerts", s.corsMiddleware(s.authMiddleware(scopeRead, s.handleListAlerts)))
+	s.mux.HandleFunc("/api/alerts/", s.corsMiddleware(s.authMiddleware(scopeRead, s.handleAlert)))
+	s.mux.HandleFunc("/api/webhooks", s.corsMiddleware(s.authMiddleware(scopeAdmin, s.handleListWebhooks)))
+	s.mux.HandleFunc("/api/webhooks/deliveries", s.corsMiddleware(s.authMiddleware(scopeAdmin, s.handleListDeliveries)))
+	s.mux.HandleFunc("/api/health", s.corsMiddleware(s.authMiddleware(scopeRead, s.handleHealth)))
+
+	// 静态文件服务，web_dir 不可用时使用内置页面
+	webDir := s.config.WebDir
+	if _, err := os.Stat(filepath.Join(webDir, "index.html")); webDir != "" && err == nil {
+		logInfof("Serving dashboard from %s", webDir)
+		s.mux.Handle("/", http.FileServer(http.Dir(webDir)))
+	} else {
+		logWarnf("Web directory %q has no index.html, using built-in dashboard", webDir)
+		s.mux.HandleFunc("/", s.handleIndex)
+	}
 }
 
 // corsMiddleware CORS 中间件
 func (s *Server) corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Agent-ID")
+
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
-		
+
 		next(w, r)
 	}
 }
@@ -76,24 +116,84 @@ func (s *Server) handleAgentData(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	
+
 	var agentData AgentData
-	
-	if err := json.NewDecoder(r.Body).Decode(&agentData); err != nil {
-		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+
+	// 请求体可能经过 gzip 压缩，解压前后均限制大小，防止单个请求耗尽内存
+	if err := s.decodeAgentBody(r, &agentData); err != nil {
+		logWarnf("Rejected agent data from %s: %v", r.RemoteAddr, err)
+		writeBodyError(w, err)
+		return
+	}
+
+	// 已注册代理以凭据中的ID为准，防止冒充其他代理
+	if agentID, ok := authenticatedAgent(r); ok {
+		if agentData.AgentID != "" && agentData.AgentID != agentID {
+			logWarnf("Agent %s reported mismatched agent_id %q", agentID, agentData.AgentID)
+		}
+		agentData.AgentID = agentID
+	} else if agent, enrolled := s.registry.Get(agentData.AgentID); enrolled {
+		// 已注册或用过证书的代理（包括已吊销的）必须使用自己的凭据或证书，共享密钥或未认证的请求不能以其名义上报
+		logWarnf("Rejected report from %s claiming enrolled agent %s without its credentials", r.RemoteAddr, agentData.AgentID)
+		if agent.Revoked {
+			http.Error(w, "Agent revoked", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Enrolled agent must use its own credentials", http.StatusForbidden)
 		return
 	}
-	
-	// 设置时间戳
-	agentData.Timestamp = time.Now()
-	
-	// 存储数据
-	s.storeAgentData(agentData)
-	
-	log.Printf("Received data from agent %s (%s)", agentData.AgentID, agentData.Hostname)
-	
+	if err := validateAgentName("agent_id", agentData.AgentID, maxAgentIDLen); err != nil {
+		logWarnf("Rejected agent data from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "Invalid agent_id", http.StatusBadRequest)
+		return
+	}
+	if err := validateAgentName("hostname", agentData.Hostname, maxHostnameLen); err != nil {
+		logWarnf("Rejected agent data from %s for agent %s: %v", r.RemoteAddr, agentData.AgentID, err)
+		http.Error(w, "Invalid hostname", http.StatusBadRequest)
+		return
+	}
+
+	// 以代理采集时间为准，离线期间缓存后补发的上报按原时间入库
+	receivedAt := time.Now()
+	s.stampTimes(r, &agentData, receivedAt)
+
+	// 将增量上报还原为全量数据，序号不连续时要求代理重新同步
+	update, resync, duplicate := s.snapshots.apply(&agentData)
+	if duplicate {
+		// 代理未收到上次响应而重发，数据已入库
+		logDebugf("Ignored duplicate report %d from agent %s", agentData.Seq, agentData.AgentID)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "resync": false})
+		return
+	}
+
+	// 存储数据，入库失败时不提交快照，代理重发时按原序号重新应用
+	if err := s.store.Append(agentData); err != nil {
+		update.discard()
+		if errors.Is(err, storage.ErrBusy) {
+			// 写入队列已满，让代理稍后重试
+			logWarnf("Store busy, rejected data from agent %s", agentData.AgentID)
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "Server busy", http.StatusServiceUnavailable)
+			return
+		}
+		logErrorf("Failed to store data from agent %s: %v", agentData.AgentID, err)
+		http.Error(w, "Failed to store data", http.StatusInternalServerError)
+		return
+	}
+	update.commit()
+
+	logDebugf("Received data from agent %s (%s)", agentData.AgentID, agentData.Hostname)
+
+	// 更新代理存活状态，补发的历史上报同样说明代理此刻在线
+	s.monitor.Observe(agentData.AgentID, agentData.Hostname, receivedAt,
+		time.Duration(agentData.ReportInterval)*time.Second)
+
+	// 执行检测规则
+	s.detect(agentData)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "resync": resync})
 }
 
 // handleGetAgents 获取代理列表
@@ -102,23 +202,28 @@ func (s *Server) handleGetAgents(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	
+
 	agents := make([]map[string]interface{}, 0)
-	
-	for agentID, dataList := range s.dataStore {
-		if len(dataList) > 0 {
-			lastData := dataList[len(dataList)-1]
-			agent := map[string]interface{}{
-				"agent_id":   agentID,
-				"hostname":   lastData.Hostname,
-				"last_seen":  lastData.Timestamp,
-				"data_count": len(dataList),
-				"status":     s.getAgentStatus(lastData.Timestamp),
-			}
-			agents = append(agents, agent)
+
+	for _, summary := range s.store.Agents() {
+		agent := map[string]interface{}{
+			"agent_id":   summary.AgentID,
+			"hostname":   summary.Hostname,
+			"last_seen":  summary.LastSeen,
+			"data_count": summary.Count,
+		}
+		if st, ok := s.getAgentStatus(summary.AgentID); ok {
+			agent["status"] = st.State
+			agent["status_since"] = st.Since
+			agent["expected_interval"] = int(st.Interval / time.Second)
 		}
+		if cs, ok := s.skews.get(summary.AgentID); ok {
+			agent["clock_skew"] = math.Round(cs.Skew.Seconds()*1000) / 1000
+			agent["clock_skewed"] = cs.Skewed
+		}
+		agents = append(agents, agent)
 	}
-	
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"agents": agents})
 }
@@ -129,54 +234,100 @@ func (s *Server) handleGetAgentData(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	
+
 	// 从 URL 路径中提取 agent ID
 	path := strings.TrimPrefix(r.URL.Path, "/api/agents/")
 	parts := strings.Split(path, "/")
-	if len(parts) < 2 || parts[1] != "data" {
+	if len(parts) < 2 || (parts[1] != "data" && parts[1] != "process-tree" && parts[1] != "sessions") {
 		http.Error(w, "Invalid URL format", http.StatusBadRequest)
 		return
 	}
-	
+
 	agentID := parts[0]
-	
-	dataList, exists := s.dataStore[agentID]
+
+	summary, exists := s.findAgent(agentID)
 	if !exists {
 		http.Error(w, "Agent not found", http.StatusNotFound)
 		return
 	}
-	
-	// 获取最近的数据（最多 100 条）
-	start := 0
-	if len(dataList) > 100 {
-		start = len(dataList) - 100
+
+	if parts[1] == "process-tree" {
+		s.handleProcessTree(w, r, agentID)
+		return
+	}
+	if parts[1] == "sessions" {
+		s.handleSessions(w, r, agentID)
+		return
+	}
+
+	// 解析时间范围（RFC3339），未指定时返回最近的数据（最多 100 条）
+	query := r.URL.Query()
+	from, err := parseTimeParam(query.Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from parameter", http.StatusBadRequest)
+		return
 	}
-	
-	recentData := dataList[start:]
-	
+	to, err := parseTimeParam(query.Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid to parameter", http.StatusBadRequest)
+		return
+	}
+
+	var dataList []AgentData
+	if from.IsZero() && to.IsZero() {
+		dataList, err = s.store.Latest(agentID, 100)
+	} else {
+		dataList, err = s.store.Range(agentID, from, to)
+	}
+	if err != nil {
+		logErrorf("Failed to read data for agent %s: %v", agentID, err)
+		http.Error(w, "Failed to read data", http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"agent_id": agentID,
-		"data":     recentData,
-		"total":    len(dataList),
+		"data":     dataList,
+		"total":    summary.Count,
 	})
 }
 
+// findAgent 查找代理概要信息
+func (s *Server) findAgent(agentID string) (storage.AgentSummary, bool) {
+	for _, summary := range s.store.Agents() {
+		if summary.AgentID == agentID {
+			return summary, true
+		}
+	}
+	return storage.AgentSummary{}, false
+}
+
+// parseTimeParam 解析 RFC3339 时间参数，空字符串返回零值
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
 // handleGetStats 获取系统统计信息
 func (s *Server) handleGetStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	
+
 	stats := map[string]interface{}{
-		"total_agents":  len(s.dataStore),
+		"total_agents":  len(s.store.Agents()),
 		"active_agents": s.getActiveAgentCount(),
 		"total_records": s.getTotalRecordCount(),
+		"agent_states":  s.monitor.Counts(),
+		"alerts":        s.alerts.Counts(),
 		"server_uptime": time.Since(startTime).String(),
 		"last_updated":  time.Now(),
 	}
-	
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
@@ -187,7 +338,7 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":    "healthy",
@@ -202,7 +353,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	
+
 	html := `<!DOCTYPE html>
 <html lang="zh-CN">
 <head>
@@ -221,7 +372,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
         .agent-item { padding: 10px; border-bottom: 1px solid #eee; display: flex; justify-content: space-between; align-items: center; }
         .status { padding: 4px 8px; border-radius: 4px; color: white; font-size: 0.8em; }
         .status.online { background-color: #4CAF50; }
-        .status.warning { background-color: #FF9800; }
+        .status.late { background-color: #FF9800; }
         .status.offline { background-color: #F44336; }
         .refresh-btn { background: #2196F3; color: white; border: none; padding: 10px 20px; border-radius: 4px; cursor: pointer; }
         .refresh-btn:hover { background: #1976D2; }
@@ -248,9 +399,28 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
     </div>
 
     <script>
+        // 服务端启用认证时使用 dashboard_api_key 访问接口
+        async function apiFetch(url) {
+            const headers = {};
+            const apiKey = localStorage.getItem('mini-hids-api-key');
+            if (apiKey) {
+                headers['Authorization'] = 'Bearer ' + apiKey;
+            }
+
+            const response = await fetch(url, { headers });
+            if (response.status === 401) {
+                const key = prompt('请输入 API 密钥');
+                if (key) {
+                    localStorage.setItem('mini-hids-api-key', key);
+                    return apiFetch(url);
+                }
+            }
+            return response;
+        }
+
         async function loadStats() {
             try {
-                const response = await fetch('/api/stats');
+                const response = await apiFetch('/api/stats');
                 const stats = await response.json();
                 
                 document.getElementById('stats').innerHTML = ` + "`" + `
@@ -278,7 +448,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
         
         async function loadAgents() {
             try {
-                const response = await fetch('/api/agents');
+                const response = await apiFetch('/api/agents');
                 const data = await response.json();
                 
                 const agentsList = document.getElementById('agents-list');
@@ -289,6 +459,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
                                 <strong>${agent.hostname}</strong> (${agent.agent_id})
                                 <br>
                                 <small>最后上报: ${new Date(agent.last_seen).toLocaleString()}</small>
+                                ${agent.clock_skewed ? '<br><small style="color: #e67e22;">时钟偏差: ' + agent.clock_skew + 's</small>' : ''}
                             </div>
                             <div>
                                 <span class="status ${agent.status}">${agent.status}</span>
@@ -318,101 +489,199 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
     </script>
 </body>
 </html>`
-	
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.Write([]byte(html))
 }
 
-// storeAgentData 存储代理数据
-func (s *Server) storeAgentData(data AgentData) {
-	// 简单的内存存储，实际项目中应该使用数据库
-	if s.dataStore[data.AgentID] == nil {
-		s.dataStore[data.AgentID] = make([]AgentData, 0)
-	}
-	
-	s.dataStore[data.AgentID] = append(s.dataStore[data.AgentID], data)
-	
-	// 保持最近的 1000 条记录
-	if len(s.dataStore[data.AgentID]) > 1000 {
-		s.dataStore[data.AgentID] = s.dataStore[data.AgentID][len(s.dataStore[data.AgentID])-1000:]
-	}
-}
-
-// getAgentStatus 获取代理状态
-func (s *Server) getAgentStatus(lastSeen time.Time) string {
-	timeSince := time.Since(lastSeen)
-	
-	if timeSince < 30*time.Second {
-		return "online"
-	} else if timeSince < 5*time.Minute {
-		return "warning"
-	} else {
-		return "offline"
-	}
-}
-
 // getActiveAgentCount 获取活跃代理数量
 func (s *Server) getActiveAgentCount() int {
-	count := 0
-	threshold := time.Now().Add(-5 * time.Minute)
-	
-	for _, dataList := range s.dataStore {
-		if len(dataList) > 0 {
-			lastData := dataList[len(dataList)-1]
-			if lastData.Timestamp.After(threshold) {
-				count++
-			}
-		}
-	}
-	
-	return count
+	return s.monitor.Counts()[monitor.StateOnline]
 }
 
 // getTotalRecordCount 获取总记录数
 func (s *Server) getTotalRecordCount() int {
-	total := 0
-	for _, dataList := range s.dataStore {
-		total += len(dataList)
-	}
-	return total
+	return s.store.Count()
 }
 
 // Start 启动服务器
 func (s *Server) Start() error {
-	addr := fmt.Sprintf("0.0.0.0:%d", s.port)
-	log.Printf("Starting server on %s", addr)
-	return http.ListenAndServe(addr, s.mux)
+	addr := fmt.Sprintf("0.0.0.0:%d", s.config.Port)
+
+	if !s.config.TLS.Enabled {
+		logInfof("Starting server on %s", addr)
+		return http.ListenAndServe(addr, s.mux)
+	}
+
+	tlsConfig, err := buildTLSConfig(s.config.TLS)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:      addr,
+		Handler:   s.mux,
+		TLSConfig: tlsConfig,
+	}
+
+	logInfof("Starting server on %s (TLS)", addr)
+	return server.ListenAndServeTLS(s.config.TLS.CertFile, s.config.TLS.KeyFile)
 }
 
 var startTime time.Time
 
+// openStore 根据配置打开数据存储
+func openStore(cfg config.DatabaseConfig) (storage.Store, error) {
+	switch cfg.Type {
+	case "memory":
+		return storage.NewMemoryStore(cfg.MaxRecordsPerAgent), nil
+	default:
+		return storage.OpenDiskStore(cfg.Path, storage.DiskOptions{
+			Retention:          time.Duration(cfg.RetentionDays) * 24 * time.Hour,
+			MaxRecordsPerAgent: cfg.MaxRecordsPerAgent,
+		})
+	}
+}
+
 func main() {
+	configPath := flag.String("config", "server-config.json", "path to server config file")
+	flag.Parse()
+
 	startTime = time.Now()
-	
+
+	// 加载配置
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	setLogLevel(cfg.LogLevel)
+
+	// 打开数据存储
+	store, err := openStore(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to open data store: %v", err)
+	}
+	logInfof("Using %s storage", cfg.Database.Type)
+
+	// 打开代理注册表
+	reg, err := registry.Open(cfg.Security.RegistryPath)
+	if err != nil {
+		log.Fatalf("Failed to open agent registry: %v", err)
+	}
+
+	// 加载检测规则
+	if _, err := os.Stat(cfg.RulesDir); err != nil {
+		logWarnf("Rules directory %s not available, no detection rules loaded", cfg.RulesDir)
+	}
+	engine, err := rules.NewEngine(cfg.RulesDir)
+	if err != nil {
+		log.Fatalf("Failed to load detection rules: %v", err)
+	}
+	logInfof("Loaded %d detection rules from %s", len(engine.Rules()), cfg.RulesDir)
+
+	// 打开告警存储，磁盘存储时与数据保存在同一目录
+	alertsPath := ""
+	if cfg.Database.Type == "disk" {
+		alertsPath = filepath.Join(cfg.Database.Path, "alerts.json")
+	}
+	alertStore, err := alerts.Open(alertsPath)
+	if err != nil {
+		log.Fatalf("Failed to open alert store: %v", err)
+	}
+
+	// 创建 Webhook 通知器
+	notifier := newNotifier(cfg.Notifications)
+	if n := len(cfg.Notifications.Webhooks); n > 0 {
+		logInfof("Configured %d webhook targets", n)
+	}
+
 	// 创建服务器
-	server := NewServer(8848)
-	
-	// 设置信号处理
+	server := NewServer(cfg, store, reg, engine, alertStore, notifier)
+
+	// 创建代理存活监控，磁盘存储时事件历史与数据保存在同一目录
+	historyPath := ""
+	if cfg.Database.Type == "disk" {
+		historyPath = filepath.Join(cfg.Database.Path, "agent-events.jsonl")
+	}
+	server.monitor, err = monitor.New(monitor.Options{
+		DefaultInterval: time.Duration(cfg.Monitor.DefaultInterval) * time.Second,
+		LateFactor:      cfg.Monitor.LateFactor,
+		OfflineFactor:   cfg.Monitor.OfflineFactor,
+		HistoryPath:     historyPath,
+		OnEvent:         server.handleAgentEvent,
+	})
+	if err != nil {
+		log.Fatalf("Failed to open agent monitor: %v", err)
+	}
+	server.seedMonitor()
+	server.seedClockSkew()
+
+	// 设置信号处理，SIGHUP 重新加载检测规则
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	// 监控代理状态
+	stopCh := make(chan struct{})
+	go server.monitor.Run(stopCh)
+
 	// 在 goroutine 中启动服务器
 	go func() {
 		if err := server.Start(); err != nil {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
-	
-	log.Println("Mini-HIDS Server started successfully")
-	log.Println("Dashboard: http://localhost:8848")
-	log.Println("API endpoints:")
-	log.Println("  POST /api/agent/data     - Receive agent data")
-	log.Println("  GET  /api/agents         - Get agent list")
-	log.Println("  GET  /api/agents/:id/data - Get agent data")
-	log.Println("  GET  /api/stats          - Get system stats")
-	log.Println("  GET  /api/health         - Health check")
-	
+
+	logInfof("Mini-HIDS Server started successfully")
+	scheme := "http"
+	if cfg.TLS.Enabled {
+		scheme = "https"
+	}
+	logInfof("Dashboard: %s://localhost:%d", scheme, cfg.Port)
+	if cfg.Security.EnableAuth {
+		logInfof("API authentication enabled")
+	}
+	if cfg.Security.EnrollmentToken != "" {
+		logInfof("Agent enrollment enabled")
+	}
+	logInfof("API endpoints:")
+	logInfof("  POST /api/agent/enroll   - Enroll agent")
+	logInfof("  POST /api/agent/data     - Receive agent data")
+	logInfof("  GET  /api/agents         - Get agent list")
+	logInfof("  GET  /api/agents/:id/data - Get agent data (?from=&to= RFC3339)")
+	logInfof("  GET  /api/agents/:id/process-tree - Process tree (?pid= for ancestry and children)")
+	logInfof("  GET  /api/agents/:id/sessions - Active sessions and login records (?from=&to=&type=&user=&limit=)")
+	logInfof("  GET  /api/enrollments    - List enrolled agents")
+	logInfof("  DELETE /api/enrollments/:id - Revoke agent")
+	logInfof("  GET  /api/rules          - List detection rules")
+	logInfof("  GET  /api/detections     - Recent rule matches")
+	logInfof("  GET  /api/alerts         - List alerts (?status=&severity=&agent_id=&limit=&offset=)")
+	logInfof("  GET  /api/alerts/:id     - Get alert")
+	logInfof("  PATCH /api/alerts/:id    - Update alert status/assignee")
+	logInfof("  GET  /api/webhooks       - List webhook targets")
+	logInfof("  GET  /api/webhooks/deliveries - Webhook delivery log")
+	logInfof("  GET  /api/agent-events   - Agent online/late/offline/recovered history")
+	logInfof("  GET  /api/stats          - Get system stats")
+	logInfof("  GET  /api/health         - Health check")
+
 	// 等待信号
-	<-sigChan
-	log.Println("Shutting down server...")
-}
\ No newline at end of file
+	for sig := range sigChan {
+		if sig == syscall.SIGHUP {
+			server.reloadRules()
+			continue
+		}
+		break
+	}
+	logInfof("Shutting down server...")
+	close(stopCh)
+	notifier.Close(5 * time.Second)
+
+	if err := server.monitor.Close(); err != nil {
+		logErrorf("Failed to close agent event history: %v", err)
+	}
+	if err := alertStore.Close(); err != nil {
+		logErrorf("Failed to save alerts: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		logErrorf("Failed to close data store: %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"mini-hids/server/alerts"
+	"mini-hids/server/config"
+	"mini-hids/server/monitor"
+	"mini-hids/server/notify"
+	"mini-hids/server/registry"
+	"mini-hids/server/storage"
+)
+
+// newTestServer 创建使用内存注册表、内存告警且不加载规则的服务器
+func newTestServer(t *testing.T, store storage.Store) *Server {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.WebDir = ""
+	reg, err := registry.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	alertStore, err := alerts.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(cfg, store, reg, nil, alertStore, notify.New(nil, 0))
+	if s.monitor, err = monitor.New(monitor.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return s
+}
+
+// postReport 向上报接口发送一次上报，header 为附加的请求头
+func postReport(t *testing.T, s *Server, data AgentData, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	return postReportTLS(t, s, data, header, nil)
+}
+
+// postReportTLS 发送上报，state 不为空时模拟 TLS 连接（例如已验证的客户端证书）
+func postReportTLS(t *testing.T, s *Server, data AgentData, header http.Header, state *tls.ConnectionState) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/api/agent/data", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	req.TLS = state
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// flakyStore 在 fail 为 true 时拒绝写入的存储
+type flakyStore struct {
+	storage.Store
+
+	mu   sync.Mutex
+	fail bool
+}
+
+func (f *flakyStore) setFail(fail bool) {
+	f.mu.Lock()
+	f.fail = fail
+	f.mu.Unlock()
+}
+
+func (f *flakyStore) Append(data AgentData) error {
+	f.mu.Lock()
+	fail := f.fail
+	f.mu.Unlock()
+	if fail {
+		return storage.ErrBusy
+	}
+	return f.Store.Append(data)
+}
+
+func TestDeltaReportRetriedAfterStoreFailure(t *testing.T) {
+	store := &flakyStore{Store: storage.NewMemoryStore(0)}
+	s := newTestServer(t, store)
+
+	proc := func(pid int) map[string]interface{} {
+		return map[string]interface{}{"pid": pid, "start_time": 100, "name": "p"}
+	}
+	report := func(typ string, seq uint64, data map[string]interface{}) AgentData {
+		return AgentData{
+			AgentID:    "agent-1",
+			Hostname:   "host",
+			Timestamp:  time.Now().Add(-time.Minute).Add(time.Duration(seq) * time.Second),
+			ReportType: typ,
+			Seq:        seq,
+			Data:       data,
+		}
+	}
+
+	full := report(reportFull, 1, map[string]interface{}{
+		"processes": []interface{}{proc(1)},
+	})
+	if rec := postReport(t, s, full, nil); rec.Code != http.StatusOK {
+		t.Fatalf("full report: status %d", rec.Code)
+	}
+
+	delta := report(reportDelta, 2, map[string]interface{}{
+		"process_delta":  map[string]interface{}{"added": []interface{}{proc(2)}},
+		"process_events": []interface{}{map[string]interface{}{"type": "exec", "pid": 2}},
+	})
+	store.setFail(true)
+	if rec := postReport(t, s, delta, nil); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("delta with busy store: status %d, want 503", rec.Code)
+	}
+
+	// 代理缓存后按原序号重发，应当入库而不是被当作重复上报
+	store.setFail(false)
+	rec := postReport(t, s, delta, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("resent delta: status %d", rec.Code)
+	}
+	var resp struct {
+		Resync bool `json:"resync"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Resync {
+		t.Error("resent delta requested resync")
+	}
+
+	latest, err := store.Latest("agent-1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 2 {
+		t.Fatalf("stored %d reports, want 2", len(latest))
+	}
+	got := latest[1]
+	if got.Seq != 2 {
+		t.Fatalf("latest seq = %d, want 2", got.Seq)
+	}
+	if procs, _ := got.Data["processes"].([]interface{}); len(procs) != 2 {
+		t.Errorf("restored %d processes, want 2", len(procs))
+	}
+	if events, _ := got.Data["process_events"].([]interface{}); len(events) != 1 {
+		t.Errorf("stored %d process events, want 1", len(events))
+	}
+
+	// 已入库的上报再次重发才是重复
+	before := store.Count()
+	if rec := postReport(t, s, delta, nil); rec.Code != http.StatusOK {
+		t.Fatalf("duplicate delta: status %d", rec.Code)
+	}
+	if store.Count() != before {
+		t.Error("duplicate delta was stored again")
+	}
+}
+
+func TestConcurrentAgentReports(t *testing.T) {
+	const (
+		agents  = 300
+		reports = 10
+	)
+
+	stores := map[string]func(t *testing.T) storage.Store{
+		"memory": func(t *testing.T) storage.Store { return storage.NewMemoryStore(0) },
+		"disk": func(t *testing.T) storage.Store {
+			store, err := storage.OpenDiskStore(t.TempDir(), storage.DiskOptions{SegmentSize: 256 << 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return store
+		},
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := open(t)
+			s := newTestServer(t, store)
+			ts := httptest.NewServer(s.mux)
+			defer ts.Close()
+			client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: agents}}
+			defer client.CloseIdleConnections()
+
+			base := time.Now().Add(-time.Hour)
+			done := make(chan struct{})
+
+			// 查询接口与上报并发执行
+			var readers sync.WaitGroup
+			for r := 0; r < 4; r++ {
+				readers.Add(1)
+				go func(r int) {
+					defer readers.Done()
+					for i := 0; ; i++ {
+						select {
+						case <-done:
+							return
+						default:
+						}
+						path := "/api/agents"
+						if i%2 == 1 {
+							path = fmt.Sprintf("/api/agents/agent-%03d/data", (r*71+i)%agents)
+						}
+						resp, err := client.Get(ts.URL + path)
+						if err != nil {
+							t.Errorf("GET %s: %v", path, err)
+							return
+						}
+						io.Copy(io.Discard, resp.Body)
+						resp.Body.Close()
+					}
+				}(r)
+			}
+
+			var writers sync.WaitGroup
+			for a := 0; a < agents; a++ {
+				writers.Add(1)
+				go func(a int) {
+					defer writers.Done()
+					agentID := fmt.Sprintf("agent-%03d", a)
+					for n := 1; n <= reports; n++ {
+						// 首次上报全量快照，之后发送增量
+						data := AgentData{
+							AgentID:    agentID,
+							Hostname:   "host-" + agentID,
+							Timestamp:  base.Add(time.Duration(n) * time.Second),
+							ReportType: reportDelta,
+							Seq:        uint64(n),
+							Data: map[string]interface{}{
+								"process_delta": map[string]interface{}{
+									"added": []interface{}{map[string]interface{}{"pid": n, "start_time": 1}},
+								},
+							},
+						}
+						if n == 1 {
+							data.ReportType = reportFull
+							data.Data = map[string]interface{}{
+								"processes": []interface{}{map[string]interface{}{"pid": n, "start_time": 1}},
+							}
+						}
+
+						body, _ := json.Marshal(data)
+						resp, err := client.Post(ts.URL+"/api/agent/data", "application/json", bytes.NewReader(body))
+						if err != nil {
+							t.Errorf("%s report %d: %v", agentID, n, err)
+							return
+						}
+						var result struct {
+							Resync bool `json:"resync"`
+						}
+						json.NewDecoder(resp.Body).Decode(&result)
+						resp.Body.Close()
+						if resp.StatusCode != http.StatusOK || result.Resync {
+							t.Errorf("%s report %d: status %d, resync %v", agentID, n, resp.StatusCode, result.Resync)
+							return
+						}
+					}
+				}(a)
+			}
+			writers.Wait()
+			close(done)
+			readers.Wait()
+
+			if n := store.Count(); n != agents*reports {
+				t.Fatalf("stored %d reports, want %d", n, agents*reports)
+			}
+			if n := len(store.Agents()); n != agents {
+				t.Fatalf("%d agents, want %d", n, agents)
+			}
+			for a := 0; a < agents; a++ {
+				agentID := fmt.Sprintf("agent-%03d", a)
+				latest, err := store.Latest(agentID, 1)
+				if err != nil || len(latest) != 1 {
+					t.Fatalf("Latest(%s): %v, %d records", agentID, err, len(latest))
+				}
+				// 每次增量新增一个进程，最后一次上报还原出全部进程
+				if procs, _ := latest[0].Data["processes"].([]interface{}); len(procs) != reports {
+					t.Errorf("%s restored %d processes, want %d", agentID, len(procs), reports)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package monitor
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// 代理状态
+const (
+	StateOnline  = "online"
+	StateLate    = "late"
+	StateOffline = "offline"
+)
+
+// 事件类型
+const (
+	EventOnline    = "online"    // 首次上报
+	EventLate      = "late"      // 超过预期间隔未上报
+	EventOffline   = "offline"   // 长时间未上报
+	EventRecovered = "recovered" // 迟到或离线后恢复上报
+)
+
+// maxEvents 保留的事件数量上限
+const maxEvents = 5000
+
+// Options 监控参数
+type Options struct {
+	DefaultInterval time.Duration // 代理未上报间隔时使用的默认间隔
+	LateFactor      float64       // 超过 间隔×LateFactor 未上报视为迟到
+	OfflineFactor   float64       // 超过 间隔×OfflineFactor 未上报视为离线
+	CheckInterval   time.Duration // 状态检查间隔
+	HistoryPath     string        // 事件历史文件（JSON Lines），为空时仅保存在内存中
+	OnEvent         func(Event)   // 状态变化回调
+}
+
+// AgentState 代理在线状态
+type AgentState struct {
+	AgentID  string        `json:"agent_id"`  // 代理ID
+	Hostname string        `json:"hostname"`  // 主机名
+	State    string        `json:"state"`     // 当前状态
+	Since    time.Time     `json:"since"`     // 进入当前状态的时间
+	LastSeen time.Time     `json:"last_seen"` // 最近一次上报时间
+	Interval time.Duration `json:"-"`         // 预期上报间隔
+	reported bool          // 间隔是否由代理上报
+}
+
+// Event 状态变化事件
+type Event struct {
+	ID       int64     `json:"id"`             // 事件ID
+	AgentID  string    `json:"agent_id"`       // 代理ID
+	Hostname string    `json:"hostname"`       // 主机名
+	Type     string    `json:"type"`           // 事件类型
+	From     string    `json:"from,omitempty"` // 变化前状态
+	To       string    `json:"to"`             // 变化后状态
+	LastSeen time.Time `json:"last_seen"`      // 最近一次上报时间
+	Silence  string    `json:"silence"`        // 距最近一次上报的时长
+	Time     time.Time `json:"timestamp"`      // 事件时间
+}
+
+// Monitor 代理存活监控，按每个代理的预期上报间隔判断状态
+type Monitor struct {
+	opts Options
+
+	mu     sync.Mutex
+	agents map[string]*AgentState
+	events []Event
+	nextID int64
+	file   *os.File
+}
+
+// New 创建监控，并加载事件历史
+func New(opts Options) (*Monitor, error) {
+	if opts.DefaultInterval <= 0 {
+		opts.DefaultInterval = 30 * time.Second
+	}
+	if opts.LateFactor <= 0 {
+		opts.LateFactor = 2
+	}
+	if opts.OfflineFactor <= opts.LateFactor {
+		opts.OfflineFactor = opts.LateFactor * 3
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = 5 * time.Second
+	}
+
+	m := &Monitor{
+		opts:   opts,
+		agents: make(map[string]*AgentState),
+	}
+
+	if opts.HistoryPath != "" {
+		if err := m.loadHistory(); err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(opts.HistoryPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		m.file = file
+	}
+
+	return m, nil
+}
+
+// loadHistory 加载事件历史，只保留最近的 maxEvents 条并压缩文件
+func (m *Monitor) loadHistory() error {
+	file, err := os.Open(m.opts.HistoryPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	total := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var ev Event
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil {
+			continue
+		}
+		total++
+		m.events = append(m.events, ev)
+		if len(m.events) > maxEvents {
+			m.events = m.events[1:]
+		}
+		m.nextID = max(m.nextID, ev.ID)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if total > len(m.events) {
+		return m.rewriteHistory()
+	}
+	return nil
+}
+
+// rewriteHistory 用内存中的事件重写历史文件
+func (m *Monitor) rewriteHistory() error {
+	tmp := m.opts.HistoryPath + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, ev := range m.events {
+		if err := enc.Encode(ev); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.opts.HistoryPath)
+}
+
+// Seed 用已有数据初始化代理状态，不产生事件
+func (m *Monitor) Seed(agentID, hostname string, lastSeen time.Time, interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st := &AgentState{
+		AgentID:  agentID,
+		Hostname: hostname,
+		LastSeen: lastSeen,
+		Interval: interval,
+		reported: interval > 0,
+	}
+	if st.Interval <= 0 {
+		st.Interval = m.opts.DefaultInterval
+	}
+	st.State = m.stateFor(st, time.Now())
+	st.Since = lastSeen
+	m.agents[agentID] = st
+}
+
+// Observe 记录一次上报，interval 为代理上报的间隔（未知时为 0）
+func (m *Monitor) Observe(agentID, hostname string, at time.Time, interval time.Duration) {
+	var events []Event
+
+	m.mu.Lock()
+	st, ok := m.agents[agentID]
+	switch {
+	case !ok:
+		st = &AgentState{AgentID: agentID, Hostname: hostname, State: StateOnline, Since: at, LastSeen: at, Interval: m.opts.DefaultInterval}
+		m.agents[agentID] = st
+		events = append(events, m.record(st, EventOnline, "", StateOnline, at))
+	case st.State != StateOnline:
+		events = append(events, m.record(st, EventRecovered, st.State, StateOnline, at))
+		st.State = StateOnline
+		st.Since = at
+	}
+
+	switch {
+	case interval > 0:
+		st.Interval = interval
+		st.reported = true
+	case !st.reported && ok && at.After(st.LastSeen):
+		// 代理未上报间隔时按实际间隔平滑估计，忽略离线期间的长间隔
+		if gap := at.Sub(st.LastSeen); gap < time.Duration(float64(st.Interval)*m.opts.LateFactor) {
+			st.Interval = (st.Interval*3 + gap) / 4
+		}
+	}
+
+	st.Hostname = hostname
+	if at.After(st.LastSeen) {
+		st.LastSeen = at
+	}
+	m.mu.Unlock()
+
+	m.dispatch(events)
+}
+
+// Run 定期检查代理状态，直到 stopCh 关闭
+func (m *Monitor) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(m.opts.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			m.Check(now)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// Check 根据当前时间更新全部代理状态
+func (m *Monitor) Check(now time.Time) {
+	var events []Event
+
+	m.mu.Lock()
+	for _, st := range m.agents {
+		state := m.stateFor(st, now)
+		if state == st.State {
+			continue
+		}
+
+		// 状态只会在检查中变差，恢复由 Observe 处理
+		if rank(state) < rank(st.State) {
+			continue
+		}
+
+		typ := EventLate
+		if state == StateOffline {
+			typ = EventOffline
+		}
+		events = append(events, m.record(st, typ, st.State, state, now))
+		st.State = state
+		st.Since = now
+	}
+	m.mu.Unlock()
+
+	m.dispatch(events)
+}
+
+// stateFor 根据静默时长计算状态
+func (m *Monitor) stateFor(st *AgentState, now time.Time) string {
+	silence := now.Sub(st.LastSeen)
+	switch {
+	case silence > time.Duration(float64(st.Interval)*m.opts.OfflineFactor):
+		return StateOffline
+	case silence > time.Duration(float64(st.Interval)*m.opts.LateFactor):
+		return StateLate
+	default:
+		return StateOnline
+	}
+}
+
+// rank 状态严重程度
+func rank(state string) int {
+	switch state {
+	case StateLate:
+		return 1
+	case StateOffline:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// record 记录事件并写入历史文件，调用方需持有锁
+func (m *Monitor) record(st *AgentState, typ, from, to string, now time.Time) Event {
+	m.nextID++
+	ev := Event{
+		ID:       m.nextID,
+		AgentID:  st.AgentID,
+		Hostname: st.Hostname,
+		Type:     typ,
+		From:     from,
+		To:       to,
+		LastSeen: st.LastSeen,
+		Silence:  now.Sub(st.LastSeen).Round(time.Second).String(),
+		Time:     now,
+	}
+
+	m.events = append(m.events, ev)
+	if over := len(m.events) - maxEvents; over > 0 {
+		m.events = append([]Event(nil), m.events[over:]...)
+	}
+
+	if m.file != nil {
+		if data, err := json.Marshal(ev); err == nil {
+			m.file.Write(append(data, '\n'))
+		}
+	}
+	return ev
+}
+
+// dispatch 在锁外调用事件回调
+func (m *Monitor) dispatch(events []Event) {
+	if m.opts.OnEvent == nil {
+		return
+	}
+	for _, ev := range events {
+		m.opts.OnEvent(ev)
+	}
+}
+
+// State 返回代理状态
+func (m *Monitor) State(agentID string) (AgentState, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	st, ok := m.agents[agentID]
+	if !ok {
+		return AgentState{}, false
+	}
+	return *st, true
+}
+
+// Events 查询事件历史（新的在前），agentID 与 typ 为空时不过滤
+func (m *Monitor) Events(agentID, typ string, since time.Time, limit int) []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := []Event{}
+	for i := len(m.events) - 1; i >= 0 && len(result) < limit; i-- {
+		ev := m.events[i]
+		if agentID != "" && ev.AgentID != agentID {
+			continue
+		}
+		if typ != "" && ev.Type != typ {
+			continue
+		}
+		if !since.IsZero() && ev.Time.Before(since) {
+			break
+		}
+		result = append(result, ev)
+	}
+	return result
+}
+
+// Counts 按状态统计代理数量
+func (m *Monitor) Counts() map[string]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := map[string]int{StateOnline: 0, StateLate: 0, StateOffline: 0}
+	for _, st := range m.agents {
+		counts[st.State]++
+	}
+	return counts
+}
+
+// Close 关闭事件历史文件
+func (m *Monitor) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.file == nil {
+		return nil
+	}
+	err := m.file.Close()
+	m.file = nil
+	return err
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 消息格式
+const (
+	FormatGeneric  = "generic"  // 通用 JSON
+	FormatSlack    = "slack"    // Slack Incoming Webhook
+	FormatDingTalk = "dingtalk" // 钉钉自定义机器人
+	FormatWeCom    = "wecom"    // 企业微信群机器人
+)
+
+// 签名请求头
+const (
+	SignatureHeader = "X-Mini-HIDS-Signature"
+	TimestampHeader = "X-Mini-HIDS-Timestamp"
+)
+
+// ValidFormat 判断消息格式是否支持
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatGeneric, FormatSlack, FormatDingTalk, FormatWeCom:
+		return true
+	default:
+		return false
+	}
+}
+
+// encode 按目标格式生成请求体
+func encode(format string, ev Event) ([]byte, error) {
+	switch format {
+	case FormatGeneric, "":
+		return json.Marshal(ev)
+	case FormatSlack:
+		return json.Marshal(map[string]interface{}{
+			"text": fmt.Sprintf("*%s*\n%s", ev.Title, ev.Text),
+		})
+	case FormatDingTalk:
+		return json.Marshal(map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"title": ev.Title,
+				"text":  fmt.Sprintf("### %s\n\n%s", ev.Title, markdownLines(ev.Text)),
+			},
+		})
+	case FormatWeCom:
+		return json.Marshal(map[string]interface{}{
+			"msgtype": "markdown",
+			"markdown": map[string]string{
+				"content": fmt.Sprintf("**%s**\n%s", ev.Title, ev.Text),
+			},
+		})
+	default:
+		return nil, fmt.Errorf("unsupported webhook format %q", format)
+	}
+}
+
+// markdownLines 钉钉 markdown 需要两个换行才能分段
+func markdownLines(text string) string {
+	return strings.ReplaceAll(text, "\n", "\n\n")
+}
+
+// sign 为通用格式添加 HMAC-SHA256 签名头，签名内容为 "时间戳.请求体"
+func sign(req *http.Request, t Target, body []byte, now time.Time) {
+	if t.Secret == "" || t.Format == FormatDingTalk {
+		return
+	}
+
+	ts := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(t.Secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+// signedURL 钉钉加签：在 URL 中附加毫秒时间戳和 base64(HMAC-SHA256("时间戳\n密钥"))
+func signedURL(t Target, now time.Time) (string, error) {
+	if t.Secret == "" || t.Format != FormatDingTalk {
+		return t.URL, nil
+	}
+
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return "", err
+	}
+
+	ts := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(t.Secret))
+	mac.Write([]byte(ts + "\n" + t.Secret))
+
+	query := u.Query()
+	query.Set("timestamp", ts)
+	query.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,349 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 事件类型
+const (
+	EventAlert        = "alert"         // 新告警
+	EventAgentOffline = "agent_offline" // 代理离线
+)
+
+// 投递状态
+const (
+	DeliveryPending = "pending"
+	DeliverySuccess = "success"
+	DeliveryFailed  = "failed"
+	DeliveryDropped = "dropped"
+)
+
+// 投递参数
+const (
+	queueSize      = 1000
+	maxDeliveryLog = 1000
+	defaultRetries = 3
+	defaultTimeout = 10 * time.Second
+	baseBackoff    = time.Second
+)
+
+// severityRank 告警级别排序
+var severityRank = map[string]int{"low": 1, "medium": 2, "high": 3, "critical": 4}
+
+// Target 通知目标
+type Target struct {
+	Name        string   // 目标名称
+	URL         string   // 回调地址
+	Format      string   // 消息格式（generic/slack/dingtalk/wecom）
+	Secret      string   // 签名密钥，为空时不签名
+	MinSeverity string   // 告警最低级别
+	Events      []string // 订阅的事件类型，为空时订阅全部
+}
+
+// Event 通知事件
+type Event struct {
+	Type      string      `json:"event"`              // 事件类型
+	Severity  string      `json:"severity,omitempty"` // 告警级别
+	Title     string      `json:"title"`              // 标题
+	Text      string      `json:"text"`               // 正文
+	AgentID   string      `json:"agent_id"`           // 代理ID
+	Hostname  string      `json:"hostname"`           // 主机名
+	Data      interface{} `json:"data,omitempty"`     // 事件详情（告警或代理信息）
+	Timestamp time.Time   `json:"timestamp"`          // 事件时间
+}
+
+// Delivery 投递记录
+type Delivery struct {
+	ID          int64      `json:"id"`                     // 记录ID
+	Target      string     `json:"target"`                 // 目标名称
+	Event       string     `json:"event"`                  // 事件类型
+	Title       string     `json:"title"`                  // 事件标题
+	Status      string     `json:"status"`                 // 投递状态
+	Attempts    int        `json:"attempts"`               // 尝试次数
+	StatusCode  int        `json:"status_code,omitempty"`  // 最后一次响应状态码
+	Error       string     `json:"error,omitempty"`        // 最后一次错误
+	CreatedAt   time.Time  `json:"created_at"`             // 创建时间
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"` // 成功投递时间
+}
+
+// Notifier Webhook 通知器，异步投递并记录投递结果
+type Notifier struct {
+	targets []Target
+	retries int
+	client  *http.Client
+	queues  []chan job // 每个目标一个队列，失败重试不影响其他目标
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+	nextID int64
+	log    []*Delivery
+}
+
+// job 待投递的任务
+type job struct {
+	target   Target
+	event    Event
+	delivery *Delivery
+}
+
+// New 创建通知器，retries 为失败后的最大重试次数，小于 0 时使用默认值
+func New(targets []Target, retries int) *Notifier {
+	if retries < 0 {
+		retries = defaultRetries
+	}
+
+	n := &Notifier{
+		targets: targets,
+		retries: retries,
+		client:  &http.Client{Timeout: defaultTimeout},
+	}
+
+	for range targets {
+		queue := make(chan job, queueSize)
+		n.queues = append(n.queues, queue)
+		n.wg.Add(1)
+		go n.run(queue)
+	}
+	return n
+}
+
+// Targets 返回已配置的通知目标（不含密钥）
+func (n *Notifier) Targets() []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(n.targets))
+	for _, t := range n.targets {
+		result = append(result, map[string]interface{}{
+			"name":         t.Name,
+			"format":       t.Format,
+			"min_severity": t.MinSeverity,
+			"events":       t.Events,
+			"signed":       t.Secret != "",
+		})
+	}
+	return result
+}
+
+// Notify 将事件投递给所有匹配的目标，不会阻塞调用方
+func (n *Notifier) Notify(ev Event) {
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now()
+	}
+
+	for i, t := range n.targets {
+		if !t.accepts(ev) {
+			continue
+		}
+
+		n.enqueue(i, ev)
+	}
+}
+
+// enqueue 创建投递记录并放入队列，队列已满时丢弃
+func (n *Notifier) enqueue(i int, ev Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return
+	}
+
+	t := n.targets[i]
+	d := n.newDelivery(t, ev)
+	select {
+	case n.queues[i] <- job{target: t, event: ev, delivery: d}:
+	default:
+		d.Status = DeliveryDropped
+		d.Error = "delivery queue full"
+		log.Printf("Webhook %s: queue full, dropped %s event", t.Name, ev.Type)
+	}
+}
+
+// accepts 判断目标是否订阅该事件
+func (t Target) accepts(ev Event) bool {
+	if len(t.Events) > 0 {
+		subscribed := false
+		for _, e := range t.Events {
+			if e == ev.Type {
+				subscribed = true
+				break
+			}
+		}
+		if !subscribed {
+			return false
+		}
+	}
+
+	// 级别过滤只作用于告警事件
+	if ev.Type == EventAlert && t.MinSeverity != "" {
+		return severityRank[ev.Severity] >= severityRank[t.MinSeverity]
+	}
+	return true
+}
+
+// run 顺序处理单个目标的投递任务
+func (n *Notifier) run(queue <-chan job) {
+	defer n.wg.Done()
+
+	for j := range queue {
+		n.deliver(j)
+	}
+}
+
+// deliver 投递单个任务，失败时按指数退避重试
+func (n *Notifier) deliver(j job) {
+	body, err := encode(j.target.Format, j.event)
+	if err != nil {
+		n.finish(j.delivery, DeliveryFailed, 0, err.Error())
+		return
+	}
+
+	backoff := baseBackoff
+	for attempt := 0; attempt <= n.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		code, retry, err := n.post(j.target, body)
+		n.mu.Lock()
+		j.delivery.Attempts = attempt + 1
+		j.delivery.StatusCode = code
+		n.mu.Unlock()
+
+		if err == nil {
+			n.finish(j.delivery, DeliverySuccess, code, "")
+			return
+		}
+
+		log.Printf("Webhook %s: delivery attempt %d failed: %v", j.target.Name, attempt+1, err)
+		if !retry || attempt == n.retries {
+			n.finish(j.delivery, DeliveryFailed, code, err.Error())
+			return
+		}
+	}
+}
+
+// post 发送一次请求，返回状态码、是否可重试以及错误
+func (n *Notifier) post(t Target, body []byte) (int, bool, error) {
+	url, err := signedURL(t, time.Now())
+	if err != nil {
+		return 0, false, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mini-hids-server")
+	sign(req, t, body, time.Now())
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, true, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return resp.StatusCode, true, fmt.Errorf("server returned %s", resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return resp.StatusCode, false, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	// 钉钉与企业微信在 HTTP 200 中通过 errcode 返回错误
+	if t.Format == FormatDingTalk || t.Format == FormatWeCom {
+		var result struct {
+			ErrCode int    `json:"errcode"`
+			ErrMsg  string `json:"errmsg"`
+		}
+		if json.Unmarshal(respBody, &result) == nil && result.ErrCode != 0 {
+			return resp.StatusCode, true, fmt.Errorf("errcode %d: %s", result.ErrCode, result.ErrMsg)
+		}
+	}
+
+	return resp.StatusCode, false, nil
+}
+
+// newDelivery 创建投递记录，超出上限时丢弃最旧的记录，调用方需持有锁
+func (n *Notifier) newDelivery(t Target, ev Event) *Delivery {
+	n.nextID++
+	d := &Delivery{
+		ID:        n.nextID,
+		Target:    t.Name,
+		Event:     ev.Type,
+		Title:     ev.Title,
+		Status:    DeliveryPending,
+		CreatedAt: time.Now(),
+	}
+
+	n.log = append(n.log, d)
+	if over := len(n.log) - maxDeliveryLog; over > 0 {
+		n.log = append([]*Delivery(nil), n.log[over:]...)
+	}
+	return d
+}
+
+// finish 更新投递结果
+func (n *Notifier) finish(d *Delivery, status string, code int, errMsg string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	d.Status = status
+	d.StatusCode = code
+	d.Error = errMsg
+	if status == DeliverySuccess {
+		now := time.Now()
+		d.DeliveredAt = &now
+	}
+}
+
+// Deliveries 查询投递记录（新的在前），target 与 status 为空时不过滤
+func (n *Notifier) Deliveries(target, status string, limit int) []Delivery {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	result := []Delivery{}
+	for i := len(n.log) - 1; i >= 0 && len(result) < limit; i-- {
+		d := n.log[i]
+		if (target == "" || d.Target == target) && (status == "" || d.Status == status) {
+			result = append(result, *d)
+		}
+	}
+	return result
+}
+
+// Close 停止接收新事件，并在 timeout 内等待队列中的任务处理完成
+func (n *Notifier) Close(timeout time.Duration) {
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	n.closed = true
+	for _, queue := range n.queues {
+		close(queue)
+	}
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Webhook queue not drained after %s, pending deliveries abandoned", timeout)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// maxTreeDepth 进程树的最大深度，防止异常数据导致无限递归
+const maxTreeDepth = 64
+
+// processTree 按 PID 索引的进程快照
+type processTree struct {
+	byPID    map[int]map[string]interface{}
+	children map[int][]int
+}
+
+// newProcessTree 根据进程列表建立父子关系
+func newProcessTree(processes []map[string]interface{}) *processTree {
+	t := &processTree{
+		byPID:    make(map[int]map[string]interface{}, len(processes)),
+		children: make(map[int][]int),
+	}
+	for _, p := range processes {
+		t.byPID[intField(p, "pid")] = p
+	}
+	for pid, p := range t.byPID {
+		ppid := intField(p, "ppid")
+		if ppid != pid {
+			t.children[ppid] = append(t.children[ppid], pid)
+		}
+	}
+	for _, pids := range t.children {
+		sort.Ints(pids)
+	}
+	return t
+}
+
+// ancestors 返回进程的祖先链，从父进程开始直到根进程
+func (t *processTree) ancestors(pid int) []map[string]interface{} {
+	chain := make([]map[string]interface{}, 0)
+	seen := map[int]bool{pid: true}
+
+	for p := t.byPID[pid]; p != nil; {
+		ppid := intField(p, "ppid")
+		parent, ok := t.byPID[ppid]
+		if !ok || seen[ppid] {
+			break
+		}
+		seen[ppid] = true
+		chain = append(chain, parent)
+		p = parent
+	}
+	return chain
+}
+
+// subtree 返回以 pid 为根的子树，子进程放在 children 字段中
+func (t *processTree) subtree(pid, depth int) map[string]interface{} {
+	node := make(map[string]interface{}, len(t.byPID[pid])+1)
+	for k, v := range t.byPID[pid] {
+		node[k] = v
+	}
+	if depth >= maxTreeDepth {
+		return node
+	}
+
+	children := make([]map[string]interface{}, 0, len(t.children[pid]))
+	for _, child := range t.children[pid] {
+		children = append(children, t.subtree(child, depth+1))
+	}
+	node["children"] = children
+	return node
+}
+
+// roots 返回父进程不在快照中的进程（通常为 PID 1 和内核线程 kthreadd）
+func (t *processTree) roots() []int {
+	var roots []int
+	for pid, p := range t.byPID {
+		ppid := intField(p, "ppid")
+		if _, ok := t.byPID[ppid]; !ok || ppid == pid {
+			roots = append(roots, pid)
+		}
+	}
+	sort.Ints(roots)
+	return roots
+}
+
+// handleProcessTree 返回代理最近一次进程快照中的进程树
+//
+// 指定 pid 参数时返回该进程、其祖先链和全部后代，否则返回完整的进程树。
+func (s *Server) handleProcessTree(w http.ResponseWriter, r *http.Request, agentID string) {
+	latest, err := s.store.Latest(agentID, 1)
+	if err != nil {
+		logErrorf("Failed to read data for agent %s: %v", agentID, err)
+		http.Error(w, "Failed to read data", http.StatusInternalServerError)
+		return
+	}
+	if len(latest) == 0 || latest[0].Data["processes"] == nil {
+		http.Error(w, "No process snapshot for agent", http.StatusNotFound)
+		return
+	}
+
+	snapshot := latest[0]
+	tree := newProcessTree(listItems(snapshot.Data["processes"]))
+	result := map[string]interface{}{
+		"agent_id":  agentID,
+		"timestamp": snapshot.Timestamp,
+	}
+
+	if value := r.URL.Query().Get("pid"); value != "" {
+		pid, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid pid parameter", http.StatusBadRequest)
+			return
+		}
+		if _, ok := tree.byPID[pid]; !ok {
+			http.Error(w, "Process not found", http.StatusNotFound)
+			return
+		}
+		node := tree.subtree(pid, 0)
+		result["pid"] = pid
+		result["ancestors"] = tree.ancestors(pid)
+		result["children"] = node["children"]
+		delete(node, "children")
+		result["process"] = node
+	} else {
+		roots := make([]map[string]interface{}, 0)
+		for _, pid := range tree.roots() {
+			roots = append(roots, tree.subtree(pid, 0))
+		}
+		result["tree"] = roots
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// intField 读取 JSON 对象中的整数字段
+func intField(item map[string]interface{}, key string) int {
+	switch v := item[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
@@ -0,0 +1,257 @@
+package registry
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 认证错误
+var (
+	ErrUnknownAgent = errors.New("registry: unknown agent")
+	ErrRevoked      = errors.New("registry: agent revoked")
+	ErrBadSecret    = errors.New("registry: invalid agent secret")
+)
+
+// Agent 已注册的代理
+type Agent struct {
+	ID         string     `json:"agent_id"`             // 代理ID
+	Hostname   string     `json:"hostname"`             // 注册时的主机名
+	SecretHash string     `json:"secret_hash"`          // 密钥的 SHA-256，只用证书认证的代理为空
+	EnrolledAt time.Time  `json:"enrolled_at"`          // 注册时间，证书代理为首次认证时间
+	CertAuth   bool       `json:"cert_auth,omitempty"`  // 是否使用过客户端证书认证
+	Revoked    bool       `json:"revoked"`              // 是否已吊销
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"` // 吊销时间
+}
+
+// Registry 代理注册表，保存在 JSON 文件中
+type Registry struct {
+	mu     sync.RWMutex
+	path   string
+	agents map[string]*Agent
+}
+
+// Open 打开注册表，path 为空时仅保存在内存中
+func Open(path string) (*Registry, error) {
+	r := &Registry{
+		path:   path,
+		agents: make(map[string]*Agent),
+	}
+
+	if path == "" {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var agents []*Agent
+	if err := json.Unmarshal(data, &agents); err != nil {
+		return nil, fmt.Errorf("parse registry %s: %w", path, err)
+	}
+	for _, agent := range agents {
+		r.agents[agent.ID] = agent
+	}
+
+	return r, nil
+}
+
+// Enroll 注册新代理，返回代理信息和明文密钥（只在此时返回一次）
+func (r *Registry) Enroll(hostname string) (Agent, string, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return Agent{}, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return Agent{}, "", err
+	}
+
+	agent := &Agent{
+		ID:         fmt.Sprintf("%s-%s-%s-%s-%s", id[0:8], id[8:12], id[12:16], id[16:20], id[20:32]),
+		Hostname:   hostname,
+		SecretHash: hashSecret(secret),
+		EnrolledAt: time.Now(),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.agents[agent.ID] = agent
+	if err := r.save(); err != nil {
+		delete(r.agents, agent.ID)
+		return Agent{}, "", err
+	}
+
+	return *agent, secret, nil
+}
+
+// Authenticate 校验代理凭据
+func (r *Registry) Authenticate(id, secret string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	agent, ok := r.agents[id]
+	if !ok {
+		return ErrUnknownAgent
+	}
+	if agent.SecretHash == "" || subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(agent.SecretHash)) != 1 {
+		return ErrBadSecret
+	}
+	if agent.Revoked {
+		return ErrRevoked
+	}
+	return nil
+}
+
+// RecordCertAgent 记录通过客户端证书认证的代理ID，之后不带该证书或凭据的上报不能再使用这个ID
+//
+// 写入文件失败时仍保留在内存中，返回的错误只用于记录日志。
+func (r *Registry) RecordCertAgent(id string) error {
+	r.mu.RLock()
+	agent, ok := r.agents[id]
+	known := ok && agent.CertAuth
+	r.mu.RUnlock()
+	if known {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	agent, ok = r.agents[id]
+	switch {
+	case !ok:
+		r.agents[id] = &Agent{ID: id, EnrolledAt: time.Now(), CertAuth: true}
+	case agent.CertAuth:
+		return nil
+	default:
+		agent.CertAuth = true
+	}
+	return r.save()
+}
+
+// Revoke 吊销代理，之后该代理的凭据不再有效
+func (r *Registry) Revoke(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	agent, ok := r.agents[id]
+	if !ok {
+		return ErrUnknownAgent
+	}
+	if agent.Revoked {
+		return nil
+	}
+
+	now := time.Now()
+	agent.Revoked = true
+	agent.RevokedAt = &now
+	if err := r.save(); err != nil {
+		agent.Revoked = false
+		agent.RevokedAt = nil
+		return err
+	}
+	return nil
+}
+
+// Get 获取代理信息
+func (r *Registry) Get(id string) (Agent, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	agent, ok := r.agents[id]
+	if !ok {
+		return Agent{}, false
+	}
+	return *agent, true
+}
+
+// List 返回全部已注册代理，按注册时间排序
+func (r *Registry) List() []Agent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	agents := make([]Agent, 0, len(r.agents))
+	for _, agent := range r.agents {
+		agents = append(agents, *agent)
+	}
+
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].EnrolledAt.Before(agents[j].EnrolledAt)
+	})
+	return agents
+}
+
+// save 原子地写入注册表文件，调用方需持有写锁
+func (r *Registry) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	agents := make([]*Agent, 0, len(r.agents))
+	for _, agent := range r.agents {
+		agents = append(agents, agent)
+	}
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].EnrolledAt.Before(agents[j].EnrolledAt)
+	})
+
+	data, err := json.MarshalIndent(agents, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// hashSecret 计算密钥的 SHA-256，注册表中不保存明文
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex 生成 n 字节随机数的十六进制字符串
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,162 @@
+package registry
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	r, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent, secret, err := r.Enroll("web-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agent.Hostname != "web-01" || agent.SecretHash == secret {
+		t.Fatalf("unexpected agent: %+v", agent)
+	}
+	// 证书代理没有密钥，任何密钥（包括空字符串）都不能通过
+	if err := r.RecordCertAgent("cert-agent"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		id     string
+		secret string
+		want   error
+	}{
+		{"valid", agent.ID, secret, nil},
+		{"wrong secret", agent.ID, secret + "x", ErrBadSecret},
+		{"empty secret", agent.ID, "", ErrBadSecret},
+		{"unknown agent", "no-such-agent", secret, ErrUnknownAgent},
+		{"cert agent empty secret", "cert-agent", "", ErrBadSecret},
+		{"cert agent hash of empty", "cert-agent", hashSecret(""), ErrBadSecret},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := r.Authenticate(tt.id, tt.secret); !errors.Is(err, tt.want) {
+				t.Errorf("Authenticate() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	r, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent, secret, err := r.Enroll("web-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, otherSecret, err := r.Enroll("web-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Revoke(agent.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Authenticate(agent.ID, secret); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Authenticate() after revoke = %v, want %v", err, ErrRevoked)
+	}
+	got, _ := r.Get(agent.ID)
+	if !got.Revoked || got.RevokedAt == nil {
+		t.Errorf("agent not marked revoked: %+v", got)
+	}
+
+	// 重复吊销不改变吊销时间
+	revokedAt := *got.RevokedAt
+	if err := r.Revoke(agent.ID); err != nil {
+		t.Errorf("second Revoke() = %v", err)
+	}
+	if got, _ := r.Get(agent.ID); !got.RevokedAt.Equal(revokedAt) {
+		t.Errorf("RevokedAt changed to %v, want %v", got.RevokedAt, revokedAt)
+	}
+
+	if err := r.Revoke("no-such-agent"); !errors.Is(err, ErrUnknownAgent) {
+		t.Errorf("Revoke(unknown) = %v, want %v", err, ErrUnknownAgent)
+	}
+	if err := r.Authenticate(other.ID, otherSecret); err != nil {
+		t.Errorf("other agent rejected: %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agents.json")
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, revokedSecret, err := r.Enroll("web-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, activeSecret, err := r.Enroll("web-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Revoke(revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RecordCertAgent("cert-agent"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 注册表中包含密钥哈希，仅所有者可读写
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("registry file mode = %o, want 600", perm)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{revokedSecret, activeSecret} {
+		if strings.Contains(string(data), secret) {
+			t.Error("registry file contains plaintext secret")
+		}
+	}
+
+	r, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Authenticate(active.ID, activeSecret); err != nil {
+		t.Errorf("Authenticate(active) after reload = %v", err)
+	}
+	if err := r.Authenticate(revoked.ID, revokedSecret); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Authenticate(revoked) after reload = %v, want %v", err, ErrRevoked)
+	}
+	cert, ok := r.Get("cert-agent")
+	if !ok || !cert.CertAuth || cert.SecretHash != "" {
+		t.Errorf("cert agent after reload = %+v, %v", cert, ok)
+	}
+	if err := r.Authenticate("cert-agent", ""); !errors.Is(err, ErrBadSecret) {
+		t.Errorf("Authenticate(cert-agent) after reload = %v, want %v", err, ErrBadSecret)
+	}
+	if n := len(r.List()); n != 3 {
+		t.Errorf("List() returned %d agents, want 3", n)
+	}
+}
+
+func TestOpenInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agents.json")
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("Open() accepted a corrupt registry file")
+	}
+}
@@ -0,0 +1,236 @@
+package rules
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"mini-hids/server/storage"
+)
+
+// maxMatchesPerRule 单条规则在一次上报中最多产生的告警数量
+const maxMatchesPerRule = 100
+
+// Match 规则命中记录
+type Match struct {
+	RuleID    string                 `json:"rule_id"`   // 规则ID
+	RuleName  string                 `json:"rule_name"` // 规则名称
+	Severity  string                 `json:"severity"`  // 告警级别
+	Section   string                 `json:"section"`   // 命中的数据段
+	AgentID   string                 `json:"agent_id"`  // 代理ID
+	Hostname  string                 `json:"hostname"`  // 主机名
+	Evidence  map[string]interface{} `json:"evidence"`  // 命中的数据条目
+	Timestamp time.Time              `json:"timestamp"` // 命中时间
+}
+
+// Engine 规则引擎
+type Engine struct {
+	dir   string
+	mu    sync.RWMutex
+	rules []*Rule
+}
+
+// NewEngine 从规则目录创建规则引擎
+func NewEngine(dir string) (*Engine, error) {
+	e := &Engine{dir: dir}
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload 重新加载规则目录，加载失败时保留原有规则
+func (e *Engine) Reload() error {
+	rules, err := LoadDir(e.dir)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.rules = rules
+	e.mu.Unlock()
+	return nil
+}
+
+// Dir 返回规则目录
+func (e *Engine) Dir() string {
+	return e.dir
+}
+
+// Rules 返回当前加载的规则
+func (e *Engine) Rules() []*Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := make([]*Rule, len(e.rules))
+	copy(result, e.rules)
+	return result
+}
+
+// Evaluate 对一次上报数据执行全部启用的规则
+func (e *Engine) Evaluate(data storage.AgentData) []Match {
+	e.mu.RLock()
+	rules := e.rules
+	e.mu.RUnlock()
+
+	now := time.Now()
+	var matches []Match
+
+	for _, rule := range rules {
+		if !rule.IsEnabled() {
+			continue
+		}
+
+		count := 0
+		for _, item := range sectionItems(data.Data, rule.Section) {
+			if !rule.matches(item) {
+				continue
+			}
+
+			matches = append(matches, Match{
+				RuleID:    rule.ID,
+				RuleName:  rule.Name,
+				Severity:  rule.Severity,
+				Section:   rule.Section,
+				AgentID:   data.AgentID,
+				Hostname:  data.Hostname,
+				Evidence:  item,
+				Timestamp: now,
+			})
+
+			count++
+			if count >= maxMatchesPerRule {
+				break
+			}
+		}
+	}
+
+	return matches
+}
+
+// sectionItems 取出数据段中的条目，对象视为单个条目，数组中的每个对象各为一个条目
+func sectionItems(data map[string]interface{}, section string) []map[string]interface{} {
+	switch value := data[section].(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{value}
+	case []interface{}:
+		items := make([]map[string]interface{}, 0, len(value))
+		for _, v := range value {
+			if item, ok := v.(map[string]interface{}); ok {
+				items = append(items, item)
+			}
+		}
+		return items
+	default:
+		return nil
+	}
+}
+
+// matches 判断条目是否命中规则
+func (r *Rule) matches(item map[string]interface{}) bool {
+	for i := range r.Conditions {
+		ok := r.Conditions[i].matches(item)
+		if r.Match == "any" && ok {
+			return true
+		}
+		if r.Match == "all" && !ok {
+			return false
+		}
+	}
+	return r.Match == "all"
+}
+
+// matches 判断条目是否满足条件，字段为数组时任一元素满足即可
+func (c *Condition) matches(item map[string]interface{}) bool {
+	values := resolve(item, strings.Split(c.Field, "."))
+
+	switch c.Op {
+	case OpExists:
+		return len(values) > 0
+	case OpNotEquals, OpNotContains, OpNotIn:
+		// 否定条件要求所有值都不满足
+		for _, v := range values {
+			if !c.test(v) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, v := range values {
+		if c.test(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// test 对单个值执行比较
+func (c *Condition) test(v interface{}) bool {
+	switch c.Op {
+	case OpEquals:
+		return toString(v) == toString(c.Value)
+	case OpNotEquals:
+		return toString(v) != toString(c.Value)
+	case OpContains:
+		return strings.Contains(toString(v), toString(c.Value))
+	case OpNotContains:
+		return !strings.Contains(toString(v), toString(c.Value))
+	case OpPrefix:
+		return strings.HasPrefix(toString(v), toString(c.Value))
+	case OpSuffix:
+		return strings.HasSuffix(toString(v), toString(c.Value))
+	case OpRegex:
+		return c.regex.MatchString(toString(v))
+	case OpIn:
+		return c.set[toString(v)]
+	case OpNotIn:
+		return !c.set[toString(v)]
+	case OpGT, OpGTE, OpLT, OpLTE:
+		n, ok := toNumber(v)
+		if !ok {
+			return false
+		}
+		switch c.Op {
+		case OpGT:
+			return n > c.number
+		case OpGTE:
+			return n >= c.number
+		case OpLT:
+			return n < c.number
+		default:
+			return n <= c.number
+		}
+	}
+	return false
+}
+
+// resolve 按路径取值，路径经过数组时展开为多个值
+func resolve(value interface{}, path []string) []interface{} {
+	if len(path) == 0 {
+		if list, ok := value.([]interface{}); ok {
+			return list
+		}
+		if value == nil {
+			return nil
+		}
+		return []interface{}{value}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		next, ok := v[path[0]]
+		if !ok {
+			return nil
+		}
+		return resolve(next, path[1:])
+	case []interface{}:
+		var result []interface{}
+		for _, elem := range v {
+			result = append(result, resolve(elem, path)...)
+		}
+		return result
+	default:
+		return nil
+	}
+}
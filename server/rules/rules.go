@@ -0,0 +1,250 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 告警级别
+const (
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// severityRank 告警级别排序
+var severityRank = map[string]int{
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+// SeverityRank 返回告警级别的序号，未知级别返回 0
+func SeverityRank(severity string) int {
+	return severityRank[severity]
+}
+
+// 条件运算符
+const (
+	OpEquals      = "equals"
+	OpNotEquals   = "not_equals"
+	OpContains    = "contains"
+	OpNotContains = "not_contains"
+	OpPrefix      = "prefix"
+	OpSuffix      = "suffix"
+	OpRegex       = "regex"
+	OpIn          = "in"
+	OpNotIn       = "not_in"
+	OpGT          = "gt"
+	OpGTE         = "gte"
+	OpLT          = "lt"
+	OpLTE         = "lte"
+	OpExists      = "exists"
+)
+
+// Rule 检测规则
+type Rule struct {
+	ID          string      `json:"id"`                    // 规则ID
+	Name        string      `json:"name"`                  // 规则名称
+	Description string      `json:"description,omitempty"` // 规则说明
+	Severity    string      `json:"severity"`              // 告警级别
+	Section     string      `json:"section"`               // 匹配的数据段，如 processes、network、system、files
+	Match       string      `json:"match,omitempty"`       // 条件组合方式（all/any），默认为 all
+	Enabled     *bool       `json:"enabled,omitempty"`     // 是否启用，默认启用
+	Conditions  []Condition `json:"conditions"`            // 匹配条件
+	Source      string      `json:"source,omitempty"`      // 规则文件
+}
+
+// Condition 匹配条件
+type Condition struct {
+	Field  string        `json:"field"`            // 字段路径，嵌套字段用 . 分隔
+	Op     string        `json:"op"`               // 运算符
+	Value  interface{}   `json:"value,omitempty"`  // 比较值
+	Values []interface{} `json:"values,omitempty"` // in/not_in 的候选值
+
+	regex  *regexp.Regexp
+	set    map[string]bool
+	number float64
+}
+
+// IsEnabled 判断规则是否启用
+func (r *Rule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// LoadDir 加载目录中全部 .json 规则文件，文件内容可以是单条规则或规则数组
+func LoadDir(dir string) ([]*Rule, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var all []*Rule
+	var errs []error
+	seen := make(map[string]string)
+
+	for _, file := range files {
+		rules, err := loadFile(file)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, rule := range rules {
+			if prev, ok := seen[rule.ID]; ok {
+				errs = append(errs, fmt.Errorf("%s: duplicate rule id %q (also in %s)", file, rule.ID, prev))
+				continue
+			}
+			seen[rule.ID] = file
+			all = append(all, rule)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return all, nil
+}
+
+// loadFile 加载单个规则文件
+func loadFile(file string) ([]*Rule, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*Rule
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &rules)
+	} else {
+		var rule Rule
+		err = json.Unmarshal(data, &rule)
+		rules = []*Rule{&rule}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	for _, rule := range rules {
+		rule.Source = filepath.Base(file)
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("%s: rule %q: %w", file, rule.ID, err)
+		}
+	}
+	return rules, nil
+}
+
+// compile 校验规则并预编译条件
+func (r *Rule) compile() error {
+	if r.ID == "" {
+		return errors.New("id is required")
+	}
+	if r.Name == "" {
+		r.Name = r.ID
+	}
+	if _, ok := severityRank[r.Severity]; !ok {
+		return fmt.Errorf("severity must be one of low, medium, high, critical, got %q", r.Severity)
+	}
+	if r.Section == "" {
+		return errors.New("section is required")
+	}
+	switch r.Match {
+	case "":
+		r.Match = "all"
+	case "all", "any":
+	default:
+		return fmt.Errorf("match must be all or any, got %q", r.Match)
+	}
+	if len(r.Conditions) == 0 {
+		return errors.New("at least one condition is required")
+	}
+
+	for i := range r.Conditions {
+		if err := r.Conditions[i].compile(); err != nil {
+			return fmt.Errorf("condition %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// compile 预编译单个条件
+func (c *Condition) compile() error {
+	if c.Field == "" {
+		return errors.New("field is required")
+	}
+
+	switch c.Op {
+	case OpEquals, OpNotEquals, OpContains, OpNotContains, OpPrefix, OpSuffix:
+		if c.Value == nil {
+			return fmt.Errorf("%s requires value", c.Op)
+		}
+	case OpRegex:
+		pattern, ok := c.Value.(string)
+		if !ok {
+			return errors.New("regex requires a string value")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		c.regex = re
+	case OpIn, OpNotIn:
+		if len(c.Values) == 0 {
+			return fmt.Errorf("%s requires values", c.Op)
+		}
+		c.set = make(map[string]bool, len(c.Values))
+		for _, v := range c.Values {
+			c.set[toString(v)] = true
+		}
+	case OpGT, OpGTE, OpLT, OpLTE:
+		n, ok := toNumber(c.Value)
+		if !ok {
+			return fmt.Errorf("%s requires a numeric value", c.Op)
+		}
+		c.number = n
+	case OpExists:
+	default:
+		return fmt.Errorf("unknown op %q", c.Op)
+	}
+	return nil
+}
+
+// toString 将 JSON 值转换为字符串，整数不带小数部分
+func toString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// toNumber 将 JSON 值转换为数字，支持 "12.5%" 形式的字符串
+func toNumber(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case int:
+		return float64(val), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(val), "%"), 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// defaultLoginRecordLimit 会话接口默认返回的登录记录数量
+const defaultLoginRecordLimit = 100
+
+// handleSessions 返回代理当前登录的会话和最近的登录/注销记录
+//
+// 当前会话取自最近一次上报；登录记录默认从最近 100 次上报中收集，也可以用 from/to 指定时间范围，
+// 并按 type、user 过滤，按时间倒序返回最多 limit 条。
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request, agentID string) {
+	query := r.URL.Query()
+	from, err := parseTimeParam(query.Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from parameter", http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(query.Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid to parameter", http.StatusBadRequest)
+		return
+	}
+	limit := defaultLoginRecordLimit
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	latest, err := s.store.Latest(agentID, 1)
+	if err != nil {
+		logErrorf("Failed to read data for agent %s: %v", agentID, err)
+		http.Error(w, "Failed to read data", http.StatusInternalServerError)
+		return
+	}
+
+	var dataList []AgentData
+	if from.IsZero() && to.IsZero() {
+		dataList, err = s.store.Latest(agentID, 100)
+	} else {
+		dataList, err = s.store.Range(agentID, from, to)
+	}
+	if err != nil {
+		logErrorf("Failed to read data for agent %s: %v", agentID, err)
+		http.Error(w, "Failed to read data", http.StatusInternalServerError)
+		return
+	}
+
+	records := make([]map[string]interface{}, 0)
+	for _, data := range dataList {
+		for _, record := range listItems(data.Data["login_records"]) {
+			if t := query.Get("type"); t != "" && record["type"] != t {
+				continue
+			}
+			if u := query.Get("user"); u != "" && record["user"] != u {
+				continue
+			}
+			records = append(records, record)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return recordTime(records[i]).After(recordTime(records[j]))
+	})
+	if len(records) > limit {
+		records = records[:limit]
+	}
+
+	result := map[string]interface{}{
+		"agent_id":      agentID,
+		"sessions":      []map[string]interface{}{},
+		"login_records": records,
+	}
+	if len(latest) > 0 {
+		result["timestamp"] = latest[0].Timestamp
+		if sessions := listItems(latest[0].Data["sessions"]); sessions != nil {
+			result["sessions"] = sessions
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// recordTime 读取记录中的 timestamp 字段
+func recordTime(item map[string]interface{}) time.Time {
+	value, _ := item["timestamp"].(string)
+	t, _ := time.Parse(time.RFC3339Nano, value)
+	return t
+}
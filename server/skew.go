@@ -0,0 +1,110 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// agentTimeHeader 代理发送请求时附带的本地时间（RFC3339），用于估计时钟偏差
+const agentTimeHeader = "X-Agent-Time"
+
+// clockSkew 代理时钟偏差
+type clockSkew struct {
+	Skew    time.Duration // 代理时钟减服务端时钟
+	Skewed  bool          // 偏差是否超过阈值
+	Updated time.Time     // 最近一次测量时间
+}
+
+// skewTracker 记录各代理最近一次测量的时钟偏差
+type skewTracker struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	agents    map[string]clockSkew
+}
+
+// newSkewTracker 创建时钟偏差记录
+func newSkewTracker(threshold time.Duration) *skewTracker {
+	return &skewTracker{threshold: threshold, agents: make(map[string]clockSkew)}
+}
+
+// observe 记录一次测量结果，偏差超出或回到阈值内时记录日志
+func (t *skewTracker) observe(agentID string, skew time.Duration, at time.Time) clockSkew {
+	cs := clockSkew{Skew: skew, Skewed: absDuration(skew) > t.threshold, Updated: at}
+
+	t.mu.Lock()
+	prev, ok := t.agents[agentID]
+	t.agents[agentID] = cs
+	t.mu.Unlock()
+
+	switch {
+	case cs.Skewed && (!ok || !prev.Skewed):
+		logWarnf("Agent %s clock is off by %s (threshold %s), correcting collection times", agentID, skew.Round(time.Millisecond), t.threshold)
+	case !cs.Skewed && ok && prev.Skewed:
+		logInfof("Agent %s clock is back within %s", agentID, t.threshold)
+	}
+	return cs
+}
+
+// get 获取代理最近一次测量的时钟偏差
+func (t *skewTracker) get(agentID string) (clockSkew, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cs, ok := t.agents[agentID]
+	return cs, ok
+}
+
+// seedClockSkew 用代理最近一条记录初始化时钟偏差
+func (s *Server) seedClockSkew() {
+	for _, summary := range s.store.Agents() {
+		latest, err := s.store.Latest(summary.AgentID, 1)
+		if err != nil || len(latest) == 0 || latest[0].ReceivedAt.IsZero() {
+			continue
+		}
+		skew := time.Duration(latest[0].ClockSkew * float64(time.Second))
+		s.skews.agents[summary.AgentID] = clockSkew{
+			Skew:    skew,
+			Skewed:  absDuration(skew) > s.skews.threshold,
+			Updated: latest[0].ReceivedAt,
+		}
+	}
+}
+
+// stampTimes 记录采集时间与接收时间，测量代理时钟偏差，偏差过大时按服务端时钟校正采集时间
+func (s *Server) stampTimes(r *http.Request, data *AgentData, receivedAt time.Time) {
+	// 旧版代理只上报 timestamp
+	if data.CollectedAt.IsZero() {
+		data.CollectedAt = data.Timestamp
+	}
+	data.ReceivedAt = receivedAt
+	data.Timestamp = data.CollectedAt
+
+	// 优先使用发送时间测量：补发的缓存上报采集时间早于接收时间，但不代表时钟有偏差
+	skew, measured := time.Duration(0), false
+	if sentAt, err := time.Parse(time.RFC3339Nano, r.Header.Get(agentTimeHeader)); err == nil {
+		skew, measured = sentAt.Sub(receivedAt), true
+	} else if data.CollectedAt.After(receivedAt) {
+		skew, measured = data.CollectedAt.Sub(receivedAt), true
+	}
+
+	if measured {
+		data.ClockSkew = math.Round(skew.Seconds()*1000) / 1000
+		if cs := s.skews.observe(data.AgentID, skew, receivedAt); cs.Skewed && !data.Timestamp.IsZero() {
+			data.Timestamp = data.Timestamp.Add(-skew)
+		}
+	}
+
+	// 缺失或仍然超前的采集时间以接收时间为准
+	if data.Timestamp.IsZero() || data.Timestamp.After(receivedAt) {
+		data.Timestamp = receivedAt
+	}
+}
+
+// absDuration 返回时长的绝对值
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
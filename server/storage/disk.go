@@ -0,0 +1,820 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSegmentSize 单个段文件的默认最大字节数
+const DefaultSegmentSize = 64 << 20
+
+// DefaultMaxRecordsPerAgent 每个代理在内存索引中保留的默认记录数
+const DefaultMaxRecordsPerAgent = 100000
+
+const (
+	segmentExt       = ".log"
+	indexExt         = ".idx"
+	recordHeaderSize = 8 // 记录头: 长度(4) + CRC32(4)
+	maxRecordSize    = 1 << 30
+
+	writeQueueSize = 1024            // 写入队列长度
+	maxBatchSize   = 256             // 单次批量写入的最大记录数
+	enqueueTimeout = 5 * time.Second // 写入队列满时的最长等待时间
+)
+
+// DiskOptions 磁盘存储选项
+type DiskOptions struct {
+	SegmentSize        int64         // 单个段文件的最大字节数
+	Retention          time.Duration // 数据保留时长，0 表示永久保留
+	MaxRecordsPerAgent int           // 每个代理在内存索引中保留的记录数，更早的记录在段过期前仍保留在磁盘上
+}
+
+// indexEntry 记录在段文件中的位置
+type indexEntry struct {
+	timestamp int64  // 采集时间（UnixNano）
+	segment   uint32 // 段编号
+	offset    int64  // 记录在段文件中的偏移
+	length    uint32 // 记录负载长度（不含记录头）
+}
+
+// segment 段文件
+type segment struct {
+	id      uint32   // 段编号
+	file    *os.File // 段文件
+	size    int64    // 已写入的字节数
+	maxTime int64    // 段内最新记录的时间，用于过期清理
+}
+
+// agentIndex 单个代理的索引
+type agentIndex struct {
+	hostname string       // 最近一次上报的主机名
+	entries  []indexEntry // 按时间升序排列的记录位置
+}
+
+// DiskStore 基于追加写段文件和索引文件的磁盘存储
+//
+// 数据目录中每个段由两个文件组成：
+//   - NNNNNNNN.log 记录文件，每条记录为 长度(4) + CRC32(4) + JSON 负载
+//   - NNNNNNNN.idx 索引文件，每条索引对应 .log 中的一条记录
+//
+// 启动时加载索引文件，并扫描索引之后的记录补全索引，截断写入不完整的尾部。
+//
+// 写入由单个后台协程完成：Append 将记录放入有界队列，写协程批量追加到段文件，
+// 只在更新内存索引时短暂持有写锁；读取在读锁下复制索引后释放锁再读文件。
+type DiskStore struct {
+	mu       sync.RWMutex
+	dir      string
+	opts     DiskOptions
+	segments map[uint32]*segment
+	active   *segment // 活动段，只由写协程修改
+	index    *os.File // 活动段的索引文件
+	agents   map[string]*agentIndex
+	count    int
+	closed   bool
+
+	writes  chan *appendRequest // 写入队列
+	closing chan struct{}       // 关闭信号
+	stopped chan struct{}       // 写协程已退出
+}
+
+// appendRequest 写入请求
+type appendRequest struct {
+	data    AgentData
+	payload []byte
+	done    chan error
+}
+
+// OpenDiskStore 打开或创建磁盘存储
+func OpenDiskStore(dir string, opts DiskOptions) (*DiskStore, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+	if opts.MaxRecordsPerAgent <= 0 {
+		opts.MaxRecordsPerAgent = DefaultMaxRecordsPerAgent
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &DiskStore{
+		dir:      dir,
+		opts:     opts,
+		segments: make(map[uint32]*segment),
+		agents:   make(map[string]*agentIndex),
+		writes:   make(chan *appendRequest, writeQueueSize),
+		closing:  make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	ids, err := s.listSegments()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if err := s.loadSegment(id); err != nil {
+			s.closeFiles()
+			return nil, fmt.Errorf("load segment %d: %w", id, err)
+		}
+	}
+
+	// 继续写入最后一个段，没有段时创建第一个
+	next := uint32(1)
+	if len(ids) > 0 {
+		next = ids[len(ids)-1]
+	}
+	if err := s.openActive(next); err != nil {
+		s.closeFiles()
+		return nil, err
+	}
+
+	s.expire(time.Now())
+	go s.writeLoop()
+
+	log.Printf("Opened disk store %s: %d segments, %d records", dir, len(s.segments), s.count)
+	return s, nil
+}
+
+// listSegments 列出数据目录中的段编号（升序）
+func (s *DiskStore) listSegments() ([]uint32, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []uint32
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 32)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint32(id))
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// segmentPath 返回段文件路径
+func (s *DiskStore) segmentPath(id uint32, ext string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%08d%s", id, ext))
+}
+
+// loadSegment 加载段及其索引，必要时从段文件恢复索引
+func (s *DiskStore) loadSegment(id uint32) error {
+	file, err := os.OpenFile(s.segmentPath(id, segmentExt), os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	seg := &segment{id: id, file: file, size: info.Size()}
+	s.segments[id] = seg
+
+	// 读取索引，丢弃超出段文件范围的条目
+	indexed, validSize := int64(0), int64(0)
+	entries, err := readIndexFile(s.segmentPath(id, indexExt))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		end := e.entry.offset + recordHeaderSize + int64(e.entry.length)
+		if end > seg.size {
+			break
+		}
+		e.entry.segment = id
+		s.addEntry(e.agentID, e.hostname, e.entry)
+		seg.maxTime = max(seg.maxTime, e.entry.timestamp)
+		indexed = end
+		validSize = e.indexEnd
+	}
+
+	idx, err := os.OpenFile(s.segmentPath(id, indexExt), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	if err := idx.Truncate(validSize); err != nil {
+		return err
+	}
+	if _, err := idx.Seek(validSize, io.SeekStart); err != nil {
+		return err
+	}
+
+	// 扫描索引之后的记录补全索引
+	offset := indexed
+	w := bufio.NewWriter(idx)
+	for offset < seg.size {
+		data, length, err := readRecord(file, offset)
+		if err != nil {
+			log.Printf("Truncating segment %d at offset %d: %v", id, offset, err)
+			if err := file.Truncate(offset); err != nil {
+				return err
+			}
+			seg.size = offset
+			break
+		}
+
+		entry := indexEntry{timestamp: data.Timestamp.UnixNano(), segment: id, offset: offset, length: length}
+		offset += recordHeaderSize + int64(length)
+		encoded, err := encodeIndexEntry(data.AgentID, data.Hostname, entry)
+		if err != nil {
+			log.Printf("Skipping record in segment %d at offset %d: %v", id, entry.offset, err)
+			continue
+		}
+		if _, err := w.Write(encoded); err != nil {
+			return err
+		}
+		s.addEntry(data.AgentID, data.Hostname, entry)
+		seg.maxTime = max(seg.maxTime, entry.timestamp)
+	}
+
+	return w.Flush()
+}
+
+// openActive 打开活动段用于追加写入
+func (s *DiskStore) openActive(id uint32) error {
+	s.mu.RLock()
+	seg, ok := s.segments[id]
+	s.mu.RUnlock()
+
+	if !ok {
+		file, err := os.OpenFile(s.segmentPath(id, segmentExt), os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		seg = &segment{id: id, file: file}
+	}
+
+	if _, err := seg.file.Seek(seg.size, io.SeekStart); err != nil {
+		return err
+	}
+
+	idx, err := os.OpenFile(s.segmentPath(id, indexExt), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	if s.index != nil {
+		s.index.Close()
+	}
+
+	s.mu.Lock()
+	s.segments[id] = seg
+	s.active = seg
+	s.mu.Unlock()
+	s.index = idx
+	return nil
+}
+
+// Append 追加一条记录，队列已满且等待超时时返回 ErrBusy
+func (s *DiskStore) Append(data AgentData) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxRecordSize {
+		return fmt.Errorf("storage: record too large (%d bytes)", len(payload))
+	}
+	if err := checkIndexStrings(data.AgentID, data.Hostname); err != nil {
+		return err
+	}
+
+	req := &appendRequest{data: data, payload: payload, done: make(chan error, 1)}
+
+	timer := time.NewTimer(enqueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case s.writes <- req:
+	case <-s.closing:
+		return ErrClosed
+	case <-timer.C:
+		return ErrBusy
+	}
+
+	select {
+	case err := <-req.done:
+		return err
+	case <-s.stopped:
+		// 写协程退出前已处理完队列，未处理的请求说明存储已关闭
+		select {
+		case err := <-req.done:
+			return err
+		default:
+			return ErrClosed
+		}
+	}
+}
+
+// writeLoop 写协程，批量处理写入队列直到存储关闭
+func (s *DiskStore) writeLoop() {
+	defer close(s.stopped)
+
+	batch := make([]*appendRequest, 0, maxBatchSize)
+	for {
+		select {
+		case req := <-s.writes:
+			batch = append(batch[:0], req)
+		collect:
+			for len(batch) < maxBatchSize {
+				select {
+				case req := <-s.writes:
+					batch = append(batch, req)
+				default:
+					break collect
+				}
+			}
+			s.writeBatch(batch)
+
+		case <-s.closing:
+			// 处理关闭前已入队的请求
+			for {
+				select {
+				case req := <-s.writes:
+					s.writeBatch([]*appendRequest{req})
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// pendingEntry 已写入段文件、等待加入内存索引的条目
+type pendingEntry struct {
+	agentID  string
+	hostname string
+	entry    indexEntry
+}
+
+// writeBatch 将一批记录追加到段文件，一次写入后统一更新索引
+func (s *DiskStore) writeBatch(batch []*appendRequest) {
+	var (
+		buf     []byte
+		index   []byte
+		pending []pendingEntry
+		waiting []*appendRequest
+	)
+
+	// flush 将缓冲的记录写入当前段，失败时回滚段文件并通知等待中的请求
+	flush := func() error {
+		if len(buf) == 0 {
+			return nil
+		}
+
+		seg := s.active
+		if _, err := seg.file.Write(buf); err != nil {
+			seg.file.Truncate(seg.size)
+			seg.file.Seek(seg.size, io.SeekStart)
+			for _, req := range waiting {
+				req.done <- err
+			}
+			buf, index, pending, waiting = buf[:0], index[:0], pending[:0], waiting[:0]
+			return err
+		}
+		seg.size += int64(len(buf))
+
+		// 索引写入失败不影响数据，重启时会从段文件恢复
+		if _, err := s.index.Write(index); err != nil {
+			log.Printf("Failed to write index for segment %d: %v", seg.id, err)
+		}
+
+		s.mu.Lock()
+		for _, p := range pending {
+			seg.maxTime = max(seg.maxTime, p.entry.timestamp)
+			s.addEntry(p.agentID, p.hostname, p.entry)
+		}
+		s.mu.Unlock()
+
+		for _, req := range waiting {
+			req.done <- nil
+		}
+		buf, index, pending, waiting = buf[:0], index[:0], pending[:0], waiting[:0]
+		return nil
+	}
+
+	for _, req := range batch {
+		size := int64(recordHeaderSize + len(req.payload))
+
+		// 当前段写满后切换到新段
+		if s.active.size+int64(len(buf)) > 0 && s.active.size+int64(len(buf))+size > s.opts.SegmentSize {
+			flush()
+			if err := s.rotate(); err != nil {
+				req.done <- err
+				continue
+			}
+		}
+
+		entry := indexEntry{
+			timestamp: req.data.Timestamp.UnixNano(),
+			segment:   s.active.id,
+			offset:    s.active.size + int64(len(buf)),
+			length:    uint32(len(req.payload)),
+		}
+		encoded, err := encodeIndexEntry(req.data.AgentID, req.data.Hostname, entry)
+		if err != nil {
+			req.done <- err
+			continue
+		}
+
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(req.payload)))
+		buf = binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(req.payload))
+		buf = append(buf, req.payload...)
+		index = append(index, encoded...)
+		pending = append(pending, pendingEntry{agentID: req.data.AgentID, hostname: req.data.Hostname, entry: entry})
+		waiting = append(waiting, req)
+	}
+
+	flush()
+}
+
+// rotate 同步当前段并创建新段
+func (s *DiskStore) rotate() error {
+	if err := s.active.file.Sync(); err != nil {
+		return err
+	}
+	if err := s.openActive(s.active.id + 1); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.expire(time.Now())
+	s.mu.Unlock()
+	return nil
+}
+
+// expire 删除全部记录都超出保留时长的旧段，调用方需持有写锁
+func (s *DiskStore) expire(now time.Time) {
+	if s.opts.Retention <= 0 {
+		return
+	}
+
+	cutoff := now.Add(-s.opts.Retention).UnixNano()
+	removed := make(map[uint32]bool)
+	for id, seg := range s.segments {
+		if seg == s.active || seg.maxTime >= cutoff {
+			continue
+		}
+		seg.file.Close()
+		os.Remove(s.segmentPath(id, segmentExt))
+		os.Remove(s.segmentPath(id, indexExt))
+		delete(s.segments, id)
+		removed[id] = true
+	}
+
+	if len(removed) == 0 {
+		return
+	}
+
+	for agentID, agent := range s.agents {
+		kept := agent.entries[:0]
+		for _, e := range agent.entries {
+			if !removed[e.segment] {
+				kept = append(kept, e)
+			}
+		}
+		s.count -= len(agent.entries) - len(kept)
+		agent.entries = kept
+		if len(kept) == 0 {
+			delete(s.agents, agentID)
+		}
+	}
+	log.Printf("Expired %d segments older than %s", len(removed), s.opts.Retention)
+}
+
+// addEntry 将索引条目按时间顺序加入代理索引，超出上限时丢弃最早的条目
+func (s *DiskStore) addEntry(agentID, hostname string, entry indexEntry) {
+	agent, ok := s.agents[agentID]
+	if !ok {
+		agent = &agentIndex{}
+		s.agents[agentID] = agent
+	}
+
+	entries := agent.entries
+	i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].timestamp > entry.timestamp
+	})
+	if i == len(entries) {
+		agent.hostname = hostname
+	}
+	entries = append(entries, indexEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = entry
+	s.count++
+
+	// 超出上限 1/8 后再整体裁剪，避免每次追加都复制
+	limit := s.opts.MaxRecordsPerAgent
+	if len(entries) > limit+limit/8 {
+		drop := len(entries) - limit
+		entries = append(make([]indexEntry, 0, limit+limit/8+1), entries[drop:]...)
+		s.count -= drop
+	}
+
+	agent.entries = entries
+}
+
+// Latest 返回代理最近的 limit 条记录
+func (s *DiskStore) Latest(agentID string, limit int) ([]AgentData, error) {
+	s.mu.RLock()
+	agent, ok := s.agents[agentID]
+	if !ok {
+		s.mu.RUnlock()
+		return nil, nil
+	}
+
+	entries := agent.entries
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	entries, files, err := s.snapshot(entries)
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return readEntries(entries, files)
+}
+
+// Range 返回代理在 [from, to) 内的记录
+func (s *DiskStore) Range(agentID string, from, to time.Time) ([]AgentData, error) {
+	s.mu.RLock()
+	agent, ok := s.agents[agentID]
+	if !ok {
+		s.mu.RUnlock()
+		return nil, nil
+	}
+
+	entries := agent.entries
+	start, end := 0, len(entries)
+	if !from.IsZero() {
+		start = sort.Search(len(entries), func(i int) bool {
+			return entries[i].timestamp >= from.UnixNano()
+		})
+	}
+	if !to.IsZero() {
+		end = sort.Search(len(entries), func(i int) bool {
+			return entries[i].timestamp >= to.UnixNano()
+		})
+	}
+	if start >= end {
+		s.mu.RUnlock()
+		return nil, nil
+	}
+
+	entries, files, err := s.snapshot(entries[start:end])
+	s.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return readEntries(entries, files)
+}
+
+// snapshot 复制索引条目并返回其所在段的文件，调用方需持有读锁
+//
+// 乱序插入会原地移动索引切片中的条目，复制后才能在锁外读取。
+func (s *DiskStore) snapshot(entries []indexEntry) ([]indexEntry, map[uint32]*os.File, error) {
+	if s.closed {
+		return nil, nil, ErrClosed
+	}
+
+	copied := make([]indexEntry, len(entries))
+	copy(copied, entries)
+
+	files := make(map[uint32]*os.File)
+	for _, e := range copied {
+		if _, ok := files[e.segment]; ok {
+			continue
+		}
+		if seg, ok := s.segments[e.segment]; ok {
+			files[e.segment] = seg.file
+		}
+	}
+	return copied, files, nil
+}
+
+// readEntries 在锁外读取索引条目对应的记录，跳过读取期间已过期的段
+func readEntries(entries []indexEntry, files map[uint32]*os.File) ([]AgentData, error) {
+	result := make([]AgentData, 0, len(entries))
+	for _, e := range entries {
+		file, ok := files[e.segment]
+		if !ok {
+			continue
+		}
+		data, _, err := readRecord(file, e.offset)
+		if errors.Is(err, os.ErrClosed) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read segment %d offset %d: %w", e.segment, e.offset, err)
+		}
+		result = append(result, *data)
+	}
+	return result, nil
+}
+
+// Agents 返回全部代理的概要信息
+func (s *DiskStore) Agents() []AgentSummary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agents := make([]AgentSummary, 0, len(s.agents))
+	for agentID, agent := range s.agents {
+		if len(agent.entries) == 0 {
+			continue
+		}
+		last := agent.entries[len(agent.entries)-1]
+		agents = append(agents, AgentSummary{
+			AgentID:  agentID,
+			Hostname: agent.hostname,
+			LastSeen: time.Unix(0, last.timestamp),
+			Count:    len(agent.entries),
+		})
+	}
+
+	sort.Slice(agents, func(i, j int) bool {
+		return agents[i].AgentID < agents[j].AgentID
+	})
+	return agents
+}
+
+// Count 返回记录总数
+func (s *DiskStore) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.count
+}
+
+// Close 写入队列中剩余的记录，同步并关闭存储
+func (s *DiskStore) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.closing)
+	<-s.stopped
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	if s.active != nil {
+		err = s.active.file.Sync()
+	}
+	if s.index != nil {
+		if syncErr := s.index.Sync(); err == nil {
+			err = syncErr
+		}
+	}
+	s.closeFiles()
+	return err
+}
+
+// closeFiles 关闭全部打开的文件
+func (s *DiskStore) closeFiles() {
+	for _, seg := range s.segments {
+		seg.file.Close()
+	}
+	if s.index != nil {
+		s.index.Close()
+	}
+}
+
+// readRecord 读取并校验 offset 处的记录
+func readRecord(file *os.File, offset int64) (*AgentData, uint32, error) {
+	var header [recordHeaderSize]byte
+	if _, err := file.ReadAt(header[:], offset); err != nil {
+		return nil, 0, err
+	}
+
+	length := binary.LittleEndian.Uint32(header[0:4])
+	if length > maxRecordSize {
+		return nil, 0, fmt.Errorf("invalid record length %d", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := file.ReadAt(payload, offset+recordHeaderSize); err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, 0, errors.New("checksum mismatch")
+	}
+
+	var data AgentData
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return nil, 0, err
+	}
+	return &data, length, nil
+}
+
+// indexRecord 从索引文件解析出的条目
+type indexRecord struct {
+	agentID  string
+	hostname string
+	entry    indexEntry
+	indexEnd int64 // 该条目在索引文件中的结束位置
+}
+
+// encodeIndexEntry 编码索引条目，代理ID或主机名超出长度字段的范围时返回错误
+// 格式: 时间(8) + 偏移(8) + 长度(4) + ID长度(2) + ID + 主机名长度(2) + 主机名
+func encodeIndexEntry(agentID, hostname string, entry indexEntry) ([]byte, error) {
+	if err := checkIndexStrings(agentID, hostname); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 0, 24+len(agentID)+len(hostname))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(entry.timestamp))
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(entry.offset))
+	buf = binary.LittleEndian.AppendUint32(buf, entry.length)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(agentID)))
+	buf = append(buf, agentID...)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(len(hostname)))
+	buf = append(buf, hostname...)
+	return buf, nil
+}
+
+// checkIndexStrings 检查代理ID和主机名能否写入索引的 2 字节长度字段
+func checkIndexStrings(agentID, hostname string) error {
+	if len(agentID) > math.MaxUint16 {
+		return fmt.Errorf("storage: agent_id too long (%d bytes)", len(agentID))
+	}
+	if len(hostname) > math.MaxUint16 {
+		return fmt.Errorf("storage: hostname too long (%d bytes)", len(hostname))
+	}
+	return nil
+}
+
+// readIndexFile 读取索引文件中完整的条目，忽略末尾写入不完整的部分
+func readIndexFile(path string) ([]indexRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []indexRecord
+	pos := 0
+	for {
+		if pos+22 > len(data) {
+			break
+		}
+		rec := indexRecord{}
+		rec.entry.timestamp = int64(binary.LittleEndian.Uint64(data[pos:]))
+		rec.entry.offset = int64(binary.LittleEndian.Uint64(data[pos+8:]))
+		rec.entry.length = binary.LittleEndian.Uint32(data[pos+16:])
+		idLen := int(binary.LittleEndian.Uint16(data[pos+20:]))
+
+		p := pos + 22
+		if p+idLen+2 > len(data) {
+			break
+		}
+		rec.agentID = string(data[p : p+idLen])
+		p += idLen
+
+		hostLen := int(binary.LittleEndian.Uint16(data[p:]))
+		p += 2
+		if p+hostLen > len(data) {
+			break
+		}
+		rec.hostname = string(data[p : p+hostLen])
+		p += hostLen
+
+		rec.indexEnd = int64(p)
+		records = append(records, rec)
+		pos = p
+	}
+
+	return records, nil
+}
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMemoryLimit 内存存储每个代理保留的默认记录数
+const DefaultMemoryLimit = 1000
+
+// memoryAgent 单个代理的记录，各代理独立加锁
+type memoryAgent struct {
+	mu   sync.RWMutex
+	list []AgentData // 按时间升序排列
+}
+
+// MemoryStore 内存存储，重启后数据丢失，主要用于测试
+type MemoryStore struct {
+	mu     sync.RWMutex // 只保护 agents 映射本身
+	limit  int
+	agents map[string]*memoryAgent
+	count  atomic.Int64
+	closed atomic.Bool
+}
+
+// NewMemoryStore 创建内存存储，limit 为每个代理保留的最大记录数
+func NewMemoryStore(limit int) *MemoryStore {
+	if limit <= 0 {
+		limit = DefaultMemoryLimit
+	}
+
+	return &MemoryStore{
+		limit:  limit,
+		agents: make(map[string]*memoryAgent),
+	}
+}
+
+// agent 获取代理记录，create 为 true 时不存在则创建
+func (m *MemoryStore) agent(agentID string, create bool) *memoryAgent {
+	m.mu.RLock()
+	agent, ok := m.agents[agentID]
+	m.mu.RUnlock()
+	if ok || !create {
+		return agent
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if agent, ok = m.agents[agentID]; !ok {
+		agent = &memoryAgent{}
+		m.agents[agentID] = agent
+	}
+	return agent
+}
+
+// Append 追加一条记录
+func (m *MemoryStore) Append(data AgentData) error {
+	if m.closed.Load() {
+		return ErrClosed
+	}
+
+	agent := m.agent(data.AgentID, true)
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	list := agent.list
+
+	// 按时间有序插入
+	i := sort.Search(len(list), func(i int) bool {
+		return list[i].Timestamp.After(data.Timestamp)
+	})
+	list = append(list, AgentData{})
+	copy(list[i+1:], list[i:])
+	list[i] = data
+	m.count.Add(1)
+
+	// 保持最近的 limit 条记录，底层数组在下次扩容时释放旧记录
+	if len(list) > m.limit {
+		drop := len(list) - m.limit
+		list = list[drop:]
+		m.count.Add(-int64(drop))
+	}
+
+	agent.list = list
+	return nil
+}
+
+// Latest 返回代理最近的 limit 条记录
+func (m *MemoryStore) Latest(agentID string, limit int) ([]AgentData, error) {
+	agent := m.agent(agentID, false)
+	if agent == nil {
+		return nil, nil
+	}
+
+	agent.mu.RLock()
+	defer agent.mu.RUnlock()
+
+	list := agent.list
+	if limit > 0 && len(list) > limit {
+		list = list[len(list)-limit:]
+	}
+
+	result := make([]AgentData, len(list))
+	copy(result, list)
+	return result, nil
+}
+
+// Range 返回代理在 [from, to) 内的记录
+func (m *MemoryStore) Range(agentID string, from, to time.Time) ([]AgentData, error) {
+	agent := m.agent(agentID, false)
+	if agent == nil {
+		return nil, nil
+	}
+
+	agent.mu.RLock()
+	defer agent.mu.RUnlock()
+
+	var result []AgentData
+	for _, data := range agent.list {
+		if inRange(data.Timestamp, from, to) {
+			result = append(result, data)
+		}
+	}
+	return result, nil
+}
+
+// Agents 返回全部代理的概要信息
+func (m *MemoryStore) Agents() []AgentSummary {
+	m.mu.RLock()
+	ids := make([]string, 0, len(m.agents))
+	agents := make([]*memoryAgent, 0, len(m.agents))
+	for agentID, agent := range m.agents {
+		ids = append(ids, agentID)
+		agents = append(agents, agent)
+	}
+	m.mu.RUnlock()
+
+	summaries := make([]AgentSummary, 0, len(agents))
+	for i, agent := range agents {
+		agent.mu.RLock()
+		if n := len(agent.list); n > 0 {
+			last := agent.list[n-1]
+			summaries = append(summaries, AgentSummary{
+				AgentID:  ids[i],
+				Hostname: last.Hostname,
+				LastSeen: last.Timestamp,
+				Count:    n,
+			})
+		}
+		agent.mu.RUnlock()
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].AgentID < summaries[j].AgentID
+	})
+	return summaries
+}
+
+// Count 返回记录总数
+func (m *MemoryStore) Count() int {
+	return int(m.count.Load())
+}
+
+// Close 关闭存储
+func (m *MemoryStore) Close() error {
+	m.closed.Store(true)
+	return nil
+}
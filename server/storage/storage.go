@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// 存储错误
+var (
+	ErrClosed = errors.New("storage: closed")
+	ErrBusy   = errors.New("storage: write queue full")
+)
+
+// AgentData 代理数据结构
+type AgentData struct {
+	AgentID        string                 `json:"agent_id"`
+	Hostname       string                 `json:"hostname"`
+	Timestamp      time.Time              `json:"timestamp"`                 // 采集时间（代理时钟偏差过大时按服务端时钟校正），存储和查询均以此为准
+	CollectedAt    time.Time              `json:"collected_at,omitzero"`     // 代理上报的原始采集时间
+	ReceivedAt     time.Time              `json:"received_at,omitzero"`      // 服务端接收时间
+	ClockSkew      float64                `json:"clock_skew,omitempty"`      // 代理时钟相对服务端的偏差（秒），正数表示代理时钟偏快
+	ReportInterval int                    `json:"report_interval,omitempty"` // 代理上报间隔（秒）
+	ReportType     string                 `json:"report_type,omitempty"`     // 上报类型（full/delta），存储时增量已还原为全量
+	Seq            uint64                 `json:"seq,omitempty"`             // 上报序号
+	Data           map[string]interface{} `json:"data"`
+}
+
+// AgentSummary 代理概要信息
+type AgentSummary struct {
+	AgentID  string    `json:"agent_id"`   // 代理ID
+	Hostname string    `json:"hostname"`   // 最近一次上报的主机名
+	LastSeen time.Time `json:"last_seen"`  // 最近一次上报时间
+	Count    int       `json:"data_count"` // 记录数
+}
+
+// Store 代理数据存储接口
+type Store interface {
+	// Append 追加一条记录
+	Append(data AgentData) error
+	// Latest 返回代理最近的 limit 条记录，按时间升序排列
+	Latest(agentID string, limit int) ([]AgentData, error)
+	// Range 返回代理在 [from, to) 内的记录，按时间升序排列，零值表示不限制
+	Range(agentID string, from, to time.Time) ([]AgentData, error)
+	// Agents 返回全部代理的概要信息
+	Agents() []AgentSummary
+	// Count 返回记录总数
+	Count() int
+	// Close 关闭存储
+	Close() error
+}
+
+// inRange 判断时间是否在 [from, to) 内
+func inRange(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !t.Before(to) {
+		return false
+	}
+	return true
+}
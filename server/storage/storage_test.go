@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	stressAgents  = 300 // 同时上报的代理数
+	stressReports = 20  // 每个代理的上报次数
+)
+
+// stressData 第 agent 个代理的第 n 条记录，时间按序号递增
+func stressData(base time.Time, agent, n int) AgentData {
+	return AgentData{
+		AgentID:   fmt.Sprintf("agent-%03d", agent),
+		Hostname:  fmt.Sprintf("host-%03d", agent),
+		Timestamp: base.Add(time.Duration(n) * time.Second),
+		Seq:       uint64(n + 1),
+		Data: map[string]interface{}{
+			"system": map[string]interface{}{"hostname": fmt.Sprintf("host-%03d", agent), "n": n},
+		},
+	}
+}
+
+// stressStore 数百个代理并发写入，同时并发执行各类查询，写完后校验结果
+func stressStore(t *testing.T, store Store) {
+	t.Helper()
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	done := make(chan struct{})
+
+	// 查询与写入并发进行，依赖 -race 发现数据竞争
+	var readers sync.WaitGroup
+	for r := 0; r < 8; r++ {
+		readers.Add(1)
+		go func(r int) {
+			defer readers.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				agentID := fmt.Sprintf("agent-%03d", (r*37+i)%stressAgents)
+				latest, err := store.Latest(agentID, 5)
+				if err != nil {
+					t.Errorf("Latest(%s): %v", agentID, err)
+					return
+				}
+				for j := 1; j < len(latest); j++ {
+					if latest[j].Timestamp.Before(latest[j-1].Timestamp) {
+						t.Errorf("Latest(%s) not in time order", agentID)
+						return
+					}
+				}
+				if _, err := store.Range(agentID, base, base.Add(10*time.Second)); err != nil {
+					t.Errorf("Range(%s): %v", agentID, err)
+					return
+				}
+				if agents := store.Agents(); len(agents) > stressAgents {
+					t.Errorf("Agents() returned %d agents", len(agents))
+					return
+				}
+				if n := store.Count(); n > stressAgents*stressReports {
+					t.Errorf("Count() = %d", n)
+					return
+				}
+			}
+		}(r)
+	}
+
+	var writers sync.WaitGroup
+	for a := 0; a < stressAgents; a++ {
+		writers.Add(1)
+		go func(a int) {
+			defer writers.Done()
+			// 倒序写入一半记录，校验乱序上报按时间插入
+			for n := 0; n < stressReports; n++ {
+				idx := n
+				if a%2 == 1 {
+					idx = stressReports - 1 - n
+				}
+				if err := store.Append(stressData(base, a, idx)); err != nil {
+					t.Errorf("Append(agent %d, %d): %v", a, idx, err)
+					return
+				}
+			}
+		}(a)
+	}
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	checkStressResult(t, store, base)
+}
+
+// checkStressResult 校验全部记录都已写入且查询结果正确
+func checkStressResult(t *testing.T, store Store, base time.Time) {
+	t.Helper()
+
+	if n := store.Count(); n != stressAgents*stressReports {
+		t.Fatalf("Count() = %d, want %d", n, stressAgents*stressReports)
+	}
+
+	agents := store.Agents()
+	if len(agents) != stressAgents {
+		t.Fatalf("Agents() returned %d agents, want %d", len(agents), stressAgents)
+	}
+	for i, summary := range agents {
+		if want := fmt.Sprintf("agent-%03d", i); summary.AgentID != want {
+			t.Fatalf("Agents()[%d] = %s, want %s", i, summary.AgentID, want)
+		}
+		if summary.Count != stressReports {
+			t.Errorf("%s count = %d, want %d", summary.AgentID, summary.Count, stressReports)
+		}
+		if want := base.Add((stressReports - 1) * time.Second); !summary.LastSeen.Equal(want) {
+			t.Errorf("%s last seen = %v, want %v", summary.AgentID, summary.LastSeen, want)
+		}
+	}
+
+	for a := 0; a < stressAgents; a++ {
+		agentID := fmt.Sprintf("agent-%03d", a)
+
+		latest, err := store.Latest(agentID, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(latest) != 3 {
+			t.Fatalf("Latest(%s, 3) returned %d records", agentID, len(latest))
+		}
+		for j, data := range latest {
+			if want := uint64(stressReports - 2 + j); data.Seq != want {
+				t.Errorf("Latest(%s)[%d].Seq = %d, want %d", agentID, j, data.Seq, want)
+			}
+		}
+
+		// [base+5s, base+10s) 包含序号 6..10 的 5 条记录
+		ranged, err := store.Range(agentID, base.Add(5*time.Second), base.Add(10*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ranged) != 5 {
+			t.Fatalf("Range(%s) returned %d records, want 5", agentID, len(ranged))
+		}
+		for j, data := range ranged {
+			if want := uint64(6 + j); data.Seq != want {
+				t.Errorf("Range(%s)[%d].Seq = %d, want %d", agentID, j, data.Seq, want)
+			}
+			if data.AgentID != agentID {
+				t.Errorf("Range(%s) returned record of %s", agentID, data.AgentID)
+			}
+		}
+	}
+}
+
+func TestMemoryStoreConcurrent(t *testing.T) {
+	store := NewMemoryStore(0)
+	defer store.Close()
+
+	stressStore(t, store)
+}
+
+func TestDiskStoreConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	// 段文件较小，写入过程中会多次轮转
+	opts := DiskOptions{SegmentSize: 64 << 10}
+
+	store, err := OpenDiskStore(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stressStore(t, store)
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开后索引与写入前一致
+	reopened, err := OpenDiskStore(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	latest, err := reopened.Latest("agent-000", 1)
+	if err != nil || len(latest) != 1 {
+		t.Fatalf("Latest after reopen: %v, %d records", err, len(latest))
+	}
+	base := latest[0].Timestamp.Add(-(stressReports - 1) * time.Second)
+	checkStressResult(t, reopened, base)
+}
+
+func TestDiskStoreRejectsOversizedNames(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenDiskStore(dir, DiskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	long := strings.Repeat("a", 1<<16)
+	now := time.Now().Add(-time.Minute)
+	for _, data := range []AgentData{
+		{AgentID: long, Hostname: "host", Timestamp: now},
+		{AgentID: "agent", Hostname: long, Timestamp: now},
+	} {
+		if err := store.Append(data); err == nil {
+			t.Errorf("Append with %d-byte agent_id and %d-byte hostname succeeded", len(data.AgentID), len(data.Hostname))
+		}
+	}
+	if _, err := encodeIndexEntry(long, "host", indexEntry{}); err == nil {
+		t.Error("encodeIndexEntry accepted a 65536-byte agent_id")
+	}
+
+	// 被拒绝的记录不影响之后写入的索引
+	for i := 0; i < 3; i++ {
+		if err := store.Append(AgentData{AgentID: "agent", Hostname: "host", Timestamp: now.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenDiskStore(dir, DiskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	agents := reopened.Agents()
+	if len(agents) != 1 || agents[0].AgentID != "agent" || agents[0].Hostname != "host" || agents[0].Count != 3 {
+		t.Errorf("agents after reopen = %+v", agents)
+	}
+}
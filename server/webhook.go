@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mini-hids/server/alerts"
+	"mini-hids/server/config"
+	"mini-hids/server/notify"
+)
+
+// newNotifier 根据配置创建 Webhook 通知器
+func newNotifier(cfg config.NotificationConfig) *notify.Notifier {
+	targets := make([]notify.Target, 0, len(cfg.Webhooks))
+	for _, hook := range cfg.Webhooks {
+		target := notify.Target{
+			Name:        hook.Name,
+			URL:         hook.URL,
+			Format:      hook.Format,
+			Secret:      hook.Secret,
+			MinSeverity: hook.MinSeverity,
+			Events:      hook.Events,
+		}
+		if target.Format == "" {
+			target.Format = notify.FormatGeneric
+		}
+		// 默认只通知高危及以上告警
+		if target.MinSeverity == "" {
+			target.MinSeverity = "high"
+		}
+		targets = append(targets, target)
+	}
+	return notify.New(targets, cfg.MaxRetries)
+}
+
+// notifyAlert 发送新告警通知
+func (s *Server) notifyAlert(alert alerts.Alert) {
+	s.notifier.Notify(notify.Event{
+		Type:     notify.EventAlert,
+		Severity: alert.Severity,
+		Title:    fmt.Sprintf("[%s] %s", alert.Severity, alert.RuleName),
+		Text: fmt.Sprintf("Agent: %s (%s)\nRule: %s\nAlert: %s\nFirst seen: %s",
+			alert.Hostname, alert.AgentID, alert.RuleID, alert.ID, alert.FirstSeen.Format(time.RFC3339)),
+		AgentID:   alert.AgentID,
+		Hostname:  alert.Hostname,
+		Data:      alert,
+		Timestamp: alert.FirstSeen,
+	})
+}
+
+// handleListWebhooks 获取已配置的 Webhook 目标
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"webhooks": s.notifier.Targets()})
+}
+
+// handleListDeliveries 查询 Webhook 投递记录，支持 target、status、limit 参数
+func (s *Server) handleListDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	limit := 100
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "Invalid limit parameter", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveries": s.notifier.Deliveries(query.Get("target"), query.Get("status"), limit),
+	})
+}